@@ -0,0 +1,102 @@
+// Comando logdecode expande os campos comprimidos (prefixo "gzip+base64:") das gravações de
+// RECORD_REQUESTS e de logs JSON, imprimindo o JSON com o texto original.
+//
+// Uso:
+//
+//	go run ./cmd/logdecode <arquivo.json>...
+//	go run ./cmd/logdecode < app.log
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/webchatcomllm/utils"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		if err := decodeStream(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "erro:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	failed := false
+	for _, path := range os.Args[1:] {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "erro:", err)
+			failed = true
+			continue
+		}
+		err = decodeStream(f, os.Stdout)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "erro em %s: %v\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// decodeStream lê valores JSON em sequência (um documento ou uma entrada de log por linha) e
+// imprime cada um, indentado, com os campos comprimidos expandidos.
+func decodeStream(r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if v, err = expand(v); err != nil {
+			return err
+		}
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+}
+
+// expand percorre o valor e descomprime as strings com utils.LogBlobPrefix.
+func expand(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		if !strings.HasPrefix(t, utils.LogBlobPrefix) {
+			return t, nil
+		}
+		return utils.DecompressLogField(t)
+	case map[string]interface{}:
+		for k, item := range t {
+			expanded, err := expand(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			t[k] = expanded
+		}
+	case []interface{}:
+		for i, item := range t {
+			expanded, err := expand(item)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = expanded
+		}
+	}
+	return v, nil
+}
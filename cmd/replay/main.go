@@ -0,0 +1,82 @@
+// Comando replay reexecuta as requisições gravadas com RECORD_REQUESTS contra um provedor
+// simulado e aponta as respostas que divergem da gravação.
+//
+// Uso:
+//
+//	go run ./cmd/replay <diretório-ou-arquivo.json>...
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/webchatcomllm/handlers"
+	"go.uber.org/zap"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "uso: replay <diretório-ou-arquivo.json>...")
+		os.Exit(2)
+	}
+
+	logger := zap.NewNop()
+	if os.Getenv("REPLAY_VERBOSE") != "" {
+		logger, _ = zap.NewDevelopment()
+	}
+
+	files, err := collectFiles(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "erro:", err)
+		os.Exit(2)
+	}
+
+	failures := 0
+	for _, path := range files {
+		rec, err := handlers.LoadRecording(path)
+		if err != nil {
+			fmt.Printf("ERRO  %s: %v\n", path, err)
+			failures++
+			continue
+		}
+
+		got := handlers.ReplayRecording(rec, logger)
+		want := rec.Response
+		if got.Status != want.Status || got.Response != want.Response || got.ErrorCategory != want.ErrorCategory {
+			fmt.Printf("FALHA %s\n  esperado: [%s/%s] %.200q\n  obtido:   [%s/%s] %.200q\n",
+				path, want.Status, want.ErrorCategory, want.Response, got.Status, got.ErrorCategory, got.Response)
+			failures++
+			continue
+		}
+		fmt.Printf("OK    %s\n", path)
+	}
+
+	fmt.Printf("\n%d gravação(ões), %d falha(s)\n", len(files), failures)
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
+
+// collectFiles expande diretórios nas gravações .json que eles contêm, em ordem.
+func collectFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
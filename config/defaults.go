@@ -18,6 +18,16 @@ const (
 	ClaudeAPIURL     = "https://api.anthropic.com/v1/messages"
 	ClaudeAPIVersion = "2023-06-01"
 
+	// Google Gemini
+	GeminiDefaultModel = "gemini-2.5-flash"
+	GeminiPro          = "gemini-2.5-pro"
+	GeminiAPIBaseURL   = "https://generativelanguage.googleapis.com/v1beta/models"
+
+	// Ollama (modelos locais)
+	OllamaDefaultBaseURL = "http://localhost:11434"
+	OllamaDefaultModel   = "llama3.1"
+	OllamaDefaultTimeout = 5 * time.Minute
+
 	// Configurações de Retry
 	DefaultMaxRetries     = 3
 	DefaultInitialBackoff = 2 * time.Second
@@ -25,3 +35,7 @@ const (
 	// Configurações Gerais de Log
 	DefaultLogFile = "app.log"
 )
+
+// Version é a versão da aplicação, definida no build com
+// -ldflags "-X github.com/webchatcomllm/config.Version=<versão>".
+var Version = "dev"
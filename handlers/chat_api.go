@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	llmclient "github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/llm/manager"
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// chatAPI atende o chat via HTTP para clientes que não usam WebSocket.
+type chatAPI struct {
+	llmManager    manager.LLMManager
+	fileProcessor *utils.FileProcessor
+	processors    RequestProcessorChain
+	// extractions é compartilhado por todas as requisições HTTP de chat
+	extractions extractionSlots
+	config      HandlerConfig
+	logger      *zap.Logger
+	// stream envia sempre a resposta via SSE, independente do cabeçalho Accept
+	stream bool
+}
+
+// ChatAPIHandler cria o handler de POST /api/chat. Por padrão a resposta completa é devolvida
+// em JSON; com "Accept: text/event-stream" ou "?stream=true" ela é enviada em trechos via SSE.
+func ChatAPIHandler(llmManager manager.LLMManager, logger *zap.Logger) http.HandlerFunc {
+	return newChatAPI(llmManager, logger).serveHTTP
+}
+
+func newChatAPI(llmManager manager.LLMManager, logger *zap.Logger) *chatAPI {
+	handlerConfig := LoadHandlerConfig()
+	return &chatAPI{
+		llmManager:    llmManager,
+		fileProcessor: utils.NewFileProcessor(logger).WithConfig(handlerConfig.FileProcessing),
+		processors:    buildRequestProcessorChain(handlerConfig.RequestProcessors, logger),
+		extractions:   sharedHTTPExtractions(handlerConfig.MaxHTTPExtractions),
+		config:        handlerConfig,
+		logger:        logger,
+	}
+}
+
+func (a *chatAPI) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var req RequestPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxTotalUploadSize)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Payload inválido: "+err.Error(), utils.ErrorCategoryClient)
+		return
+	}
+
+	if provider, applied := a.config.resolveProvider(req.Provider); applied {
+		a.logger.Info("Provedor não especificado, usando provedor padrão", zap.String("provider", provider))
+		req.Provider = provider
+	}
+	if err := a.processors.Process(r.Context(), &req); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error(), utils.ErrorCategoryClient)
+		return
+	}
+	resolveModelAlias(&req, a.logger)
+	extractPastedImages(&req, a.logger)
+	if err := validateChatRequest(req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error(), utils.ErrorCategoryClient)
+		return
+	}
+	applyVisionRouting(&req, a.config, a.logger)
+	if _, err := applyMaintenanceRouting(&req, a.config, a.logger); err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, err.Error(), utils.ErrorCategoryMaintenance)
+		return
+	}
+
+	llmClient, err := a.llmManager.GetClient(req.Provider, req.Model)
+	if err != nil {
+		if category := clientErrorCategory(err); category != utils.ErrorCategoryClient {
+			writeAPIError(w, http.StatusServiceUnavailable, err.Error(), category)
+			return
+		}
+		writeAPIError(w, http.StatusBadRequest, err.Error(), utils.ErrorCategoryClient)
+		return
+	}
+	applyProviderParams(llmClient, req, a.logger)
+
+	// O WriteTimeout do servidor é curto para as rotas comuns; chamadas ao LLM podem levar minutos
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		a.logger.Debug("Não foi possível remover o prazo de escrita", zap.Error(err))
+	}
+
+	// O contexto da requisição é cancelado quando o cliente desconecta, interrompendo o provedor
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout(req, a.config, a.logger))
+	defer cancel()
+	ctx = startTimings(ctx, a.config)
+
+	a.logger.Info("Requisição REST de chat recebida",
+		zap.String("provider", req.Provider),
+		zap.String("model", req.Model),
+		zap.Int("files_count", len(req.Files)),
+		zap.Bool("stream", a.stream || wantsStream(r)),
+	)
+
+	if a.stream || wantsStream(r) {
+		a.serveStream(ctx, w, rc, req, llmClient)
+		return
+	}
+	a.serveJSON(ctx, w, req, llmClient)
+}
+
+// serveJSON processa a requisição e devolve a resposta completa em um único JSON.
+func (a *chatAPI) serveJSON(ctx context.Context, w http.ResponseWriter, req RequestPayload, llmClient llmclient.LLMClient) {
+	start := time.Now()
+	prompt, err := preparePrompt(ctx, req, llmClient, a.fileProcessor, a.extractions, a.config, discardProgress{}, a.logger)
+	recordPhase(ctx, utils.PhaseFileProcessing, start)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error(), utils.ErrorCategoryClient)
+		return
+	}
+
+	baseHistory := capHistory(req.History, a.config, a.logger)
+	var history []models.Message
+	prepare := func(llmClient llmclient.LLMClient) (preparedPrompt, error) {
+		return preparePrompt(ctx, req, llmClient, a.fileProcessor, a.extractions, a.config, discardProgress{}, a.logger)
+	}
+	llmStart := time.Now()
+	result, err := generateWithFallback(ctx, &req, llmClient, &prompt, a.llmManager, a.config, a.logger, prepare,
+		func(ctx context.Context, llmClient llmclient.LLMClient, prompt preparedPrompt) (llmResult, error) {
+			// O prompt padrão é o do provedor da tentativa, que muda na troca para um alternativo
+			history = applySystemInstructions(baseHistory, req, a.config)
+			return generate(ctx, llmClient, prompt, history, req.ResponseTemplate, nil, a.logger)
+		})
+	recordPhase(ctx, utils.PhaseLLM, llmStart)
+	if err != nil {
+		if requestTimedOut(ctx) {
+			writeAPIError(w, http.StatusGatewayTimeout, timeoutMessage(requestTimeout(req, a.config, a.logger)), utils.ErrorCategoryTimeout)
+			return
+		}
+		noteProviderError(req.Provider, err, a.config, a.logger)
+		category := utils.ErrorCategoryOf(err)
+		writeAPIError(w, httpStatusForCategory(category), llmErrorMessage(req.Provider, err), category)
+		return
+	}
+
+	response := buildChatResponse(req, prompt, history, result, a.config)
+	assignMessageIDs(nil, &req, &response, a.logger)
+	attachTimings(ctx, &response, start)
+	recordRequest(req, response)
+	logSlowRequest(req, response, time.Since(start), a.config, a.logger)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// serveStream envia a resposta via SSE: eventos "progress" durante o processamento dos arquivos,
+// "chunk" para cada trecho gerado e "done" (ou "error") ao final.
+func (a *chatAPI) serveStream(ctx context.Context, w http.ResponseWriter, rc *http.ResponseController, req RequestPayload, llmClient llmclient.LLMClient) {
+	start := time.Now()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &sseWriter{w: w, rc: rc}
+	if err := rc.Flush(); err != nil {
+		a.logger.Error("Streaming não suportado pela conexão", zap.Error(err))
+		return
+	}
+
+	filesStart := time.Now()
+	prompt, err := preparePrompt(ctx, req, llmClient, a.fileProcessor, a.extractions, a.config, stream, a.logger)
+	recordPhase(ctx, utils.PhaseFileProcessing, filesStart)
+	if err != nil {
+		stream.event("error", ResponsePayload{Type: "error", Status: "error", Response: err.Error(), ErrorCategory: utils.ErrorCategoryClient})
+		return
+	}
+
+	ctx = withQueueProgress(ctx, stream)
+	baseHistory := capHistory(req.History, a.config, a.logger)
+	var history []models.Message
+	sendChunk := func(chunk string) error {
+		return stream.event("chunk", ResponsePayload{Type: "chunk", Status: "streaming", Response: chunk, Provider: req.Provider})
+	}
+	prepare := func(llmClient llmclient.LLMClient) (preparedPrompt, error) {
+		return preparePrompt(ctx, req, llmClient, a.fileProcessor, a.extractions, a.config, stream, a.logger)
+	}
+	llmStart := time.Now()
+	result, err := generateWithFallback(ctx, &req, llmClient, &prompt, a.llmManager, a.config, a.logger, prepare,
+		func(ctx context.Context, llmClient llmclient.LLMClient, prompt preparedPrompt) (llmResult, error) {
+			history = applySystemInstructions(baseHistory, req, a.config)
+			return generateWithDeadline(ctx, a.config.MaxStreamDuration, a.logger, func(ctx context.Context) (llmResult, error) {
+				return generateWithStopPattern(ctx, req.StopPattern, sendChunk, a.logger, func(ctx context.Context, onChunk func(chunk string) error) (llmResult, error) {
+					return generate(ctx, llmClient, prompt, history, req.ResponseTemplate, onChunk, a.logger)
+				})
+			})
+		})
+	recordPhase(ctx, utils.PhaseLLM, llmStart)
+	if err != nil {
+		if ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+			a.logger.Info("Cliente desconectou durante o streaming", zap.String("provider", req.Provider))
+			return
+		}
+		if requestTimedOut(ctx) {
+			stream.event("error", ResponsePayload{
+				Type:          "error",
+				Status:        "error",
+				Response:      timeoutMessage(requestTimeout(req, a.config, a.logger)),
+				ErrorCategory: utils.ErrorCategoryTimeout,
+			})
+			return
+		}
+		stream.event("error", ResponsePayload{
+			Type:          "error",
+			Status:        "error",
+			Response:      llmErrorMessage(req.Provider, err),
+			ErrorCategory: utils.ErrorCategoryOf(err),
+		})
+		return
+	}
+
+	done := buildChatResponse(req, prompt, history, result, a.config)
+	done.Type = "done"
+	assignMessageIDs(nil, &req, &done, a.logger)
+	attachTimings(ctx, &done, start)
+	recordRequest(req, done)
+	logSlowRequest(req, done, time.Since(start), a.config, a.logger)
+	stream.event("done", done)
+}
+
+// wantsStream indica se o cliente pediu a resposta em streaming.
+func wantsStream(r *http.Request) bool {
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return true
+	}
+	stream := strings.ToLower(r.URL.Query().Get("stream"))
+	return stream == "true" || stream == "1"
+}
+
+// IsLongLivedRequest indica requisições que não devem passar pelo limite global de duração:
+// upgrades de WebSocket e respostas em streaming (SSE), inclusive as de ChatStreamPath.
+func IsLongLivedRequest(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r) || wantsStream(r) || r.URL.Path == ChatStreamPath
+}
+
+// httpStatusForCategory traduz a categoria de um erro do provedor para o status HTTP da API.
+func httpStatusForCategory(category utils.ErrorCategory) int {
+	switch category {
+	case utils.ErrorCategoryRateLimit:
+		return http.StatusTooManyRequests
+	case utils.ErrorCategoryTimeout:
+		return http.StatusGatewayTimeout
+	case utils.ErrorCategoryMaintenance:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// writeAPIError responde com um ResponsePayload de erro no status informado.
+func writeAPIError(w http.ResponseWriter, status int, message string, category utils.ErrorCategory) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ResponsePayload{
+		Type:          "message",
+		Status:        "error",
+		Response:      message,
+		ErrorCategory: category,
+	})
+}
+
+// sseWriter escreve eventos SSE e descarrega o buffer após cada um.
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s *sseWriter) event(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *sseWriter) sendProgress(message string, current, total, percentage int, eta time.Duration) {
+	s.event("progress", ProgressPayload{
+		Type:       "progress",
+		Status:     "processing",
+		Message:    message,
+		Current:    current,
+		Total:      total,
+		Percentage: percentage,
+		EtaSeconds: etaSeconds(eta),
+	})
+}
+
+// discardProgress ignora o progresso quando não há canal para reportá-lo.
+type discardProgress struct{}
+
+func (discardProgress) sendProgress(string, int, int, int, time.Duration) {}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/webchatcomllm/llm/catalog"
+	llmclient "github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/store"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// maxRawResponseChars limita o corpo bruto do provedor incluído nos metadados de debug.
+const maxRawResponseChars = 20000
+
+// llmErrorPrefix antecede as mensagens de erro retornadas pelos provedores.
+const llmErrorPrefix = "Erro ao processar resposta do LLM: "
+
+// progressReporter recebe as atualizações de progresso do processamento de arquivos; eta é
+// a estimativa do tempo restante (0 quando desconhecida).
+type progressReporter interface {
+	sendProgress(message string, current, total, percentage int, eta time.Duration)
+}
+
+// withQueueProgress informa ao usuário, como progresso, a espera por uma vaga no limite de
+// concorrência do provedor.
+func withQueueProgress(ctx context.Context, progress progressReporter) context.Context {
+	return utils.WithQueueNotifier(ctx, func(message string) {
+		progress.sendProgress(message, 0, 0, 0, 0)
+	})
+}
+
+// preparedPrompt é o prompt final de uma requisição, já com o contexto dos arquivos.
+type preparedPrompt struct {
+	FullPrompt  string
+	FileContext string
+	Attachments []models.Attachment
+	// ContextTrimmed indica que o conteúdo dos arquivos foi reduzido pelo limite de contexto
+	ContextTrimmed bool
+	// FileTypes conta os arquivos incluídos no contexto por tipo
+	FileTypes map[utils.FileType]int
+	// Images são as imagens enviadas como partes multimodais, fora do texto do prompt
+	Images []models.Attachment
+	// Tables são os arquivos CSV/JSON consultados pela ferramenta query_file
+	Tables []*fileTable
+	// SuspiciousFiles são os arquivos isolados por conter possíveis injeções de instruções
+	SuspiciousFiles []string
+}
+
+// llmResult reúne a resposta do provedor e as informações adicionais que ele retornou.
+type llmResult struct {
+	Response        string
+	Citations       []models.Citation
+	TemplateMatched *bool
+	Usage           *models.Usage
+	// TruncatedByTimeout indica que o streaming foi interrompido por MaxStreamDuration
+	TruncatedByTimeout bool
+	// StoppedByPattern indica que o streaming foi interrompido por StopPattern
+	StoppedByPattern bool
+	// RawResponse é o corpo bruto retornado pelo provedor (apenas sem streaming)
+	RawResponse []byte
+	// FallbackAttempts são os provedores que falharam antes do que respondeu
+	FallbackAttempts []fallbackAttempt
+	// Model é o modelo que o cliente de fato usou, que pode diferir do pedido quando o
+	// provedor não o suporta e recorre ao padrão
+	Model string
+}
+
+// validateChatRequest aplica as validações de entrada comuns aos transportes de chat.
+func validateChatRequest(req RequestPayload) error {
+	if req.Provider == "" {
+		return errors.New("Provedor LLM não especificado. Selecione um provedor e tente novamente.")
+	}
+	if req.Prompt == "" && len(req.Files) == 0 {
+		return errors.New("Mensagem vazia. Digite algo ou anexe arquivos.")
+	}
+	if len(req.Files) > MaxFilesPerRequest {
+		return fmt.Errorf("Número máximo de arquivos excedido. Limite: %d", MaxFilesPerRequest)
+	}
+	if len(req.ProviderParams) > 0 {
+		if err := catalog.ValidateProviderParams(req.Provider, req.ProviderParams); err != nil {
+			return fmt.Errorf("Parâmetros do provedor inválidos: %w", err)
+		}
+	}
+	if err := validateFallbackProviders(req.FallbackProviders); err != nil {
+		return err
+	}
+	if req.SessionID != "" && !store.IssuedSessionID(req.SessionID) {
+		return errors.New("ID de sessão inválido. Use um ID emitido pelo servidor em POST /api/sessions.")
+	}
+	if req.ParentMessageID != "" && !store.ValidMessageID(req.ParentMessageID) {
+		return errors.New("ID da mensagem de origem inválido.")
+	}
+	if req.StopPattern != "" {
+		if _, err := compileStopPattern(req.StopPattern); err != nil {
+			return err
+		}
+	}
+	if req.ResponseTemplate != nil {
+		return req.ResponseTemplate.validate()
+	}
+	return nil
+}
+
+// resolveModelAlias troca um alias de modelo ("claude-latest") pelo ID concreto do catálogo,
+// que é o usado no restante do fluxo e devolvido na resposta.
+func resolveModelAlias(req *RequestPayload, logger *zap.Logger) {
+	resolved := catalog.ResolveModel(req.Provider, req.Model)
+	if resolved == req.Model {
+		return
+	}
+	logger.Debug("Alias de modelo resolvido",
+		zap.String("provider", req.Provider),
+		zap.String("alias", req.Model),
+		zap.String("model", resolved),
+	)
+	req.Model = resolved
+}
+
+// applyProviderParams repassa ao cliente os parâmetros específicos do provedor. Clientes sem
+// suporte ignoram os parâmetros, o que é registrado em log.
+func applyProviderParams(llmClient llmclient.LLMClient, req RequestPayload, logger *zap.Logger) {
+	if len(req.ProviderParams) == 0 {
+		return
+	}
+	if paramsClient, ok := llmClient.(llmclient.ParamsClient); ok {
+		paramsClient.SetProviderParams(req.ProviderParams)
+		return
+	}
+	logger.Warn("Cliente não aceita parâmetros do provedor, parâmetros ignorados",
+		zap.String("provider", req.Provider),
+		zap.Int("params", len(req.ProviderParams)),
+	)
+}
+
+// preparePrompt processa os arquivos da requisição e monta o prompt enviado ao provedor.
+// slots limita as extrações simultâneas da conexão (nil sem limite).
+func preparePrompt(ctx context.Context, req RequestPayload, llmClient llmclient.LLMClient, fp *utils.FileProcessor, slots extractionSlots, cfg HandlerConfig, progress progressReporter, logger *zap.Logger) (preparedPrompt, error) {
+	var p preparedPrompt
+	files := req.Files
+	// Os arquivos e as tabelas da requisição compartilham o nonce dos marcadores de conteúdo suspeito
+	untrusted := newUntrustedBlock()
+
+	if len(files) > 0 && (cfg.FileTools || req.FileTools) {
+		files, p.Tables = splitToolFiles(files, llmClient, logger)
+	}
+	// Documentos nativos dependem do cliente para saber o que o provedor aceita. A chamada com
+	// ferramentas não leva anexos, então com tabelas os documentos seguem para a extração local
+	if len(files) > 0 && (cfg.NativeDocuments || req.NativeDocuments) {
+		if len(p.Tables) == 0 {
+			files, p.Attachments = splitNativeDocuments(files, llmClient, logger)
+		} else {
+			logger.Info("Documentos nativos desativados na requisição com ferramentas de arquivo; os documentos seguem para a extração local",
+				zap.Int("tables", len(p.Tables)))
+		}
+	}
+
+	if cfg.MaxContextChars > 0 && len(req.Prompt) > cfg.MaxContextChars {
+		return p, fmt.Errorf("Mensagem muito longa: %d caracteres (limite de contexto: %d)", len(req.Prompt), cfg.MaxContextChars)
+	}
+
+	if len(files) > 0 {
+		metadataFormat, err := resolveMetadataFormat(req.MetadataFormat, cfg.FileMetadataFormat)
+		if err != nil {
+			return p, err
+		}
+		opts := fileProcessingOptions{
+			MaxImages:             cfg.MaxImagesPerRequest,
+			IncludeEmbeddedImages: cfg.FileProcessing.ExtractEmbeddedImages && catalog.SupportsVision(req.Provider, req.Model),
+			Timeout:               fileProcessingTimeout(req, cfg),
+			ImageMode:             imageModeFor(llmClient, req.Provider, req.Model),
+			Slots:                 slots,
+			MetadataFormat:        metadataFormat,
+			DetectPromptInjection: cfg.DetectPromptInjection,
+			Untrusted:             untrusted,
+			MaxListedFailures:     cfg.MaxListedFailedFiles,
+		}
+		if opts.MaxImages <= 0 {
+			opts.MaxImages = catalog.GetMaxImages(req.Provider, req.Model)
+		}
+		if cfg.MaxContextChars > 0 {
+			// O orçamento dos arquivos é o que sobra do limite após a pergunta e o histórico; sem
+			// sobra, a requisição é recusada (0 significaria "sem limite")
+			budget := cfg.MaxContextChars - len(req.Prompt) - historySize(req.History)
+			if budget <= 0 {
+				return p, fmt.Errorf("A mensagem e o histórico já ocupam o limite de contexto de %d caracteres; não há espaço para os arquivos. "+
+					"Envie menos histórico ou uma mensagem mais curta.", cfg.MaxContextChars)
+			}
+			opts.MaxContextChars = budget
+		}
+
+		fc, err := processFilesAdvanced(ctx, files, fp, opts, progress, logger)
+		if err != nil {
+			return p, err
+		}
+		p.FileContext = fc.Text
+		p.ContextTrimmed = fc.Trimmed
+		p.FileTypes = fc.Types
+		p.Images = fc.Images
+		p.SuspiciousFiles = fc.SuspiciousFiles
+		attachImages(llmClient, p, logger)
+	}
+	if len(p.Tables) > 0 {
+		if p.FileContext != "" {
+			p.FileContext += "\n\n"
+		}
+		description, suspicious := describeTables(p.Tables, cfg.DetectPromptInjection, untrusted, logger)
+		p.FileContext += description
+		p.SuspiciousFiles = append(p.SuspiciousFiles, suspicious...)
+	}
+
+	p.FullPrompt = req.Prompt
+	if p.FileContext != "" {
+		p.FullPrompt = p.FileContext + "\n\n---\n\n**Pergunta do usuário:**\n" + req.Prompt
+	}
+	return p, nil
+}
+
+// generate envia a requisição ao provedor, aplicando o template de resposta quando solicitado.
+// Com template, o streaming é desativado e a resposta validada é entregue em um único trecho.
+func generate(ctx context.Context, llmClient llmclient.LLMClient, prompt preparedPrompt, history []models.Message, tmpl *ResponseTemplate, onChunk func(chunk string) error, logger *zap.Logger) (llmResult, error) {
+	if tmpl == nil {
+		return sendToLLM(ctx, llmClient, prompt, history, onChunk)
+	}
+
+	result, matched, err := sendWithTemplate(ctx, llmClient, prompt, history, tmpl, logger)
+	if err != nil {
+		return result, err
+	}
+	result.TemplateMatched = &matched
+
+	if onChunk != nil && result.Response != "" {
+		if err := onChunk(result.Response); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// generateWithDeadline limita a duração de uma geração em streaming. Ao atingir maxDuration,
+// a chamada ao provedor é cancelada e o texto gerado até ali é retornado como resultado
+// parcial, em vez de erro. Sem nenhum texto gerado, o erro de timeout é mantido.
+func generateWithDeadline(ctx context.Context, maxDuration time.Duration, logger *zap.Logger, gen func(ctx context.Context) (llmResult, error)) (llmResult, error) {
+	if maxDuration <= 0 {
+		return gen(ctx)
+	}
+
+	streamCtx, cancel := context.WithTimeout(ctx, maxDuration)
+	defer cancel()
+
+	result, err := gen(streamCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(streamCtx.Err(), context.DeadlineExceeded) && result.Response != "" {
+		logger.Warn("Streaming interrompido por exceder a duração máxima",
+			zap.Duration("max_duration", maxDuration),
+			zap.Int("response_length", len(result.Response)),
+		)
+		result.TruncatedByTimeout = true
+		return result, nil
+	}
+	return result, err
+}
+
+// sendToLLM escolhe o método do cliente conforme ferramentas, anexos, streaming e citações. Com onChunk
+// definido, provedores sem streaming entregam a resposta completa em um único trecho.
+func sendToLLM(ctx context.Context, llmClient llmclient.LLMClient, prompt preparedPrompt, history []models.Message, onChunk func(chunk string) error) (llmResult, error) {
+	result := llmResult{Model: llmClient.GetModelName()}
+	var err error
+
+	if toolClient, ok := llmClient.(llmclient.ToolClient); ok && len(prompt.Tables) > 0 {
+		result.Response, err = toolClient.SendPromptWithTools(ctx, prompt.FullPrompt, history, 0,
+			[]models.Tool{fileQueryTool(prompt.Tables)}, tableQueryExecutor(prompt.Tables))
+	} else if attClient, ok := llmClient.(llmclient.AttachmentClient); ok && len(prompt.Attachments) > 0 {
+		result.Response, err = attClient.SendPromptWithAttachments(ctx, prompt.FullPrompt, history, 0, prompt.Attachments)
+	} else if streamClient, ok := llmClient.(llmclient.StreamingClient); ok && onChunk != nil {
+		result.Response, err = streamClient.SendPromptStream(ctx, prompt.FullPrompt, history, 0, onChunk)
+		result.Usage = lastUsage(llmClient)
+		return result, err
+	} else if citClient, ok := llmClient.(llmclient.CitationClient); ok {
+		result.Response, result.Citations, err = citClient.SendPromptWithCitations(ctx, prompt.FullPrompt, history, 0)
+	} else {
+		result.Response, err = llmClient.SendPrompt(ctx, prompt.FullPrompt, history, 0)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Usage = lastUsage(llmClient)
+	if rawClient, ok := llmClient.(llmclient.RawResponseClient); ok {
+		result.RawResponse = rawClient.LastRawResponse()
+	}
+
+	if onChunk != nil && result.Response != "" {
+		if err := onChunk(result.Response); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// lastUsage retorna o consumo de tokens registrado pelo cliente, quando disponível.
+func lastUsage(llmClient llmclient.LLMClient) *models.Usage {
+	if usageClient, ok := llmClient.(llmclient.UsageClient); ok {
+		return usageClient.LastUsage()
+	}
+	return nil
+}
+
+// buildChatResponse monta o payload com a resposta completa entregue ao cliente.
+func buildChatResponse(req RequestPayload, prompt preparedPrompt, history []models.Message, result llmResult, cfg HandlerConfig) ResponsePayload {
+	llmResponse := result.Response
+	isMarkdown := detectMarkdown(llmResponse)
+	if req.PlainText {
+		llmResponse = utils.StripMarkdown(llmResponse)
+		isMarkdown = false
+	}
+
+	response := ResponsePayload{
+		Type:       "message",
+		Status:     "completed",
+		Response:   llmResponse,
+		IsMarkdown: isMarkdown,
+		Provider:   req.Provider,
+		Model:      firstNonEmpty(result.Model, req.Model),
+		Citations:  result.Citations,
+
+		TemplateMatched: result.TemplateMatched,
+
+		TruncatedByTimeout: result.TruncatedByTimeout,
+		StoppedByPattern:   result.StoppedByPattern,
+	}
+	if result.Usage != nil {
+		response.PromptTokens = result.Usage.PromptTokens
+		response.CompletionTokens = result.Usage.CompletionTokens
+		response.TotalTokens = result.Usage.TotalTokens
+		response.TokensEstimated = result.Usage.Estimated
+	}
+	response.Metadata = map[string]interface{}{
+		"contextChars":    len(prompt.FullPrompt),
+		"historyMessages": len(history),
+		"historyChars":    historySize(history),
+	}
+	timeout, _ := effectiveRequestTimeout(req, cfg)
+	response.Metadata["effectiveTimeoutSeconds"] = int(timeout / time.Second)
+	if filesTimeout := fileProcessingTimeout(req, cfg); len(req.Files) > 0 && filesTimeout > 0 {
+		response.Metadata["fileProcessingTimeoutSeconds"] = int(filesTimeout / time.Second)
+	}
+	if req.providerNotice != "" {
+		response.Metadata["providerNotice"] = req.providerNotice
+	}
+	if len(result.FallbackAttempts) > 0 {
+		response.Metadata["fallbackAttempts"] = result.FallbackAttempts
+	}
+	if prompt.ContextTrimmed {
+		response.Metadata["contextTrimmed"] = true
+		response.Metadata["contextWarning"] = fmt.Sprintf("Os arquivos excederam o limite de contexto de %d caracteres e foram reduzidos; a resposta pode não considerar o conteúdo completo.", cfg.MaxContextChars)
+	}
+	if len(prompt.SuspiciousFiles) > 0 {
+		response.Metadata["promptInjectionSuspected"] = prompt.SuspiciousFiles
+		response.Metadata["securityWarning"] = "Alguns arquivos contêm trechos que parecem instruções ao assistente; o conteúdo foi enviado como dado não confiável."
+	}
+	if cfg.ReturnPromptDebug || req.DebugPrompt {
+		response.Metadata["promptDebug"] = buildPromptDebug(prompt.FullPrompt, prompt.FileContext, history)
+	}
+	if (cfg.ReturnRawResponse || req.DebugRawResponse) && len(result.RawResponse) > 0 {
+		response.Metadata["rawResponse"] = utils.RedactBody(result.RawResponse, maxRawResponseChars)
+	}
+	return response
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/webchatcomllm/config"
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/utils"
+)
+
+// HandlerConfig reúne as opções configuráveis dos handlers de chat.
+type HandlerConfig struct {
+	// ReturnPromptDebug inclui o prompt montado (redigido) nos metadados da resposta.
+	ReturnPromptDebug bool
+	// ReturnRawResponse inclui o corpo bruto (redigido) da resposta do provedor nos metadados.
+	ReturnRawResponse bool
+
+	// MaxMessageSize é o tamanho máximo de uma mensagem do WebSocket; mensagens maiores, até
+	// HardMessageSizeLimit, são recusadas com um erro explicativo sem encerrar a conexão. Acima do
+	// limite rígido a conexão é encerrada com o código 1009. Cada conexão pode manter em memória
+	// uma mensagem de até HardMessageSizeLimit enquanto a lê.
+	MaxMessageSize       int
+	HardMessageSizeLimit int
+
+	// SendTimeout é o tempo máximo de espera para enfileirar uma mensagem de saída.
+	SendTimeout time.Duration
+
+	// NativeDocuments envia PDFs diretamente aos provedores que os aceitam, em vez de extrair o texto localmente.
+	NativeDocuments bool
+
+	// DetectPromptInjection procura padrões de injeção de instruções no conteúdo dos arquivos e
+	// isola os arquivos suspeitos em delimitadores de conteúdo não confiável.
+	DetectPromptInjection bool
+
+	// FileTools disponibiliza arquivos CSV/JSON como a ferramenta query_file, consultada pelo
+	// modelo, em vez de enviar o conteúdo inteiro no contexto.
+	FileTools bool
+
+	// MaxListedFailedFiles limita os arquivos com falha listados individualmente no contexto; os
+	// demais são resumidos em uma linha. 0 lista todos.
+	MaxListedFailedFiles int
+
+	// MaxImagesPerRequest limita as imagens por requisição; 0 usa o limite do modelo no catálogo.
+	MaxImagesPerRequest int
+
+	// ForceResponseLanguage instrui o modelo a responder sempre neste idioma (ex.: "português do Brasil").
+	ForceResponseLanguage string
+
+	// SystemPromptPrefix abre o prompt de sistema de todas as requisições.
+	SystemPromptPrefix string
+	// ProviderSystemPrompts são os prompts de sistema padrão por provedor (<PROVEDOR>_SYSTEM_PROMPT),
+	// usados quando a requisição não traz um prompt de sistema próprio.
+	ProviderSystemPrompts map[string]string
+
+	// SummaryMemoryEnabled condensa as mensagens mais antigas em um resumo quando o histórico
+	// passa de SummaryMemoryThreshold mensagens, mantendo as SummaryMemoryKeepRecent mais recentes.
+	SummaryMemoryEnabled    bool
+	SummaryMemoryThreshold  int
+	SummaryMemoryKeepRecent int
+	// SummaryProvider/SummaryModel definem o modelo (idealmente barato) usado nos resumos.
+	// Vazio usa o mesmo provedor e modelo da requisição.
+	SummaryProvider string
+	SummaryModel    string
+
+	// MaxConnections limita as conexões WebSocket ativas no servidor; 0 desativa o limite.
+	MaxConnections int
+	// ConnectionOverflowMode define o comportamento ao atingir o limite: "reject" ou "queue".
+	ConnectionOverflowMode string
+	// ConnectionRetryAfter é sugerido no cabeçalho Retry-After das conexões recusadas.
+	ConnectionRetryAfter time.Duration
+	// ConnectionQueueTimeout é o tempo máximo de permanência na sala de espera.
+	ConnectionQueueTimeout time.Duration
+
+	// DefaultProvider é usado quando a requisição não especifica um provedor.
+	DefaultProvider string
+
+	// RecordRequestsDir grava cada requisição e sua resposta (redigidas) como fixtures JSON
+	// neste diretório, para reprodução com cmd/replay. Vazio desativa a gravação.
+	RecordRequestsDir string
+	// RecordGzipThreshold comprime (gzip + base64) os prompts, arquivos e respostas gravados com
+	// este tamanho em bytes ou mais; 0 desativa.
+	RecordGzipThreshold int
+
+	// FileProcessing contém os limites repassados ao processador de arquivos.
+	FileProcessing utils.FileProcessorConfig
+
+	// MaxContextChars limita o tamanho do prompt final (pergunta, histórico e arquivos) enviado ao
+	// provedor; acima dele o conteúdo dos arquivos é reduzido. 0 desativa o limite.
+	MaxContextChars int
+
+	// DebugTimings inclui em metadata.timings a duração de cada fase da requisição (renovação do
+	// token, processamento de arquivos, TTFB e chamada ao provedor) e o total, em milissegundos.
+	DebugTimings bool
+
+	// SlowRequestThreshold marca como lentas as requisições concluídas acima deste tempo; 0 desativa.
+	SlowRequestThreshold time.Duration
+
+	// HistoryMaxMessages e HistoryMaxChars limitam o histórico usado por conversa; as mensagens
+	// mais antigas são descartadas (ou resumidas antes, com a memória de resumo). 0 desativa.
+	HistoryMaxMessages int
+	HistoryMaxChars    int
+
+	// MaxStreamDuration interrompe respostas em streaming que excedam este tempo, entregando o
+	// texto gerado até ali como resposta parcial. 0 desativa.
+	MaxStreamDuration time.Duration
+
+	// LLMRequestTimeout é o timeout padrão da chamada ao LLM. A requisição pode pedir outro com
+	// timeoutSeconds, limitado a LLMMaxRequestTimeout (0 não limita).
+	LLMRequestTimeout    time.Duration
+	LLMMaxRequestTimeout time.Duration
+
+	// FileProcessingTimeout limita o tempo de decodificação e processamento dos arquivos de uma
+	// requisição, independente do timeout do LLM. 0 desativa.
+	FileProcessingTimeout time.Duration
+
+	// FileMetadataFormat define como os metadados dos arquivos entram no contexto: "markdown"
+	// (padrão), "json" ou "both". A requisição pode sobrescrevê-lo com metadataFormat.
+	FileMetadataFormat string
+
+	// MaxConcurrentExtractions limita as extrações de arquivo simultâneas por conexão WebSocket;
+	// os arquivos excedentes aguardam na fila. 0 desativa.
+	MaxConcurrentExtractions int
+	// MaxHTTPExtractions limita as extrações de arquivo simultâneas de todas as requisições de
+	// /api/chat e /api/chat/stream juntas. 0 desativa.
+	MaxHTTPExtractions int
+
+	// MessagesPerSecond limita as mensagens aceitas por conexão WebSocket (com rajada de igual
+	// tamanho); 0 desativa. RateLimitCloseAfter encerra a conexão após esse número de
+	// mensagens recusadas seguidas; 0 nunca encerra.
+	MessagesPerSecond   int
+	RateLimitCloseAfter int
+
+	// SessionResumeGrace é o tempo que uma geração com sessionId aguarda a reconexão do cliente
+	// após a queda da conexão; depois disso ela é cancelada. 0 cancela na desconexão.
+	SessionResumeGrace time.Duration
+
+	// ContentRoutes são regras "tipo=PROVEDOR[:modelo]" que escolhem o destino pelo tipo de
+	// conteúdo predominante (image, code, document, data, text ou chat).
+	ContentRoutes []string
+
+	// MaintenanceCooldown é o tempo sem chamadas a um provedor que anunciou manutenção.
+	// MaintenanceFallbackProvider/Model atendem as requisições nesse período; vazio desativa.
+	MaintenanceCooldown         time.Duration
+	MaintenanceFallbackProvider string
+	MaintenanceFallbackModel    string
+
+	// ReconnectHints envia {"type": "reconnect"} antes de o servidor encerrar a conexão
+	// (inatividade ou desligamento), sugerindo ReconnectBackoff de espera antes de reconectar.
+	ReconnectHints   bool
+	ReconnectBackoff time.Duration
+
+	// VisionProvider/VisionModel atendem as requisições com imagens quando o modelo escolhido
+	// não interpreta imagens (conforme o catálogo). Vazio desativa a troca automática.
+	VisionProvider string
+	VisionModel    string
+
+	// DeadLetterLog é o arquivo (JSON por linha) onde mensagens descartadas sem entrega são
+	// registradas; vazio mantém apenas a métrica e o log da aplicação.
+	DeadLetterLog string
+
+	// MaxQueuedMessages limita a fila de reenvio por conexão; ao exceder, a mais antiga é descartada.
+	MaxQueuedMessages int
+
+	// RequestProcessors lista, em ordem, os processadores registrados aplicados a cada requisição.
+	RequestProcessors []string
+}
+
+// DefaultHandlerConfig retorna a configuração padrão dos handlers.
+func DefaultHandlerConfig() HandlerConfig {
+	return HandlerConfig{
+		ReturnPromptDebug: false,
+		ReturnRawResponse: false,
+		SendTimeout:       utils.DefaultConnectionConfig().SendTimeout,
+
+		MaxMessageSize:       defaultMaxMessageSize,
+		HardMessageSizeLimit: defaultHardMessageSizeLimit,
+
+		SummaryMemoryThreshold:  20,
+		SummaryMemoryKeepRecent: 10,
+
+		ConnectionOverflowMode: OverflowReject,
+		ConnectionRetryAfter:   30 * time.Second,
+		ConnectionQueueTimeout: 2 * time.Minute,
+
+		MaxQueuedMessages:        100,
+		LLMRequestTimeout:        defaultLLMRequestTimeout,
+		LLMMaxRequestTimeout:     15 * time.Minute,
+		FileProcessingTimeout:    60 * time.Second,
+		MaxConcurrentExtractions: 2,
+		MaxHTTPExtractions:       4,
+		FileMetadataFormat:       metadataMarkdown,
+		MessagesPerSecond:        10,
+		SessionResumeGrace:       30 * time.Second,
+		MaintenanceCooldown:      5 * time.Minute,
+		ReconnectHints:           true,
+		ReconnectBackoff:         2 * time.Second,
+		RecordGzipThreshold:      64 * 1024,
+		MaxListedFailedFiles:     10,
+
+		FileProcessing: utils.DefaultFileProcessorConfig(),
+	}
+}
+
+// LoadHandlerConfig carrega a configuração a partir das variáveis de ambiente.
+func LoadHandlerConfig() HandlerConfig {
+	cfg := DefaultHandlerConfig()
+	cfg.ReturnPromptDebug = config.GetEnvBool("RETURN_PROMPT_DEBUG", cfg.ReturnPromptDebug)
+	cfg.ReturnRawResponse = config.GetEnvBool("RETURN_RAW_RESPONSE", cfg.ReturnRawResponse)
+	cfg.SendTimeout = config.GetEnvDuration("WS_SEND_TIMEOUT", cfg.SendTimeout)
+	cfg.MaxMessageSize = config.GetEnvInt("WS_MAX_MESSAGE_SIZE", cfg.MaxMessageSize)
+	cfg.HardMessageSizeLimit = config.GetEnvInt("WS_HARD_MESSAGE_SIZE", cfg.HardMessageSizeLimit)
+	cfg.NativeDocuments = config.GetEnvBool("NATIVE_DOCUMENTS", cfg.NativeDocuments)
+	cfg.FileTools = config.GetEnvBool("FILE_TOOLS", cfg.FileTools)
+	cfg.DetectPromptInjection = config.GetEnvBool("DETECT_PROMPT_INJECTION", cfg.DetectPromptInjection)
+	cfg.MaxListedFailedFiles = config.GetEnvInt("MAX_LISTED_FAILED_FILES", cfg.MaxListedFailedFiles)
+	cfg.MaxImagesPerRequest = config.GetEnvInt("MAX_IMAGES_PER_REQUEST", cfg.MaxImagesPerRequest)
+	cfg.ForceResponseLanguage = config.GetEnvString("FORCE_RESPONSE_LANGUAGE", cfg.ForceResponseLanguage)
+	cfg.SystemPromptPrefix = config.GetEnvString("SYSTEM_PROMPT_PREFIX", cfg.SystemPromptPrefix)
+	cfg.ProviderSystemPrompts = make(map[string]string)
+	for _, provider := range catalog.Providers() {
+		if prompt := config.GetEnvString(provider+"_SYSTEM_PROMPT", ""); prompt != "" {
+			cfg.ProviderSystemPrompts[provider] = prompt
+		}
+	}
+	cfg.SummaryMemoryEnabled = config.GetEnvBool("SUMMARY_MEMORY_ENABLED", cfg.SummaryMemoryEnabled)
+	cfg.SummaryMemoryThreshold = config.GetEnvInt("SUMMARY_MEMORY_THRESHOLD", cfg.SummaryMemoryThreshold)
+	cfg.SummaryMemoryKeepRecent = config.GetEnvInt("SUMMARY_MEMORY_KEEP_RECENT", cfg.SummaryMemoryKeepRecent)
+	cfg.SummaryProvider = config.GetEnvString("SUMMARY_PROVIDER", cfg.SummaryProvider)
+	cfg.SummaryModel = config.GetEnvString("SUMMARY_MODEL", cfg.SummaryModel)
+	cfg.MaxConnections = config.GetEnvInt("MAX_CONNECTIONS", cfg.MaxConnections)
+	cfg.ConnectionOverflowMode = strings.ToLower(config.GetEnvString("MAX_CONNECTIONS_MODE", cfg.ConnectionOverflowMode))
+	cfg.ConnectionRetryAfter = config.GetEnvDuration("MAX_CONNECTIONS_RETRY_AFTER", cfg.ConnectionRetryAfter)
+	cfg.ConnectionQueueTimeout = config.GetEnvDuration("MAX_CONNECTIONS_QUEUE_TIMEOUT", cfg.ConnectionQueueTimeout)
+	cfg.DefaultProvider = strings.ToUpper(strings.TrimSpace(config.GetEnvString("DEFAULT_PROVIDER", cfg.DefaultProvider)))
+	cfg.RecordRequestsDir = config.GetEnvString("RECORD_REQUESTS", cfg.RecordRequestsDir)
+	cfg.RecordGzipThreshold = config.GetEnvInt("RECORD_GZIP_THRESHOLD", cfg.RecordGzipThreshold)
+	cfg.FileProcessing.LogTailLines = config.GetEnvInt("LOG_TAIL_LINES", cfg.FileProcessing.LogTailLines)
+	cfg.FileProcessing.LogMaxHighlights = config.GetEnvInt("LOG_MAX_HIGHLIGHTS", cfg.FileProcessing.LogMaxHighlights)
+	cfg.FileProcessing.MaxCharsPerFile = config.GetEnvInt("MAX_CHARS_PER_FILE", cfg.FileProcessing.MaxCharsPerFile)
+	cfg.FileProcessing.GeneratedFilesMode = strings.ToLower(config.GetEnvString("GENERATED_FILES_MODE", cfg.FileProcessing.GeneratedFilesMode))
+	cfg.FileProcessing.MinifiedLineLength = config.GetEnvInt("MINIFIED_LINE_LENGTH", cfg.FileProcessing.MinifiedLineLength)
+	cfg.FileProcessing.ExtractEmbeddedImages = config.GetEnvBool("EXTRACT_EMBEDDED_IMAGES", cfg.FileProcessing.ExtractEmbeddedImages)
+	cfg.FileProcessing.MaxEmbeddedImages = config.GetEnvInt("MAX_EMBEDDED_IMAGES", cfg.FileProcessing.MaxEmbeddedImages)
+	cfg.FileProcessing.NormalizeText = config.GetEnvBool("NORMALIZE_TEXT", cfg.FileProcessing.NormalizeText)
+	cfg.FileProcessing.MaxBlankLines = config.GetEnvInt("MAX_BLANK_LINES", cfg.FileProcessing.MaxBlankLines)
+	cfg.FileProcessing.XlsxMaxSheets = config.GetEnvInt("XLSX_MAX_SHEETS", cfg.FileProcessing.XlsxMaxSheets)
+	cfg.FileProcessing.XlsxMaxCells = config.GetEnvInt("XLSX_MAX_CELLS", cfg.FileProcessing.XlsxMaxCells)
+	cfg.FileProcessing.EnableOCR = config.GetEnvBool("ENABLE_OCR", cfg.FileProcessing.EnableOCR)
+	cfg.FileProcessing.OCRLanguages = config.GetEnvString("OCR_LANGUAGES", cfg.FileProcessing.OCRLanguages)
+	cfg.MaxContextChars = config.GetEnvInt("MAX_CONTEXT_CHARS", cfg.MaxContextChars)
+	cfg.RequestProcessors = config.GetEnvList("REQUEST_PROCESSORS", cfg.RequestProcessors)
+	cfg.VisionProvider = strings.ToUpper(config.GetEnvString("VISION_PROVIDER", cfg.VisionProvider))
+	cfg.VisionModel = config.GetEnvString("VISION_MODEL", cfg.VisionModel)
+	cfg.MaxStreamDuration = config.GetEnvDuration("MAX_STREAM_DURATION", cfg.MaxStreamDuration)
+	cfg.LLMRequestTimeout = config.GetEnvDuration("LLM_REQUEST_TIMEOUT", cfg.LLMRequestTimeout)
+	cfg.LLMMaxRequestTimeout = config.GetEnvDuration("LLM_MAX_REQUEST_TIMEOUT", config.GetEnvDuration("MAX_REQUEST_TIMEOUT", cfg.LLMMaxRequestTimeout))
+	cfg.FileProcessingTimeout = config.GetEnvDuration("FILE_PROCESSING_TIMEOUT", cfg.FileProcessingTimeout)
+	cfg.MaxConcurrentExtractions = config.GetEnvInt("MAX_CONCURRENT_EXTRACTIONS", cfg.MaxConcurrentExtractions)
+	cfg.MaxHTTPExtractions = config.GetEnvInt("MAX_HTTP_EXTRACTIONS", cfg.MaxHTTPExtractions)
+	cfg.FileMetadataFormat = strings.ToLower(config.GetEnvString("FILE_METADATA_FORMAT", cfg.FileMetadataFormat))
+	cfg.MessagesPerSecond = config.GetEnvInt("WS_MESSAGES_PER_SEC", cfg.MessagesPerSecond)
+	cfg.RateLimitCloseAfter = config.GetEnvInt("WS_RATE_LIMIT_CLOSE_AFTER", cfg.RateLimitCloseAfter)
+	cfg.SessionResumeGrace = config.GetEnvDuration("SESSION_RESUME_GRACE", cfg.SessionResumeGrace)
+	cfg.ContentRoutes = config.GetEnvList("CONTENT_ROUTES", cfg.ContentRoutes)
+	cfg.MaintenanceCooldown = config.GetEnvDuration("PROVIDER_MAINTENANCE_COOLDOWN", cfg.MaintenanceCooldown)
+	cfg.MaintenanceFallbackProvider = strings.ToUpper(config.GetEnvString("MAINTENANCE_FALLBACK_PROVIDER", cfg.MaintenanceFallbackProvider))
+	cfg.MaintenanceFallbackModel = config.GetEnvString("MAINTENANCE_FALLBACK_MODEL", cfg.MaintenanceFallbackModel)
+	cfg.ReconnectHints = config.GetEnvBool("RECONNECT_HINTS", cfg.ReconnectHints)
+	cfg.ReconnectBackoff = config.GetEnvDuration("RECONNECT_BACKOFF", cfg.ReconnectBackoff)
+	cfg.DeadLetterLog = config.GetEnvString("DEAD_LETTER_LOG", cfg.DeadLetterLog)
+	cfg.MaxQueuedMessages = config.GetEnvInt("MAX_QUEUED_MESSAGES", cfg.MaxQueuedMessages)
+	cfg.HistoryMaxMessages = config.GetEnvInt("HISTORY_MAX_MESSAGES", cfg.HistoryMaxMessages)
+	cfg.HistoryMaxChars = config.GetEnvInt("HISTORY_MAX_CHARS", cfg.HistoryMaxChars)
+	cfg.DebugTimings = config.GetEnvBool("DEBUG_TIMINGS", cfg.DebugTimings)
+	cfg.SlowRequestThreshold = time.Duration(config.GetEnvInt("SLOW_REQUEST_MS", int(cfg.SlowRequestThreshold/time.Millisecond))) * time.Millisecond
+	return cfg
+}
+
+// Validate verifica as opções que não têm fallback seguro, para que um valor inválido impeça a
+// inicialização em vez de falhar em cada requisição.
+func (cfg HandlerConfig) Validate() error {
+	if _, err := resolveMetadataFormat("", cfg.FileMetadataFormat); err != nil {
+		return fmt.Errorf("FILE_METADATA_FORMAT: %w", err)
+	}
+	// Com zero ou menos, a espera expira de imediato e os envios vão para a fila de reenvio ao acaso
+	if cfg.SendTimeout <= 0 {
+		return fmt.Errorf("WS_SEND_TIMEOUT deve ser maior que zero (recebido %s)", cfg.SendTimeout)
+	}
+	return nil
+}
+
+// resolveProvider aplica o provedor padrão quando nenhum foi informado.
+// O segundo retorno indica se o padrão foi aplicado.
+func (cfg HandlerConfig) resolveProvider(provider string) (string, bool) {
+	if provider == "" && cfg.DefaultProvider != "" {
+		return cfg.DefaultProvider, true
+	}
+	return provider, false
+}
+
+// messageSizeLimits retorna o limite de tamanho das mensagens do WebSocket e o limite rígido,
+// usando os padrões para valores inválidos. O limite rígido nunca fica abaixo do limite comum.
+func (cfg HandlerConfig) messageSizeLimits() (int, int) {
+	maxSize, hardLimit := cfg.MaxMessageSize, cfg.HardMessageSizeLimit
+	if maxSize <= 0 {
+		maxSize = defaultMaxMessageSize
+	}
+	if hardLimit < maxSize {
+		hardLimit = maxSize
+	}
+	return maxSize, hardLimit
+}
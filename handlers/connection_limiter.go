@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// OverflowReject recusa novas conexões com 503 quando o limite é atingido.
+	OverflowReject = "reject"
+	// OverflowQueue aceita a conexão e a mantém em uma sala de espera até liberar uma vaga.
+	OverflowQueue = "queue"
+
+	waitingRoomUpdateInterval = 10 * time.Second
+)
+
+// connectionLimiter controla o número global de conexões WebSocket ativas.
+type connectionLimiter struct {
+	slots   chan struct{}
+	active  atomic.Int64
+	waiting atomic.Int64
+	max     int
+}
+
+// newConnectionLimiter cria o limitador; max <= 0 desativa o limite.
+func newConnectionLimiter(max int) *connectionLimiter {
+	l := &connectionLimiter{max: max}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+	return l
+}
+
+// tryAcquire ocupa uma vaga sem bloquear.
+func (l *connectionLimiter) tryAcquire() bool {
+	if l.slots == nil {
+		l.active.Add(1)
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		l.active.Add(1)
+		return true
+	default:
+		return false
+	}
+}
+
+// release libera a vaga ocupada por uma conexão encerrada.
+func (l *connectionLimiter) release() {
+	l.active.Add(-1)
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// Active retorna o número de conexões ativas.
+func (l *connectionLimiter) Active() int64 {
+	return l.active.Load()
+}
+
+// rejectOverflow responde 503 com Retry-After antes do upgrade.
+func (l *connectionLimiter) rejectOverflow(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Servidor com capacidade máxima de conexões. Tente novamente em instantes.",
+	})
+}
+
+// waitForSlot mantém a conexão já estabelecida na sala de espera, enviando atualizações
+// periódicas de status, até obter uma vaga, expirar o tempo ou o cliente desconectar.
+func (l *connectionLimiter) waitForSlot(conn *websocket.Conn, timeout time.Duration, logger *zap.Logger) bool {
+	position := l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+
+	notify := func(status, message string) error {
+		data, _ := json.Marshal(ResponsePayload{
+			Type:     "status",
+			Status:   status,
+			Response: message,
+		})
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+
+	waitingMsg := fmt.Sprintf("Servidor cheio. Você está na fila de espera (posição aproximada: %d).", position)
+	if err := notify("waiting", waitingMsg); err != nil {
+		return false
+	}
+
+	logger.Info("Conexão na sala de espera",
+		zap.Int64("posicao", position),
+		zap.Int64("ativas", l.Active()),
+	)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(waitingRoomUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case l.slots <- struct{}{}:
+			l.active.Add(1)
+			if err := notify("ready", "Conexão liberada. Você já pode enviar mensagens."); err != nil {
+				l.release()
+				return false
+			}
+			return true
+		case <-ticker.C:
+			// A escrita periódica também detecta clientes que desistiram da fila
+			if err := notify("waiting", "Aguardando uma vaga no servidor..."); err != nil {
+				return false
+			}
+		case <-deadline.C:
+			notify("error", "Tempo de espera por uma vaga esgotado. Tente novamente mais tarde.")
+			return false
+		}
+	}
+}
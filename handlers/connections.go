@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/webchatcomllm/store"
+	"go.uber.org/zap"
+)
+
+// connectionInfo descreve uma conexão WebSocket aberta e o tamanho da sessão que ela usa.
+type connectionInfo struct {
+	ClientID        string    `json:"clientId"`
+	RemoteAddr      string    `json:"remoteAddr"`
+	ConnectedAt     time.Time `json:"connectedAt"`
+	SessionID       string    `json:"sessionId,omitempty"`
+	SessionMessages int       `json:"sessionMessages"`
+	SessionBytes    int       `json:"sessionBytes"`
+}
+
+// ConnectionsHandler cria o handler de GET /api/admin/connections, que lista as conexões
+// WebSocket abertas, da mais antiga para a mais recente, com o número de mensagens e o tamanho
+// gravados da última sessão usada por cada uma.
+func ConnectionsHandler(conversations store.ConversationStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		connections := make([]connectionInfo, 0)
+		for _, c := range liveClients.snapshot() {
+			c.mu.Lock()
+			info := connectionInfo{
+				ClientID:    c.id,
+				RemoteAddr:  c.conn.RemoteAddr().String(),
+				ConnectedAt: c.connectedAt,
+				SessionID:   c.sessionID,
+			}
+			c.mu.Unlock()
+
+			if info.SessionID != "" && conversations != nil {
+				msgs, err := conversations.Load(ctx, info.SessionID)
+				if err == nil {
+					info.SessionMessages, info.SessionBytes = store.Size(msgs)
+				}
+			}
+			connections = append(connections, info)
+		}
+		sort.Slice(connections, func(i, j int) bool {
+			return connections[i].ConnectedAt.Before(connections[j].ConnectedAt)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(connections); err != nil {
+			logger.Error("Erro ao enviar lista de conexões", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/webchatcomllm/llm/catalog"
+	llmclient "github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// Tipos de conteúdo usados nas regras de CONTENT_ROUTES.
+const (
+	contentImage    = "image"
+	contentCode     = "code"
+	contentDocument = "document"
+	contentData     = "data"
+	contentText     = "text"
+	contentChat     = "chat" // mensagem sem arquivos
+)
+
+// contentPriority desempata tipos com a mesma quantidade de arquivos.
+var contentPriority = []string{contentImage, contentCode, contentDocument, contentData, contentText}
+
+// contentKinds agrupa os tipos de arquivo nos tipos de conteúdo das regras.
+var contentKinds = map[utils.FileType]string{
+	utils.FileTypeImage:    contentImage,
+	utils.FileTypeCode:     contentCode,
+	utils.FileTypeDiff:     contentCode,
+	utils.FileTypePDF:      contentDocument,
+	utils.FileTypeDocx:     contentDocument,
+	utils.FileTypeXlsx:     contentDocument,
+	utils.FileTypeJSON:     contentData,
+	utils.FileTypeYAML:     contentData,
+	utils.FileTypeXML:      contentData,
+	utils.FileTypeCSV:      contentData,
+	utils.FileTypeText:     contentText,
+	utils.FileTypeMarkdown: contentText,
+	utils.FileTypeLog:      contentText,
+}
+
+// contentRoute é o destino de um tipo de conteúdo.
+type contentRoute struct {
+	Provider string
+	Model    string
+}
+
+// buildContentRoutes interpreta as regras "tipo=PROVEDOR[:modelo]" de CONTENT_ROUTES. Regras
+// malformadas, com tipo desconhecido ou que mandam imagens a um modelo sem visão são ignoradas.
+func buildContentRoutes(entries []string, logger *zap.Logger) map[string]contentRoute {
+	routes := make(map[string]contentRoute)
+	for _, entry := range entries {
+		kind, target, ok := strings.Cut(entry, "=")
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		provider, model, _ := strings.Cut(strings.TrimSpace(target), ":")
+		provider = catalog.ResolveProvider(provider)
+		if !ok || provider == "" || !validContentKind(kind) {
+			logger.Warn("Regra de roteamento por conteúdo inválida ignorada", zap.String("rule", entry))
+			continue
+		}
+		if kind == contentImage && !catalog.SupportsVision(provider, model) {
+			logger.Warn("Regra de roteamento de imagens aponta para modelo sem visão, ignorada", zap.String("rule", entry))
+			continue
+		}
+		routes[kind] = contentRoute{Provider: provider, Model: catalog.ResolveModel(provider, strings.TrimSpace(model))}
+	}
+	if len(routes) > 0 {
+		logger.Info("Roteamento por conteúdo configurado", zap.Strings("rules", entries))
+	}
+	return routes
+}
+
+func validContentKind(kind string) bool {
+	if kind == contentChat {
+		return true
+	}
+	for _, k := range contentPriority {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// dominantContent retorna o tipo de conteúdo predominante da requisição: o tipo com mais
+// arquivos no contexto ou, sem arquivos, "code" para mensagens com blocos de código e "chat"
+// para as demais.
+func dominantContent(prompt preparedPrompt, userPrompt string) string {
+	counts := make(map[string]int)
+	for fileType, n := range prompt.FileTypes {
+		if kind, ok := contentKinds[fileType]; ok {
+			counts[kind] += n
+		}
+	}
+
+	best, bestCount := "", 0
+	for _, kind := range contentPriority {
+		if counts[kind] > bestCount {
+			best, bestCount = kind, counts[kind]
+		}
+	}
+	if best != "" {
+		return best
+	}
+	if strings.Contains(userPrompt, "```") {
+		return contentCode
+	}
+	return contentChat
+}
+
+// applyContentRouting troca o provedor/modelo da requisição conforme a regra do tipo de
+// conteúdo predominante, avaliada após o processamento dos arquivos. Retorna o cliente a usar
+// e a decisão para os metadados da resposta (nil quando nenhuma regra se aplica).
+func (c *Client) applyContentRouting(req *RequestPayload, prompt preparedPrompt, llmClient llmclient.LLMClient) (llmclient.LLMClient, map[string]interface{}) {
+	if len(c.contentRoutes) == 0 {
+		return llmClient, nil
+	}
+	kind := dominantContent(prompt, req.Prompt)
+	route, ok := c.contentRoutes[kind]
+	if !ok || (route.Provider == catalog.ResolveProvider(req.Provider) && strings.EqualFold(route.Model, req.Model)) {
+		return llmClient, nil
+	}
+	// Documentos nativos já foram separados para o cliente original
+	if len(prompt.Attachments) > 0 {
+		c.logger.Debug("Roteamento por conteúdo ignorado: requisição com documentos nativos", zap.String("content", kind))
+		return llmClient, nil
+	}
+	// Assim como os arquivos consultados por ferramenta
+	if len(prompt.Tables) > 0 {
+		c.logger.Debug("Roteamento por conteúdo ignorado: requisição com arquivos consultáveis", zap.String("content", kind))
+		return llmClient, nil
+	}
+
+	routed, err := c.llmManager.GetClient(route.Provider, route.Model)
+	if err != nil {
+		c.logger.Warn("Roteamento por conteúdo ignorado: destino indisponível",
+			zap.String("content", kind),
+			zap.String("provider", route.Provider),
+			zap.String("model", route.Model),
+			zap.Error(err),
+		)
+		return llmClient, nil
+	}
+
+	decision := map[string]interface{}{
+		"content":      kind,
+		"provider":     route.Provider,
+		"model":        route.Model,
+		"fromProvider": req.Provider,
+		"fromModel":    req.Model,
+	}
+	c.logger.Info("Requisição roteada pelo tipo de conteúdo",
+		zap.String("content", kind),
+		zap.String("from_provider", req.Provider),
+		zap.String("to_provider", route.Provider),
+		zap.String("to_model", route.Model),
+	)
+	req.Provider = route.Provider
+	req.Model = route.Model
+	applyProviderParams(routed, *req, c.logger)
+	attachImages(routed, prompt, c.logger)
+	return routed, decision
+}
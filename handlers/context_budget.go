@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// contextOverheadPerFile estima os caracteres de cabeçalho, metadados e cercas que cada
+// arquivo acrescenta ao contexto além do próprio conteúdo.
+const contextOverheadPerFile = 500
+
+// fitContextBudget reduz o conteúdo dos arquivos para caber em budget caracteres. Imagens não
+// podem ser cortadas: são mantidas na ordem enquanto couberem e as demais são descartadas.
+// O espaço restante é dividido igualmente entre os arquivos de texto, truncando os maiores.
+// Retorna os arquivos mantidos, as descrições dos descartados e se houve alguma redução.
+func fitContextBudget(files []utils.ProcessedFile, budget int, fp *utils.FileProcessor, logger *zap.Logger) ([]utils.ProcessedFile, []string, bool) {
+	total := 0
+	for _, pf := range files {
+		total += len(pf.Content) + contextOverheadPerFile
+	}
+	if budget <= 0 || total <= budget {
+		return files, nil, false
+	}
+
+	remaining := budget
+	var dropped []string
+	kept := make([]utils.ProcessedFile, 0, len(files))
+	var textIdx []int
+
+	for _, pf := range files {
+		if !pf.IsBase64 {
+			textIdx = append(textIdx, len(kept))
+			kept = append(kept, pf)
+			remaining -= contextOverheadPerFile
+			continue
+		}
+		size := len(pf.Content) + contextOverheadPerFile
+		if size > remaining {
+			dropped = append(dropped, fmt.Sprintf("%s (excede o limite de contexto)", pf.Name))
+			continue
+		}
+		kept = append(kept, pf)
+		remaining -= size
+	}
+
+	// Os menores arquivos são atendidos primeiro; a sobra da cota deles fica para os maiores
+	sort.Slice(textIdx, func(a, b int) bool {
+		return len(kept[textIdx[a]].Content) < len(kept[textIdx[b]].Content)
+	})
+	for i, idx := range textIdx {
+		share := remaining / (len(textIdx) - i)
+		if share < 0 {
+			share = 0
+		}
+		pf := &kept[idx]
+		if len(pf.Content) > share {
+			if share == 0 {
+				pf.Content = "[conteúdo omitido: limite de contexto atingido]"
+				pf.Metadata["truncated"] = true
+			} else {
+				fp.TruncateContentTo(pf, share)
+			}
+		}
+		remaining -= len(pf.Content)
+	}
+
+	logger.Warn("Contexto dos arquivos reduzido para respeitar o limite",
+		zap.Int("original_chars", total),
+		zap.Int("budget", budget),
+		zap.Int("dropped", len(dropped)),
+	)
+	return kept, dropped, true
+}
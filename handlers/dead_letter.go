@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/webchatcomllm/metrics"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// Motivos de descarte registrados no dead-letter log
+const (
+	deadLetterConnectionClosed = "connection_closed"
+	deadLetterQueueFull        = "queue_full"
+	deadLetterSendFailed       = "send_failed"
+)
+
+// deadLetterPreviewChars limita o trecho da mensagem gravado em cada registro
+const deadLetterPreviewChars = 300
+
+var deadLettersTotal = metrics.Default.Counter("ws_dead_letters_total", "Mensagens descartadas sem entrega ao cliente")
+
+// deadLetter é um registro de mensagem descartada.
+type deadLetter struct {
+	Time         time.Time `json:"time"`
+	ConnectionID string    `json:"connectionId"`
+	Reason       string    `json:"reason"`
+	Error        string    `json:"error,omitempty"`
+	Type         string    `json:"type,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	Size         int       `json:"size"`
+	Preview      string    `json:"preview"`
+}
+
+// deadLetterLog contabiliza mensagens que não puderam ser entregues e, quando DEAD_LETTER_LOG
+// está definido, grava cada uma como uma linha JSON no arquivo. O conteúdo passa pelo
+// redator de PII e é truncado antes de ser gravado.
+type deadLetterLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	logger *zap.Logger
+}
+
+// newDeadLetterLog cria o registro; sem path (ou se o arquivo não puder ser aberto) apenas a
+// métrica e o log da aplicação são usados.
+func newDeadLetterLog(path string, logger *zap.Logger) *deadLetterLog {
+	d := &deadLetterLog{logger: logger}
+	if path == "" {
+		return d
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		logger.Error("Não foi possível abrir o dead-letter log, registrando apenas a métrica",
+			zap.String("path", path), zap.Error(err))
+		return d
+	}
+	logger.Info("Dead-letter log ativado", zap.String("path", path))
+	d.file = f
+	return d
+}
+
+// record registra o descarte de uma mensagem já serializada.
+func (d *deadLetterLog) record(connID, reason string, data []byte, cause error) {
+	deadLettersTotal.Inc(metrics.Labels{"reason": reason})
+
+	entry := deadLetter{
+		Time:         time.Now(),
+		ConnectionID: connID,
+		Reason:       reason,
+		Size:         len(data),
+	}
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+	var head struct {
+		Type   string `json:"type"`
+		Status string `json:"status"`
+	}
+	if json.Unmarshal(data, &head) == nil {
+		entry.Type = head.Type
+		entry.Status = head.Status
+	}
+	preview := []rune(string(data))
+	if len(preview) > deadLetterPreviewChars {
+		preview = preview[:deadLetterPreviewChars]
+	}
+	entry.Preview = utils.RedactPII(string(preview))
+
+	d.logger.Warn("Mensagem descartada sem entrega",
+		zap.String("client_id", connID),
+		zap.String("reason", reason),
+		zap.String("type", entry.Type),
+		zap.Int("size", entry.Size),
+		zap.NamedError("cause", cause),
+	)
+
+	if d.file == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.file.Write(append(line, '\n')); err != nil {
+		d.logger.Error("Erro ao gravar no dead-letter log", zap.Error(err))
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// extractionSlots limita as extrações de arquivo simultâneas de uma conexão, para que uma
+// conexão com várias mensagens em andamento não ocupe todo o processamento do servidor.
+// A vaga só é liberada quando a extração termina, mesmo que a requisição já tenha desistido
+// dela por timeout. Um valor nil não impõe limite.
+type extractionSlots chan struct{}
+
+// newExtractionSlots cria o limite da conexão; max <= 0 desativa.
+func newExtractionSlots(max int) extractionSlots {
+	if max <= 0 {
+		return nil
+	}
+	return make(extractionSlots, max)
+}
+
+var (
+	httpExtractionsOnce sync.Once
+	httpExtractions     extractionSlots
+)
+
+// sharedHTTPExtractions retorna o limite compartilhado pelas requisições HTTP de chat, que não
+// têm uma conexão própria. Criado na primeira chamada com max (MAX_HTTP_EXTRACTIONS).
+func sharedHTTPExtractions(max int) extractionSlots {
+	httpExtractionsOnce.Do(func() {
+		httpExtractions = newExtractionSlots(max)
+	})
+	return httpExtractions
+}
+
+// tryAcquire ocupa uma vaga sem bloquear.
+func (s extractionSlots) tryAcquire() bool {
+	if s == nil {
+		return true
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquireUntil aguarda uma vaga até o prazo; sem prazo, aguarda indefinidamente.
+func (s extractionSlots) acquireUntil(deadline time.Time) bool {
+	if s == nil {
+		return true
+	}
+	if deadline.IsZero() {
+		s <- struct{}{}
+		return true
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return false
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case s <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// release libera a vaga ocupada por uma extração concluída.
+func (s extractionSlots) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// inUse retorna quantas extrações da conexão estão em andamento.
+func (s extractionSlots) inUse() int {
+	return len(s)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/webchatcomllm/utils"
+)
+
+// Formatos dos metadados de cada arquivo no contexto (FILE_METADATA_FORMAT / metadataFormat).
+const (
+	metadataMarkdown = "markdown" // lista de itens, legível (padrão)
+	metadataJSON     = "json"     // bloco JSON, para consumo programático
+	metadataBoth     = "both"     // lista seguida do bloco JSON
+)
+
+// resolveMetadataFormat escolhe o formato da requisição ou, sem ele, o configurado.
+func resolveMetadataFormat(requested, configured string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(firstNonEmpty(requested, configured, metadataMarkdown)))
+	switch format {
+	case metadataMarkdown, metadataJSON, metadataBoth:
+		return format, nil
+	}
+	return "", fmt.Errorf("formato de metadados inválido: %q (use %s, %s ou %s)", format, metadataMarkdown, metadataJSON, metadataBoth)
+}
+
+// writeFileMetadata escreve os metadados do arquivo no formato escolhido. O bloco JSON traz
+// também nome, tipo e tamanho, para que o modelo não dependa do índice de arquivos. O texto do
+// OCR fica de fora, pois entra no contexto logo após o conteúdo do arquivo.
+func writeFileMetadata(b *strings.Builder, pf utils.ProcessedFile, format string) {
+	if _, ok := pf.Metadata[utils.MetadataOCRText]; ok {
+		metadata := make(map[string]interface{}, len(pf.Metadata))
+		for key, value := range pf.Metadata {
+			if key != utils.MetadataOCRText {
+				metadata[key] = value
+			}
+		}
+		pf.Metadata = metadata
+	}
+	if format != metadataJSON && len(pf.Metadata) > 0 {
+		b.WriteString("**Metadados:**\n")
+		for key, value := range pf.Metadata {
+			b.WriteString(fmt.Sprintf("- %s: %v\n", key, value))
+		}
+		b.WriteString("\n")
+	}
+	if format == metadataMarkdown {
+		return
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"name":        pf.Name,
+		"fileType":    pf.FileType,
+		"contentType": pf.ContentType,
+		"size":        pf.Size,
+		"metadata":    pf.Metadata,
+	}, "", "  ")
+	if err != nil {
+		// Metadados com valores não serializáveis ficam apenas na lista
+		return
+	}
+	b.WriteString(fmt.Sprintf("```json\n%s\n```\n\n", data))
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// errFileProcessingTimeout indica que o orçamento de tempo dos arquivos se esgotou.
+var errFileProcessingTimeout = errors.New("tempo de processamento de arquivos excedido")
+
+// processFileUntil processa o arquivo respeitando o prazo. Sem prazo, chama o processador
+// diretamente; com prazo, a requisição é liberada ao expirar e a goroutine termina o
+// arquivo em segundo plano, descartando o resultado, já que os extratores não podem ser
+// interrompidos. O contexto repassado ao processador expira junto com o prazo e é cancelado
+// ao retornar, o que encerra as etapas externas, como o OCR, da goroutine abandonada.
+// release é chamado quando o processamento termina, inclusive em segundo plano: a vaga das
+// extractionSlots continua ocupada pela goroutine abandonada, o que limita quantas delas
+// podem existir ao mesmo tempo.
+func processFileUntil(ctx context.Context, deadline time.Time, fp *utils.FileProcessor, name string, content []byte, password string, release func()) (*utils.ProcessedFile, error) {
+	if deadline.IsZero() {
+		defer release()
+		return fp.ProcessFileContext(ctx, name, content, password)
+	}
+
+	if time.Until(deadline) <= 0 {
+		release()
+		return nil, errFileProcessingTimeout
+	}
+
+	type result struct {
+		processed *utils.ProcessedFile
+		err       error
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	done := make(chan result, 1)
+	go func() {
+		defer release()
+		processed, err := fp.ProcessFileContext(ctx, name, content, password)
+		done <- result{processed, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.processed, r.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errFileProcessingTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// fileProcessingTimeoutError monta a mensagem enviada ao usuário com o progresso até o corte.
+func fileProcessingTimeoutError(processed, total int, budget time.Duration, logger *zap.Logger) error {
+	logger.Warn("Tempo de processamento de arquivos excedido",
+		zap.Duration("budget", budget),
+		zap.Int("processed", processed),
+		zap.Int("total", total),
+	)
+	return fmt.Errorf("%w (limite de %s): %d de %d arquivos processados", errFileProcessingTimeout, budget, processed, total)
+}
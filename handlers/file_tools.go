@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	llmclient "github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// fileQueryToolName é o nome da ferramenta de consulta aos arquivos tabulares.
+	fileQueryToolName = "query_file"
+	// fileQueryMaxRows limita as linhas retornadas por consulta.
+	fileQueryMaxRows = 50
+	// fileTableSampleRows é o número de linhas de exemplo descritas no prompt.
+	fileTableSampleRows = 3
+	// fileTableMaxRows limita as linhas carregadas de cada arquivo; as demais são descartadas.
+	fileTableMaxRows = 100000
+)
+
+// fileTable é um arquivo CSV ou JSON (lista de objetos) carregado em memória para consultas
+// pela ferramenta query_file, em vez de ter o conteúdo inteiro enviado no contexto.
+type fileTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]string
+	// Truncated indica que o arquivo tinha mais de fileTableMaxRows linhas
+	Truncated bool
+}
+
+// splitToolFiles separa os arquivos CSV e JSON tabulares, consultados pela ferramenta
+// query_file, dos que seguem para a extração local. Sem suporte do cliente a ferramentas,
+// todos seguem o fluxo local. Os limites de tamanho são verificados antes da decodificação:
+// arquivos acima de MaxFileSize, ou uma requisição acima de MaxTotalUploadSize, seguem para a
+// extração local, que os recusa. Nomes repetidos recebem um sufixo, para que cada tabela
+// seja identificável na ferramenta.
+func splitToolFiles(files []FilePayload, llmClient llmclient.LLMClient, logger *zap.Logger) ([]FilePayload, []*fileTable) {
+	if _, ok := llmClient.(llmclient.ToolClient); !ok {
+		return files, nil
+	}
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += payloadSize(file)
+	}
+	if totalSize > MaxTotalUploadSize {
+		return files, nil
+	}
+
+	var local []FilePayload
+	var tables []*fileTable
+	names := make(map[string]bool)
+	for _, file := range files {
+		if payloadSize(file) > MaxFileSize {
+			local = append(local, file)
+			continue
+		}
+		table, err := parseFileTable(file)
+		if err != nil || table == nil {
+			if err != nil {
+				logger.Debug("Arquivo não pôde ser carregado como tabela, seguindo para extração",
+					zap.String("file", file.Name), zap.Error(err))
+			}
+			local = append(local, file)
+			continue
+		}
+		table.Name = uniqueTableName(table.Name, names)
+		tables = append(tables, table)
+		logger.Info("Arquivo disponível para consulta por ferramenta",
+			zap.String("file", table.Name),
+			zap.Int("rows", len(table.Rows)),
+			zap.Int("columns", len(table.Columns)),
+			zap.Bool("truncated", table.Truncated),
+		)
+	}
+	return local, tables
+}
+
+// payloadSize é o tamanho do conteúdo do arquivo, estimado sem decodificar quando em base64.
+func payloadSize(file FilePayload) int64 {
+	if file.IsBase64 {
+		return base64DecodedSize(file.Content)
+	}
+	return int64(len(file.Content))
+}
+
+// uniqueTableName acrescenta " (2)", " (3)"... antes da extensão de um nome já usado.
+func uniqueTableName(name string, used map[string]bool) string {
+	unique := name
+	ext := filepath.Ext(name)
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[unique] = true
+	return unique
+}
+
+// parseFileTable carrega arquivos .csv e .json como tabela. Retorna nil para outros tipos.
+func parseFileTable(file FilePayload) (*fileTable, error) {
+	ext := strings.ToLower(filepath.Ext(file.Name))
+	if ext != ".csv" && ext != ".json" {
+		return nil, nil
+	}
+
+	content := []byte(file.Content)
+	if file.IsBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(file.Content)
+		if err != nil {
+			return nil, err
+		}
+		content = decoded
+	}
+
+	if ext == ".csv" {
+		return parseCSVTable(file.Name, content)
+	}
+	return parseJSONTable(file.Name, content)
+}
+
+func parseCSVTable(name string, content []byte) (*fileTable, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	table := &fileTable{Name: name, Columns: header}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(table.Rows) == fileTableMaxRows {
+			table.Truncated = true
+			break
+		}
+		table.Rows = append(table.Rows, record)
+	}
+	if len(table.Rows) == 0 {
+		return nil, fmt.Errorf("CSV sem linhas de dados")
+	}
+	return table, nil
+}
+
+// parseJSONTable aceita apenas listas de objetos; as colunas são as chaves de todos os objetos,
+// em ordem alfabética dentro de cada objeto. Valores aninhados são mantidos como JSON.
+func parseJSONTable(name string, content []byte) (*fileTable, error) {
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(content, &items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("JSON sem objetos")
+	}
+
+	table := &fileTable{Name: name}
+	if len(items) > fileTableMaxRows {
+		items = items[:fileTableMaxRows]
+		table.Truncated = true
+	}
+	index := make(map[string]int)
+	for _, item := range items {
+		keys := make([]string, 0, len(item))
+		for key := range item {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, ok := index[key]; !ok {
+				index[key] = len(table.Columns)
+				table.Columns = append(table.Columns, key)
+			}
+		}
+	}
+	for _, item := range items {
+		row := make([]string, len(table.Columns))
+		for key, raw := range item {
+			var s string
+			if err := json.Unmarshal(raw, &s); err == nil {
+				row[index[key]] = s
+			} else if string(raw) != "null" {
+				row[index[key]] = string(raw)
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
+
+// describeTables descreve no prompt os arquivos consultáveis: colunas, total de linhas e
+// algumas linhas de exemplo. Com detectInjection, as colunas e os exemplos de cada arquivo são
+// verificados, e os suspeitos são isolados com os marcadores de untrusted e retornados.
+func describeTables(tables []*fileTable, detectInjection bool, untrusted untrustedBlock, logger *zap.Logger) (string, []string) {
+	var b strings.Builder
+	var suspiciousFiles []string
+	b.WriteString("### Arquivos disponíveis para consulta\n\n")
+	b.WriteString("O conteúdo destes arquivos não foi incluído no contexto. Use a ferramenta `" + fileQueryToolName +
+		"` para filtrar, ordenar e agregar os dados antes de responder.\n")
+	for _, t := range tables {
+		var d strings.Builder
+		fmt.Fprintf(&d, "\n- **%s**: %d linhas; colunas: %s\n", t.Name, len(t.Rows), strings.Join(t.Columns, ", "))
+		if t.Truncated {
+			fmt.Fprintf(&d, "  - apenas as primeiras %d linhas foram carregadas\n", fileTableMaxRows)
+		}
+		for i := 0; i < len(t.Rows) && i < fileTableSampleRows; i++ {
+			fmt.Fprintf(&d, "  - exemplo: %s\n", strings.Join(t.Rows[i], " | "))
+		}
+
+		description := d.String()
+		if detectInjection {
+			if patterns := detectPromptInjection(description); len(patterns) > 0 {
+				suspiciousFiles = append(suspiciousFiles, t.Name)
+				logger.Warn("Possível injeção de instruções em arquivo",
+					zap.String("file", t.Name),
+					zap.Strings("patterns", patterns),
+				)
+				description = "\n" + untrusted.start(t.Name) + escapeUntrusted(description) + "\n" + untrusted.end()
+			}
+		}
+		b.WriteString(description)
+	}
+	return b.String(), suspiciousFiles
+}
+
+// fileQueryTool define a ferramenta query_file para os arquivos da requisição.
+func fileQueryTool(tables []*fileTable) models.Tool {
+	names := make([]string, 0, len(tables))
+	for _, t := range tables {
+		names = append(names, t.Name)
+	}
+	return models.Tool{
+		Name: fileQueryToolName,
+		Description: fmt.Sprintf("Consulta um arquivo tabular enviado pelo usuário, como um SELECT: filtra as linhas, "+
+			"escolhe colunas, ordena e agrega. Retorna no máximo %d linhas.", fileQueryMaxRows),
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"file": map[string]interface{}{"type": "string", "enum": names, "description": "Nome do arquivo"},
+				"columns": map[string]interface{}{
+					"type": "array", "items": map[string]string{"type": "string"},
+					"description": "Colunas retornadas; vazio retorna todas",
+				},
+				"filters": map[string]interface{}{
+					"type":        "array",
+					"description": "Condições combinadas com AND",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"column": map[string]string{"type": "string"},
+							"op":     map[string]interface{}{"type": "string", "enum": []string{"=", "!=", ">", ">=", "<", "<=", "contains"}},
+							"value":  map[string]string{"type": "string"},
+						},
+						"required": []string{"column", "op", "value"},
+					},
+				},
+				"group_by":  map[string]interface{}{"type": "string", "description": "Coluna de agrupamento da agregação"},
+				"aggregate": map[string]interface{}{"type": "string", "enum": []string{"count", "sum", "avg", "min", "max"}},
+				"aggregate_column": map[string]interface{}{
+					"type": "string", "description": "Coluna agregada (dispensável para count)",
+				},
+				"order_by":   map[string]string{"type": "string"},
+				"descending": map[string]string{"type": "boolean"},
+				"limit":      map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Máximo de linhas (até %d)", fileQueryMaxRows)},
+			},
+			"required": []string{"file"},
+		},
+	}
+}
+
+// fileQuery são os argumentos de uma chamada de query_file.
+type fileQuery struct {
+	File    string   `json:"file"`
+	Columns []string `json:"columns"`
+	Filters []struct {
+		Column string `json:"column"`
+		Op     string `json:"op"`
+		Value  string `json:"value"`
+	} `json:"filters"`
+	GroupBy         string `json:"group_by"`
+	Aggregate       string `json:"aggregate"`
+	AggregateColumn string `json:"aggregate_column"`
+	OrderBy         string `json:"order_by"`
+	Descending      bool   `json:"descending"`
+	Limit           int    `json:"limit"`
+}
+
+// tableQueryExecutor executa as chamadas de query_file sobre os arquivos da requisição. Erros
+// são devolvidos ao modelo no resultado, para que ele corrija a consulta.
+func tableQueryExecutor(tables []*fileTable) llmclient.ToolExecutor {
+	return func(_ context.Context, call models.ToolCall) string {
+		result, err := runTableQuery(tables, call)
+		if err != nil {
+			result = map[string]interface{}{"error": err.Error()}
+		}
+		data, _ := json.Marshal(result)
+		return string(data)
+	}
+}
+
+func runTableQuery(tables []*fileTable, call models.ToolCall) (interface{}, error) {
+	if call.Name != fileQueryToolName {
+		return nil, fmt.Errorf("ferramenta desconhecida: %s", call.Name)
+	}
+	var q fileQuery
+	if err := json.Unmarshal(call.Arguments, &q); err != nil {
+		return nil, fmt.Errorf("argumentos inválidos: %w", err)
+	}
+	var table *fileTable
+	for _, t := range tables {
+		if t.Name == q.File {
+			table = t
+		}
+	}
+	if table == nil {
+		return nil, fmt.Errorf("arquivo não encontrado: %q", q.File)
+	}
+
+	rows, err := table.filter(q)
+	if err != nil {
+		return nil, err
+	}
+	if q.Aggregate != "" {
+		return table.aggregate(rows, q)
+	}
+
+	if q.OrderBy != "" {
+		col, err := table.column(q.OrderBy)
+		if err != nil {
+			return nil, err
+		}
+		// Linhas do CSV podem ter menos campos que o cabeçalho
+		sort.SliceStable(rows, func(i, j int) bool {
+			if q.Descending {
+				return lessValue(cell(rows[j], col), cell(rows[i], col))
+			}
+			return lessValue(cell(rows[i], col), cell(rows[j], col))
+		})
+	}
+
+	columns := q.Columns
+	if len(columns) == 0 {
+		columns = table.Columns
+	}
+	indexes := make([]int, len(columns))
+	for i, name := range columns {
+		if indexes[i], err = table.column(name); err != nil {
+			return nil, err
+		}
+	}
+
+	limit := q.Limit
+	if limit <= 0 || limit > fileQueryMaxRows {
+		limit = fileQueryMaxRows
+	}
+	matched := len(rows)
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	out := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		values := make([]string, len(indexes))
+		for i, col := range indexes {
+			values[i] = cell(row, col)
+		}
+		out = append(out, values)
+	}
+	return map[string]interface{}{
+		"columns":   columns,
+		"rows":      out,
+		"matched":   matched,
+		"truncated": matched > len(out),
+	}, nil
+}
+
+func (t *fileTable) column(name string) (int, error) {
+	for i, c := range t.Columns {
+		if strings.EqualFold(c, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("coluna não encontrada: %q (colunas: %s)", name, strings.Join(t.Columns, ", "))
+}
+
+// filter retorna as linhas que atendem a todas as condições.
+func (t *fileTable) filter(q fileQuery) ([][]string, error) {
+	type condition struct {
+		col   int
+		op    string
+		value string
+	}
+	conditions := make([]condition, 0, len(q.Filters))
+	for _, f := range q.Filters {
+		col, err := t.column(f.Column)
+		if err != nil {
+			return nil, err
+		}
+		switch f.Op {
+		case "=", "!=", ">", ">=", "<", "<=", "contains":
+		default:
+			return nil, fmt.Errorf("operador inválido: %q", f.Op)
+		}
+		conditions = append(conditions, condition{col: col, op: f.Op, value: f.Value})
+	}
+
+	var rows [][]string
+	for _, row := range t.Rows {
+		match := true
+		for _, c := range conditions {
+			if !compareValues(cell(row, c.col), c.op, c.value) {
+				match = false
+				break
+			}
+		}
+		if match {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// aggregate calcula count, sum, avg, min ou max, por grupo quando group_by é informado.
+func (t *fileTable) aggregate(rows [][]string, q fileQuery) (interface{}, error) {
+	op := strings.ToLower(q.Aggregate)
+	valueCol := -1
+	if op != "count" {
+		if q.AggregateColumn == "" {
+			return nil, fmt.Errorf("aggregate_column é obrigatório para %s", op)
+		}
+		col, err := t.column(q.AggregateColumn)
+		if err != nil {
+			return nil, err
+		}
+		valueCol = col
+	}
+	groupCol := -1
+	if q.GroupBy != "" {
+		col, err := t.column(q.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		groupCol = col
+	}
+
+	type acc struct {
+		count, n      int
+		sum, min, max float64
+	}
+	groups := make(map[string]*acc)
+	var order []string
+	for _, row := range rows {
+		key := ""
+		if groupCol >= 0 {
+			key = cell(row, groupCol)
+		}
+		a, ok := groups[key]
+		if !ok {
+			a = &acc{}
+			groups[key] = a
+			order = append(order, key)
+		}
+		a.count++
+		if valueCol < 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(cell(row, valueCol)), 64)
+		if err != nil {
+			continue
+		}
+		if a.n == 0 || v < a.min {
+			a.min = v
+		}
+		if a.n == 0 || v > a.max {
+			a.max = v
+		}
+		a.sum += v
+		a.n++
+	}
+
+	// O resultado da agregação não pode sobrescrever a coluna de agrupamento de mesmo nome
+	aggKey := op
+	if strings.EqualFold(q.GroupBy, op) {
+		aggKey = "aggregate_" + op
+	}
+	var results []map[string]interface{}
+	for _, key := range order {
+		a := groups[key]
+		var value interface{}
+		switch op {
+		case "count":
+			value = a.count
+		case "sum":
+			value = a.sum
+		case "avg":
+			if a.n > 0 {
+				value = a.sum / float64(a.n)
+			}
+		case "min":
+			if a.n > 0 {
+				value = a.min
+			}
+		case "max":
+			if a.n > 0 {
+				value = a.max
+			}
+		default:
+			return nil, fmt.Errorf("agregação inválida: %q", q.Aggregate)
+		}
+		entry := map[string]interface{}{aggKey: value}
+		if groupCol >= 0 {
+			entry[q.GroupBy] = key
+		}
+		results = append(results, entry)
+	}
+	if q.OrderBy != "" {
+		desc := q.Descending
+		sort.SliceStable(results, func(i, j int) bool {
+			a, b := fmt.Sprint(results[i][q.OrderBy]), fmt.Sprint(results[j][q.OrderBy])
+			if desc {
+				return lessValue(b, a)
+			}
+			return lessValue(a, b)
+		})
+	}
+	truncated := len(results) > fileQueryMaxRows
+	if truncated {
+		results = results[:fileQueryMaxRows]
+	}
+	return map[string]interface{}{"results": results, "aggregateKey": aggKey, "matched": len(rows), "truncated": truncated}, nil
+}
+
+func cell(row []string, col int) string {
+	if col < len(row) {
+		return row[col]
+	}
+	return ""
+}
+
+// compareValues compara numericamente quando os dois lados são números; caso contrário,
+// compara o texto sem diferenciar maiúsculas.
+func compareValues(value, op, target string) bool {
+	if op == "contains" {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(target))
+	}
+	var cmp int
+	a, errA := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	b, errB := strconv.ParseFloat(strings.TrimSpace(target), 64)
+	switch {
+	case errA == nil && errB == nil && a < b:
+		cmp = -1
+	case errA == nil && errB == nil && a > b:
+		cmp = 1
+	case errA == nil && errB == nil:
+		cmp = 0
+	default:
+		cmp = strings.Compare(strings.ToLower(value), strings.ToLower(target))
+	}
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+func lessValue(a, b string) bool {
+	return compareValues(a, "<", b)
+}
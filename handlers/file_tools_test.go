@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/webchatcomllm/models"
+)
+
+// raggedTable tem linhas com menos campos que o cabeçalho, que o parser do CSV mantém.
+func raggedTable(t *testing.T) []*fileTable {
+	t.Helper()
+	table, err := parseCSVTable("dados.csv", []byte("a,b\n1\n2,3\n4,1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []*fileTable{table}
+}
+
+func queryTable(t *testing.T, tables []*fileTable, args string) map[string]interface{} {
+	t.Helper()
+	call := models.ToolCall{Name: fileQueryToolName, Arguments: json.RawMessage(args)}
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(tableQueryExecutor(tables)(context.Background(), call)), &result); err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := result["error"]; ok {
+		t.Fatalf("consulta %s: %v", args, msg)
+	}
+	return result
+}
+
+func TestRunTableQueryRaggedRows(t *testing.T) {
+	tables := raggedTable(t)
+
+	t.Run("order_by", func(t *testing.T) {
+		for _, desc := range []string{"false", "true"} {
+			result := queryTable(t, tables, `{"file": "dados.csv", "order_by": "b", "descending": `+desc+`}`)
+			rows := result["rows"].([]interface{})
+			if len(rows) != 3 {
+				t.Fatalf("linhas = %v", rows)
+			}
+			// A célula ausente vale "" e fica antes dos números na ordem crescente
+			first := rows[0].([]interface{})
+			if desc == "false" && (first[0] != "1" || first[1] != "") {
+				t.Fatalf("primeira linha = %v", first)
+			}
+		}
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		result := queryTable(t, tables, `{"file": "dados.csv", "filters": [{"column": "b", "op": ">=", "value": "1"}]}`)
+		if result["matched"] != float64(2) {
+			t.Fatalf("matched = %v, want 2", result["matched"])
+		}
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		result := queryTable(t, tables, `{"file": "dados.csv", "aggregate": "sum", "aggregate_column": "b", "group_by": "b", "order_by": "b"}`)
+		if groups := result["results"].([]interface{}); len(groups) != 3 {
+			t.Fatalf("grupos = %v, want 3", groups)
+		}
+		result = queryTable(t, tables, `{"file": "dados.csv", "aggregate": "avg", "aggregate_column": "b"}`)
+		avg := result["results"].([]interface{})[0].(map[string]interface{})["avg"]
+		if avg != float64(2) {
+			t.Fatalf("avg = %v, want 2 (células ausentes ignoradas)", avg)
+		}
+	})
+}
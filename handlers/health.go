@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/webchatcomllm/config"
+	"github.com/webchatcomllm/llm/manager"
+)
+
+// healthStatus é o corpo das respostas de /healthz e /readyz.
+type healthStatus struct {
+	Status    string   `json:"status"`
+	Version   string   `json:"version"`
+	Providers []string `json:"providers,omitempty"`
+}
+
+// shuttingDown é marcado no início do desligamento, para que /readyz tire o pod do balanceamento.
+var shuttingDown atomic.Bool
+
+// MarkShuttingDown faz /readyz responder 503 a partir de agora; chamado no início do desligamento.
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// HealthzHandler é a sonda de liveness: responde 200 enquanto o processo atende requisições.
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, healthStatus{Status: "ok", Version: config.Version})
+	}
+}
+
+// ReadyzHandler é a sonda de readiness: responde 200 apenas com ao menos um provedor de LLM
+// configurado e fora do desligamento, e 503 caso contrário. O corpo lista os provedores para
+// conferência da configuração.
+func ReadyzHandler(llmManager manager.LLMManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			writeHealth(w, http.StatusServiceUnavailable, healthStatus{Status: "shutting_down", Version: config.Version})
+			return
+		}
+		providers := llmManager.ConfiguredProviders()
+		if len(providers) == 0 {
+			writeHealth(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Version: config.Version})
+			return
+		}
+		writeHealth(w, http.StatusOK, healthStatus{Status: "ready", Version: config.Version, Providers: providers})
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, body healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
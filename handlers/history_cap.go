@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"github.com/webchatcomllm/models"
+	"go.uber.org/zap"
+)
+
+// historySize retorna o total de caracteres das mensagens do histórico.
+func historySize(history []models.Message) int {
+	total := 0
+	for _, msg := range history {
+		total += len(msg.Content)
+	}
+	return total
+}
+
+// capHistory aplica os limites de HistoryMaxMessages e HistoryMaxChars descartando as mensagens
+// mais antigas da conversa. As mensagens de sistema iniciais (instruções e resumo da memória)
+// são sempre mantidas, e a conversa restante nunca começa por uma resposta do assistente.
+func capHistory(history []models.Message, cfg HandlerConfig, logger *zap.Logger) []models.Message {
+	if cfg.HistoryMaxMessages <= 0 && cfg.HistoryMaxChars <= 0 {
+		return history
+	}
+
+	leading := 0
+	for leading < len(history) && history[leading].IsInstruction() {
+		leading++
+	}
+	conversation := history[leading:]
+	size := historySize(history)
+
+	start := 0
+	for start < len(conversation) {
+		overMessages := cfg.HistoryMaxMessages > 0 && len(conversation)-start > cfg.HistoryMaxMessages
+		overChars := cfg.HistoryMaxChars > 0 && size > cfg.HistoryMaxChars
+		if !overMessages && !overChars {
+			break
+		}
+		size -= len(conversation[start].Content)
+		start++
+	}
+	for start < len(conversation) && conversation[start].Role == "assistant" {
+		size -= len(conversation[start].Content)
+		start++
+	}
+	if start == 0 {
+		return history
+	}
+
+	logger.Info("Histórico da conversa limitado",
+		zap.Int("mensagens_descartadas", start),
+		zap.Int("mensagens_mantidas", len(conversation)-start),
+		zap.Int("caracteres", size),
+	)
+
+	result := make([]models.Message, 0, leading+len(conversation)-start)
+	result = append(result, history[:leading]...)
+	return append(result, conversation[start:]...)
+}
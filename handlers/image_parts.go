@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"github.com/webchatcomllm/llm/catalog"
+	llmclient "github.com/webchatcomllm/llm/client"
+	"go.uber.org/zap"
+)
+
+// Formas de incluir as imagens processadas na requisição.
+const (
+	imageInline  = ""        // data URI no texto do prompt (clientes sem partes multimodais)
+	imageParts   = "parts"   // partes multimodais da mensagem (ImageClient com modelo de visão)
+	imageOmitted = "omitted" // apenas uma nota no contexto (ImageClient com modelo sem visão)
+)
+
+// imageModeFor escolhe como as imagens seguem ao provedor. Clientes com partes multimodais
+// recebem as imagens separadas do texto quando o modelo interpreta imagens; sem visão, o
+// base64 seria apenas texto ilegível ao modelo e é omitido.
+func imageModeFor(llmClient llmclient.LLMClient, provider, model string) string {
+	if _, ok := llmClient.(llmclient.ImageClient); !ok {
+		return imageInline
+	}
+	if catalog.SupportsVision(provider, model) {
+		return imageParts
+	}
+	return imageOmitted
+}
+
+// attachImages entrega ao cliente as imagens do prompt como partes multimodais.
+func attachImages(llmClient llmclient.LLMClient, prompt preparedPrompt, logger *zap.Logger) {
+	if len(prompt.Images) == 0 {
+		return
+	}
+	imageClient, ok := llmClient.(llmclient.ImageClient)
+	if !ok {
+		// O cliente foi trocado após o processamento (ex.: roteamento) por um sem suporte
+		logger.Warn("Cliente não aceita imagens como partes multimodais, imagens descartadas",
+			zap.Int("images", len(prompt.Images)))
+		return
+	}
+	imageClient.SetImages(prompt.Images)
+}
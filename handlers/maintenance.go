@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/metrics"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+var providerMaintenanceTotal = metrics.Default.Counter("provider_maintenance_total", "Respostas de manutenção recebidas dos provedores")
+
+// maintenanceError é a mensagem exibida quando o provedor está em manutenção.
+func maintenanceError(provider string) error {
+	return &utils.CategorizedError{
+		Category: utils.ErrorCategoryMaintenance,
+		Err:      fmt.Errorf("O provedor %s está em manutenção. Tente novamente mais tarde ou escolha outro provedor.", provider),
+	}
+}
+
+// applyMaintenanceRouting verifica se o provedor da requisição está em manutenção. Com
+// MAINTENANCE_FALLBACK_PROVIDER configurado (e disponível), a requisição é desviada para ele e
+// o aviso ao usuário é retornado; sem fallback, retorna o erro de manutenção.
+func applyMaintenanceRouting(req *RequestPayload, cfg HandlerConfig, logger *zap.Logger) (string, error) {
+	provider := catalog.ResolveProvider(req.Provider)
+	if _, inMaintenance := utils.ProviderInMaintenance(provider); !inMaintenance {
+		return "", nil
+	}
+
+	fallback := cfg.MaintenanceFallbackProvider
+	if fallback == "" || catalog.ResolveProvider(fallback) == provider {
+		return "", maintenanceError(req.Provider)
+	}
+	if _, inMaintenance := utils.ProviderInMaintenance(catalog.ResolveProvider(fallback)); inMaintenance {
+		return "", maintenanceError(req.Provider)
+	}
+
+	logger.Info("Provedor em manutenção, usando provedor alternativo",
+		zap.String("provider", req.Provider),
+		zap.String("fallback_provider", fallback),
+		zap.String("fallback_model", cfg.MaintenanceFallbackModel),
+	)
+	notice := fmt.Sprintf("O provedor %s está em manutenção; esta mensagem foi respondida por %s.", req.Provider, fallback)
+	req.Provider = fallback
+	req.Model = cfg.MaintenanceFallbackModel
+	req.providerNotice = notice
+	return notice, nil
+}
+
+// noteProviderError abre o circuito do provedor por MaintenanceCooldown quando ele anuncia
+// manutenção, evitando novas chamadas (e retries) enquanto estiver fora do ar.
+func noteProviderError(provider string, err error, cfg HandlerConfig, logger *zap.Logger) {
+	if !utils.IsMaintenanceError(err) {
+		return
+	}
+	provider = catalog.ResolveProvider(provider)
+	until := utils.MarkProviderMaintenance(provider, cfg.MaintenanceCooldown)
+	providerMaintenanceTotal.Inc(metrics.Labels{"provider": metricProvider(provider)})
+	logger.Warn("Provedor em manutenção",
+		zap.Bool("provider_maintenance", true),
+		zap.String("provider", provider),
+		zap.Duration("cooldown", cfg.MaintenanceCooldown),
+		zap.Time("until", until),
+		zap.Error(err),
+	)
+}
+
+// recordProviderOutcome alimenta o circuit breaker do provedor com o resultado da chamada.
+// Só falhas do lado do provedor contam (providerFault): erros da própria requisição, limites
+// de taxa, cancelamentos pelo cliente e o prazo da requisição (ctx) esgotado não, e manutenções
+// já abriram o circuito em noteProviderError.
+func recordProviderOutcome(ctx context.Context, provider string, err error) {
+	cb := utils.ProviderBreaker(catalog.ResolveProvider(provider))
+	switch {
+	case err == nil:
+		cb.RecordSuccess()
+	case requestTimedOut(ctx), errors.Is(err, context.Canceled), utils.IsMaintenanceError(err):
+	case providerFault(err):
+		cb.RecordFailure()
+	}
+}
+
+// providerFault indica uma falha do provedor: respostas 5xx (inclusive o 529 da Claude), 408,
+// erros de rede e prazos esgotados fora do contexto da requisição.
+func providerFault(err error) bool {
+	var apiErr *utils.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusRequestTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return utils.ErrorCategoryOf(err) == utils.ErrorCategoryNetwork
+}
+
+// clientErrorCategory classifica a falha ao obter o cliente do provedor: circuito aberto é
+// indisponibilidade do provedor; os demais casos são erros da requisição.
+func clientErrorCategory(err error) utils.ErrorCategory {
+	if errors.Is(err, utils.ErrCircuitOpen) {
+		return utils.ErrorCategoryServer
+	}
+	return utils.ErrorCategoryClient
+}
+
+// llmErrorMessage monta a mensagem de erro de uma chamada ao provedor.
+func llmErrorMessage(provider string, err error) string {
+	if utils.IsMaintenanceError(err) {
+		return maintenanceError(provider).Error()
+	}
+	return llmErrorPrefix + err.Error()
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/webchatcomllm/metrics"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+var rateLimitedMessages = metrics.Default.Counter("ws_rate_limited_messages_total", "Mensagens WebSocket recusadas por WS_MESSAGES_PER_SEC")
+
+// messageRateLimiter é um token bucket por conexão: acumula até perSecond mensagens (rajada)
+// e repõe perSecond por segundo. Protege o parse e a validação das mensagens antes de
+// qualquer chamada ao provedor.
+type messageRateLimiter struct {
+	mu         sync.Mutex
+	perSecond  float64
+	tokens     float64
+	last       time.Time
+	violations int
+}
+
+// newMessageRateLimiter retorna nil quando perSecond <= 0 (limite desativado).
+func newMessageRateLimiter(perSecond int) *messageRateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &messageRateLimiter{
+		perSecond: float64(perSecond),
+		tokens:    float64(perSecond),
+		last:      time.Now(),
+	}
+}
+
+// allow consome uma mensagem do balde. O segundo retorno é o número de mensagens recusadas
+// seguidas, zerado a cada mensagem aceita.
+func (l *messageRateLimiter) allow() (bool, int) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.perSecond
+	if l.tokens > l.perSecond {
+		l.tokens = l.perSecond
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		l.violations++
+		return false, l.violations
+	}
+	l.tokens--
+	l.violations = 0
+	return true, 0
+}
+
+// rejectRateLimited avisa o cliente da mensagem recusada e encerra a conexão quando as
+// recusas seguidas atingem RateLimitCloseAfter.
+func (c *Client) rejectRateLimited(violations int) {
+	closeConn := c.config.RateLimitCloseAfter > 0 && violations >= c.config.RateLimitCloseAfter
+	c.logger.Warn("Mensagem recusada pelo limite de mensagens por segundo",
+		zap.String("client_id", c.id),
+		zap.Int("limit_per_sec", c.config.MessagesPerSecond),
+		zap.Int("violations", violations),
+		zap.Bool("closing", closeConn),
+	)
+	rateLimitedMessages.Inc(metrics.Labels{"closed": strconv.FormatBool(closeConn)})
+
+	c.sendJSON(ResponsePayload{
+		Type:          "message",
+		Status:        "rate_limited",
+		Response:      fmt.Sprintf("Muitas mensagens em sequência (limite: %d por segundo). Aguarde antes de enviar novamente.", c.config.MessagesPerSecond),
+		ErrorCategory: utils.ErrorCategoryRateLimit,
+	})
+	if closeConn {
+		// WriteControl pode ser chamado em paralelo ao writePump
+		c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "limite de mensagens excedido"),
+			time.Now().Add(writeWait))
+		c.close()
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/webchatcomllm/utils"
+)
+
+// AllowMethods restringe o handler aos métodos informados, respondendo 405 com o cabeçalho
+// Allow aos demais. GET também aceita HEAD, como no restante da biblioteca padrão. Rotas em
+// /api/ recebem o erro no formato JSON da API; as demais, texto simples.
+func AllowMethods(next http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allowed := make(map[string]bool, len(methods)+1)
+	var list []string
+	for _, m := range methods {
+		m = strings.ToUpper(m)
+		if !allowed[m] {
+			allowed[m] = true
+			list = append(list, m)
+		}
+		if m == http.MethodGet && !allowed[http.MethodHead] {
+			allowed[http.MethodHead] = true
+			list = append(list, http.MethodHead)
+		}
+	}
+	allow := strings.Join(list, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if allowed[r.Method] {
+			next(w, r)
+			return
+		}
+		w.Header().Set("Allow", allow)
+		message := "Método não permitido. Use " + strings.Join(methods, " ou ") + "."
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			writeAPIError(w, http.StatusMethodNotAllowed, message, utils.ErrorCategoryClient)
+			return
+		}
+		http.Error(w, message, http.StatusMethodNotAllowed)
+	}
+}
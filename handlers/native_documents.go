@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// splitNativeDocuments separa os arquivos que o provedor aceita nativamente dos que
+// seguem para a extração local. Sem suporte do cliente, todos seguem o fluxo local. Os
+// limites de tamanho valem antes da decodificação e, acima do total por requisição, todos os
+// arquivos seguem para o fluxo local, que recusa a requisição.
+func splitNativeDocuments(files []FilePayload, llmClient client.LLMClient, logger *zap.Logger) ([]FilePayload, []models.Attachment) {
+	attClient, ok := llmClient.(client.AttachmentClient)
+	if !ok {
+		return files, nil
+	}
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += payloadSize(file)
+	}
+	if totalSize > MaxTotalUploadSize {
+		return files, nil
+	}
+
+	var local []FilePayload
+	var attachments []models.Attachment
+
+	for _, file := range files {
+		// Apenas PDFs são aceitos nativamente; os demais nem são decodificados
+		isPDF := strings.ToLower(filepath.Ext(file.Name)) == ".pdf" || file.ContentType == "application/pdf"
+		if !file.IsBase64 || !isPDF {
+			local = append(local, file)
+			continue
+		}
+
+		// Como na extração local, PDFs declarados como tal têm o limite próprio de PDFs
+		limit := int64(MaxFileSize)
+		if file.ContentType == "application/pdf" {
+			limit = utils.MaxPDFSize
+		}
+		if base64DecodedSize(file.Content) > limit {
+			// Deixa a validação e a mensagem de erro para o fluxo local
+			local = append(local, file)
+			continue
+		}
+
+		content, err := base64.StdEncoding.DecodeString(file.Content)
+		if err != nil {
+			local = append(local, file)
+			continue
+		}
+
+		mediaType := mimetype.Detect(content).String()
+		if strings.ToLower(filepath.Ext(file.Name)) == ".pdf" && mediaType != "application/pdf" {
+			mediaType = "application/pdf"
+		}
+
+		if !attClient.SupportsAttachment(mediaType) {
+			local = append(local, file)
+			continue
+		}
+
+		attachments = append(attachments, models.Attachment{
+			Name:      file.Name,
+			MediaType: mediaType,
+			Data:      file.Content,
+		})
+		logger.Info("Arquivo enviado nativamente ao provedor",
+			zap.String("file", file.Name),
+			zap.String("media_type", mediaType),
+			zap.Int("size", len(content)),
+		)
+	}
+
+	return local, attachments
+}
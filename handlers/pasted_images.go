@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// pastedImagePattern encontra imagens coladas no texto como data URI. O grupo 1 é o subtipo
+// (png, jpeg...) e o grupo 2, o conteúdo em base64.
+var pastedImagePattern = regexp.MustCompile(`data:image/(png|jpe?g|gif|webp);base64,([A-Za-z0-9+/]+={0,2})`)
+
+// extractPastedImages move as imagens coladas no prompt para req.Files, onde passam pelo
+// mesmo processamento (e pelos mesmos limites) dos anexos, e troca cada data URI no texto por
+// uma referência ao arquivo gerado.
+func extractPastedImages(req *RequestPayload, logger *zap.Logger) {
+	if !strings.Contains(req.Prompt, "data:image/") {
+		return
+	}
+
+	count := 0
+	req.Prompt = pastedImagePattern.ReplaceAllStringFunc(req.Prompt, func(match string) string {
+		parts := pastedImagePattern.FindStringSubmatch(match)
+		subtype, content := parts[1], parts[2]
+		if subtype == "jpg" {
+			subtype = "jpeg"
+		}
+		count++
+		name := fmt.Sprintf("imagem-colada-%d.%s", count, strings.Replace(subtype, "jpeg", "jpg", 1))
+		req.Files = append(req.Files, FilePayload{
+			Name:        name,
+			Content:     content,
+			ContentType: "image/" + subtype,
+			FileType:    "image",
+			Size:        int64(len(content) / 4 * 3),
+			IsBase64:    true,
+		})
+		return fmt.Sprintf("[imagem colada: %s]", name)
+	})
+
+	if count > 0 {
+		logger.Info("Imagens coladas no prompt extraídas como anexos", zap.Int("images", count))
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"math"
+	"time"
+)
+
+// etaSmoothing é o peso da amostra mais recente na média móvel do tempo por arquivo. Valores
+// menores deixam a estimativa mais estável quando os arquivos têm tamanhos muito diferentes.
+const etaSmoothing = 0.3
+
+// etaEstimator estima o tempo restante do processamento de arquivos a partir do tempo gasto
+// em cada arquivo concluído, suavizado por média móvel exponencial.
+type etaEstimator struct {
+	last    time.Time
+	perItem float64 // segundos por arquivo, suavizado
+	samples int
+}
+
+func newETAEstimator() *etaEstimator {
+	return &etaEstimator{last: time.Now()}
+}
+
+// complete registra a conclusão de um arquivo.
+func (e *etaEstimator) complete() {
+	now := time.Now()
+	sample := now.Sub(e.last).Seconds()
+	e.last = now
+	if e.samples == 0 {
+		e.perItem = sample
+	} else {
+		e.perItem = etaSmoothing*sample + (1-etaSmoothing)*e.perItem
+	}
+	e.samples++
+}
+
+// remaining estima o tempo para concluir os arquivos restantes; 0 enquanto não há amostras.
+func (e *etaEstimator) remaining(done, total int) time.Duration {
+	if e.samples == 0 || done >= total {
+		return 0
+	}
+	return time.Duration(e.perItem * float64(total-done) * float64(time.Second))
+}
+
+// etaSeconds converte a estimativa para o campo do payload, arredondando para cima.
+func etaSeconds(eta time.Duration) int {
+	if eta <= 0 {
+		return 0
+	}
+	return int(math.Ceil(eta.Seconds()))
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/utils"
+)
+
+// PromptDebug é a representação estruturada do prompt efetivamente enviado ao LLM.
+type PromptDebug struct {
+	FullPrompt       string           `json:"fullPrompt"`
+	Messages         []models.Message `json:"messages"`
+	PromptChars      int              `json:"promptChars"`
+	FileContextChars int              `json:"fileContextChars"`
+	HistoryMessages  int              `json:"historyMessages"`
+}
+
+// buildPromptDebug monta o snapshot de debug do prompt, com PII redigida e data URIs resumidos.
+func buildPromptDebug(fullPrompt, fileContext string, history []models.Message) PromptDebug {
+	sanitize := func(s string) string {
+		return utils.RedactPII(utils.CollapseDataURIs(s))
+	}
+
+	messages := make([]models.Message, 0, len(history)+1)
+	for _, msg := range history {
+		messages = append(messages, models.Message{Role: msg.Role, Content: sanitize(msg.Content)})
+	}
+	redactedPrompt := sanitize(fullPrompt)
+	messages = append(messages, models.Message{Role: "user", Content: redactedPrompt})
+
+	return PromptDebug{
+		FullPrompt:       redactedPrompt,
+		Messages:         messages,
+		PromptChars:      len(fullPrompt),
+		FileContextChars: len(fileContext),
+		HistoryMessages:  len(history),
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/webchatcomllm/llm/catalog"
+	llmclient "github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/llm/manager"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// maxFallbackProviders limita os provedores alternativos de uma requisição.
+const maxFallbackProviders = 4
+
+// fallbackAttempt registra um provedor que falhou antes do que respondeu a requisição.
+type fallbackAttempt struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	Error    string `json:"error"`
+}
+
+// parseFallbackTarget lê uma entrada de fallbackProviders: "PROVEDOR" ou "PROVEDOR:modelo".
+// Sem modelo, o provedor usa o seu modelo padrão.
+func parseFallbackTarget(entry string) (provider, model string) {
+	provider, model, _ = strings.Cut(strings.TrimSpace(entry), ":")
+	provider = catalog.ResolveProvider(strings.TrimSpace(provider))
+	model = strings.TrimSpace(model)
+	return provider, catalog.ResolveModel(provider, model)
+}
+
+// validateFallbackProviders recusa listas longas ou com entradas vazias.
+func validateFallbackProviders(entries []string) error {
+	if len(entries) > maxFallbackProviders {
+		return fmt.Errorf("Provedores alternativos demais: %d (limite: %d)", len(entries), maxFallbackProviders)
+	}
+	for _, entry := range entries {
+		if provider, _ := parseFallbackTarget(entry); provider == "" {
+			return fmt.Errorf("Provedor alternativo inválido: %q", entry)
+		}
+	}
+	return nil
+}
+
+// canFallback indica se a falha justifica tentar outro provedor. Erros da própria requisição
+// se repetiriam em qualquer provedor; com o contexto encerrado ou parte da resposta já
+// entregue em streaming, uma nova tentativa não é possível.
+func canFallback(ctx context.Context, result llmResult, err error) bool {
+	if ctx.Err() != nil || result.Response != "" {
+		return false
+	}
+	return utils.ErrorCategoryOf(err) != utils.ErrorCategoryClient
+}
+
+// generateWithFallback executa gen com o cliente da requisição e, se ele falhar
+// definitivamente (após as novas tentativas do próprio cliente), repete com cada provedor de
+// req.FallbackProviders, em ordem. Ao final, req.Provider e req.Model indicam o provedor que
+// respondeu. prepare refaz o prompt quando ele depende do cliente (documentos nativos e
+// arquivos consultáveis).
+func generateWithFallback(ctx context.Context, req *RequestPayload, llmClient llmclient.LLMClient, prompt *preparedPrompt,
+	llmManager manager.LLMManager, cfg HandlerConfig, logger *zap.Logger,
+	prepare func(llmclient.LLMClient) (preparedPrompt, error),
+	gen func(ctx context.Context, llmClient llmclient.LLMClient, prompt preparedPrompt) (llmResult, error),
+) (llmResult, error) {
+	result, err := gen(ctx, llmClient, *prompt)
+	recordProviderOutcome(ctx, req.Provider, err)
+
+	var attempts []fallbackAttempt
+	for _, entry := range req.FallbackProviders {
+		if err == nil || !canFallback(ctx, result, err) {
+			break
+		}
+		provider, model := parseFallbackTarget(entry)
+		if provider == catalog.ResolveProvider(req.Provider) && model == req.Model {
+			continue
+		}
+		if _, inMaintenance := utils.ProviderInMaintenance(provider); inMaintenance {
+			logger.Info("Provedor alternativo em manutenção, ignorado", zap.String("provider", provider))
+			continue
+		}
+		next, clientErr := llmManager.GetClient(provider, model)
+		if clientErr != nil {
+			logger.Warn("Provedor alternativo indisponível, ignorado",
+				zap.String("provider", provider),
+				zap.Error(clientErr),
+			)
+			continue
+		}
+
+		noteProviderError(req.Provider, err, cfg, logger)
+		attempts = append(attempts, fallbackAttempt{Provider: req.Provider, Model: req.Model, Error: err.Error()})
+		logger.Warn("Provedor falhou, tentando provedor alternativo",
+			zap.String("provider", req.Provider),
+			zap.String("model", req.Model),
+			zap.String("fallback_provider", provider),
+			zap.String("fallback_model", model),
+			zap.String("category", string(utils.ErrorCategoryOf(err))),
+			zap.Error(err),
+		)
+
+		req.Provider, req.Model = provider, model
+		if len(req.ProviderParams) > 0 && catalog.ValidateProviderParams(provider, req.ProviderParams) == nil {
+			applyProviderParams(next, *req, logger)
+		}
+		if len(prompt.Attachments) > 0 || len(prompt.Tables) > 0 {
+			p, prepErr := prepare(next)
+			if prepErr != nil {
+				result.FallbackAttempts = attempts
+				return result, prepErr
+			}
+			*prompt = p
+		} else {
+			attachImages(next, *prompt, logger)
+		}
+
+		result, err = gen(ctx, next, *prompt)
+		recordProviderOutcome(ctx, provider, err)
+		if err == nil {
+			req.providerNotice = fmt.Sprintf("O provedor %s falhou; esta mensagem foi respondida por %s.", attempts[0].Provider, provider)
+			logger.Info("Requisição atendida pelo provedor alternativo",
+				zap.String("provider", provider),
+				zap.String("model", model),
+				zap.Int("failed_providers", len(attempts)),
+			)
+		}
+	}
+	result.FallbackAttempts = attempts
+	return result, err
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// activeProvider é o provedor padrão da conexão, definido por uma mensagem "switch_provider".
+type activeProvider struct {
+	Provider string
+	Model    string
+}
+
+// handleSwitchProvider troca o provedor padrão da conexão. O histórico continua sendo o
+// enviado pelo cliente, então a conversa segue intacta com o novo provedor; mensagens sem
+// "provider" passam a usar o provedor ativo. A troca vale só para esta conexão e não é gravada
+// com a sessão: após reconectar, o cliente precisa repeti-la. A resposta ecoa o provedor e o
+// modelo ativos e informa esse escopo.
+func (c *Client) handleSwitchProvider(req RequestPayload) {
+	provider := strings.ToUpper(strings.TrimSpace(req.Provider))
+	if provider == "" {
+		c.sendError("Informe o provedor para o qual deseja trocar.")
+		return
+	}
+
+	// Valida provedor e modelo criando o cliente, como em uma requisição comum
+	if _, err := c.llmManager.GetClient(provider, req.Model); err != nil {
+		c.sendError(err.Error())
+		return
+	}
+
+	c.mu.Lock()
+	previous := c.active
+	c.active = activeProvider{Provider: provider, Model: req.Model}
+	c.mu.Unlock()
+
+	c.logger.Info("Provedor da conexão alterado",
+		zap.String("client_id", c.id),
+		zap.String("from", previous.Provider),
+		zap.String("to", provider),
+		zap.String("model", req.Model),
+	)
+	c.sendJSON(ResponsePayload{
+		Type:     "provider_switched",
+		Status:   "ok",
+		Provider: provider,
+		Response: "Provedor alterado para esta conexão. Ao reconectar, envie switch_provider novamente.",
+		Metadata: map[string]interface{}{"model": req.Model, "scope": "connection"},
+	})
+}
+
+// applyActiveProvider usa o provedor ativo da conexão quando a mensagem não informa um e
+// registra trocas de provedor entre mensagens da mesma conversa.
+func (c *Client) applyActiveProvider(req *RequestPayload) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if req.Provider == "" && c.active.Provider != "" {
+		req.Provider = c.active.Provider
+		if req.Model == "" {
+			req.Model = c.active.Model
+		}
+	}
+	if req.Provider == "" {
+		return
+	}
+	if c.lastProvider != "" && !strings.EqualFold(c.lastProvider, req.Provider) {
+		c.logger.Info("Conversa continua com outro provedor",
+			zap.String("client_id", c.id),
+			zap.String("from", c.lastProvider),
+			zap.String("to", req.Provider),
+			zap.Int("history_length", len(req.History)),
+		)
+	}
+	c.lastProvider = req.Provider
+}
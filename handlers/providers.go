@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/llm/manager"
+	"go.uber.org/zap"
+)
+
+// providerInfo descreve um provedor configurado e os modelos do catálogo disponíveis nele.
+type providerInfo struct {
+	Provider    string      `json:"provider"`
+	DisplayName string      `json:"displayName"`
+	Models      []modelInfo `json:"models"`
+}
+
+type modelInfo struct {
+	ID             string `json:"id"`
+	MaxTokens      int    `json:"maxTokens"`
+	MaxImages      int    `json:"maxImages"`
+	SupportsVision bool   `json:"supportsVision"`
+	ContextWindow  int    `json:"contextWindow,omitempty"`
+}
+
+// ProvidersHandler lista os provedores configurados no gerenciador, em ordem alfabética, com
+// o nome exibido e os modelos do catálogo de cada um.
+func ProvidersHandler(llmManager manager.LLMManager, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providers := make([]providerInfo, 0)
+		for _, provider := range llmManager.ConfiguredProviders() {
+			info := providerInfo{
+				Provider:    provider,
+				DisplayName: catalog.DisplayName(provider),
+				Models:      make([]modelInfo, 0),
+			}
+			for _, meta := range catalog.Models(provider) {
+				info.Models = append(info.Models, modelInfo{
+					ID:             meta.ID,
+					MaxTokens:      meta.MaxTokens,
+					MaxImages:      meta.MaxImages,
+					SupportsVision: meta.SupportsVision,
+					ContextWindow:  meta.ContextWindow,
+				})
+			}
+			providers = append(providers, info)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(providers); err != nil {
+			logger.Error("Erro ao enviar lista de provedores", zap.Error(err))
+		}
+	}
+}
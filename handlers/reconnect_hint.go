@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Motivos informados na mensagem "reconnect".
+const (
+	reconnectIdleTimeout = "idle_timeout"
+	reconnectShutdown    = "shutdown"
+)
+
+// ReconnectPayload avisa que o servidor vai encerrar a conexão e sugere quanto esperar antes
+// de reconectar.
+type ReconnectPayload struct {
+	Type         string `json:"type"`
+	Reason       string `json:"reason"`
+	Message      string `json:"message"`
+	RetryAfterMs int64  `json:"retryAfterMs"`
+	// ResumeSession indica que respostas com sessionId podem ser retomadas após reconectar
+	ResumeSession bool `json:"resumeSession"`
+}
+
+// closeWithReconnect envia a dica de reconexão e encerra a conexão depois que ela for escrita.
+// O writePump envia o frame de fechamento ao encontrar a mensagem nil na fila de envio; se ele
+// não o fizer em 2*writeWait, a conexão é fechada mesmo assim.
+func (c *Client) closeWithReconnect(reason, message string) {
+	if !c.config.ReconnectHints {
+		c.close()
+		return
+	}
+
+	backoff := c.config.ReconnectBackoff
+	if reason == reconnectShutdown && backoff > 0 {
+		// Espalha as reconexões para que os clientes não voltem todos ao mesmo tempo
+		backoff += time.Duration(rand.Int63n(int64(backoff)))
+	}
+	c.sendJSON(ReconnectPayload{
+		Type:          "reconnect",
+		Reason:        reason,
+		Message:       message,
+		RetryAfterMs:  backoff.Milliseconds(),
+		ResumeSession: c.config.SessionResumeGrace > 0,
+	})
+
+	c.mu.Lock()
+	queued := false
+	if !c.closed {
+		select {
+		case c.send <- nil:
+			queued = true
+		default:
+		}
+	}
+	c.mu.Unlock()
+
+	if !queued {
+		c.close()
+		return
+	}
+	time.AfterFunc(2*writeWait, c.close)
+}
+
+// clientSet acompanha as conexões WebSocket abertas para o encerramento gracioso.
+type clientSet struct {
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+}
+
+var liveClients = &clientSet{clients: make(map[*Client]struct{})}
+
+func (s *clientSet) add(c *Client) {
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *clientSet) remove(c *Client) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+}
+
+func (s *clientSet) snapshot() []*Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := make([]*Client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// DrainWebSocketClients envia a dica de reconexão a todas as conexões abertas e aguarda que
+// sejam encerradas ou que ctx expire. Usado no desligamento do servidor.
+func DrainWebSocketClients(ctx context.Context, logger *zap.Logger) {
+	clients := liveClients.snapshot()
+	if len(clients) == 0 {
+		return
+	}
+	logger.Info("Encerrando conexões WebSocket", zap.Int("connections", len(clients)))
+
+	for _, c := range clients {
+		go c.closeWithReconnect(reconnectShutdown, "O servidor está reiniciando. Reconectando em instantes...")
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for len(liveClients.snapshot()) > 0 {
+		select {
+		case <-ctx.Done():
+			logger.Warn("Prazo de encerramento esgotado com conexões abertas",
+				zap.Int("connections", len(liveClients.snapshot())))
+			return
+		case <-ticker.C:
+		}
+	}
+}
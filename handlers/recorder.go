@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/webchatcomllm/llm/catalog"
+	llmclient "github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// Recording é uma requisição gravada junto com a resposta enviada ao cliente.
+type Recording struct {
+	RecordedAt time.Time       `json:"recordedAt"`
+	Request    RequestPayload  `json:"request"`
+	Response   ResponsePayload `json:"response"`
+	// PromptDebug é o prompt montado, gravado quando a resposta o inclui (RETURN_PROMPT_DEBUG)
+	PromptDebug *PromptDebug `json:"promptDebug,omitempty"`
+}
+
+// requestRecorder grava requisições e respostas em disco quando RECORD_REQUESTS está definido.
+// Um recorder nil não grava nada.
+type requestRecorder struct {
+	dir string
+	// gzipThreshold comprime os textos a partir deste tamanho (ver utils.CompressLogField)
+	gzipThreshold int
+	seq           atomic.Int64
+	logger        *zap.Logger
+}
+
+// newRequestRecorder cria o gravador; retorna nil se dir estiver vazio ou não puder ser criado.
+func newRequestRecorder(dir string, gzipThreshold int, logger *zap.Logger) *requestRecorder {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		logger.Error("Não foi possível criar o diretório de gravação, gravação desativada",
+			zap.String("dir", dir), zap.Error(err))
+		return nil
+	}
+	logger.Warn("Gravação de requisições ativada", zap.String("dir", dir))
+	return &requestRecorder{dir: dir, gzipThreshold: gzipThreshold, logger: logger}
+}
+
+// record grava a requisição e a resposta com dados pessoais redigidos.
+func (r *requestRecorder) record(req RequestPayload, resp ResponsePayload) {
+	if r == nil {
+		return
+	}
+
+	rec := Recording{
+		RecordedAt: time.Now().UTC(),
+		Request:    redactRequest(req),
+		Response:   resp,
+	}
+	rec.Response.Response = utils.RedactPII(resp.Response)
+	if debug, ok := resp.Metadata["promptDebug"].(PromptDebug); ok {
+		debug.Messages = append([]models.Message(nil), debug.Messages...)
+		rec.PromptDebug = &debug
+	}
+	rec.Response.Metadata = nil
+	rec.mapText(func(s string) (string, error) {
+		return utils.CompressLogField(s, r.gzipThreshold), nil
+	})
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		r.logger.Warn("Erro ao serializar gravação", zap.Error(err))
+		return
+	}
+
+	name := fmt.Sprintf("%s_%04d.json", rec.RecordedAt.Format("20060102T150405"), r.seq.Add(1))
+	if err := os.WriteFile(filepath.Join(r.dir, name), data, 0o640); err != nil {
+		r.logger.Warn("Erro ao gravar requisição", zap.String("file", name), zap.Error(err))
+	}
+}
+
+// redactRequest remove dados pessoais do prompt, do histórico e dos arquivos de texto.
+func redactRequest(req RequestPayload) RequestPayload {
+	req.Prompt = utils.RedactPII(req.Prompt)
+
+	history := make([]models.Message, len(req.History))
+	for i, msg := range req.History {
+		history[i] = models.Message{Role: msg.Role, Content: utils.RedactPII(msg.Content)}
+	}
+	req.History = history
+
+	files := make([]FilePayload, len(req.Files))
+	for i, file := range req.Files {
+		if !file.IsBase64 {
+			file.Content = utils.RedactPII(file.Content)
+		}
+		if _, ok := file.Metadata["password"]; ok {
+			metadata := make(map[string]interface{}, len(file.Metadata))
+			for k, v := range file.Metadata {
+				metadata[k] = v
+			}
+			metadata["password"] = "[REDACTED]"
+			file.Metadata = metadata
+		}
+		files[i] = file
+	}
+	req.Files = files
+	return req
+}
+
+// LoadRecording lê uma gravação feita com RECORD_REQUESTS.
+func LoadRecording(path string) (Recording, error) {
+	var rec Recording
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("gravação inválida %s: %w", path, err)
+	}
+	if err := rec.mapText(utils.DecompressLogField); err != nil {
+		return rec, fmt.Errorf("gravação inválida %s: %w", path, err)
+	}
+	return rec, nil
+}
+
+// mapText aplica fn aos textos potencialmente grandes da gravação: prompt, histórico, arquivos,
+// resposta e prompt montado.
+func (rec *Recording) mapText(fn func(string) (string, error)) error {
+	apply := func(s *string) error {
+		out, err := fn(*s)
+		if err != nil {
+			return err
+		}
+		*s = out
+		return nil
+	}
+
+	fields := []*string{&rec.Request.Prompt, &rec.Response.Response}
+	for i := range rec.Request.History {
+		fields = append(fields, &rec.Request.History[i].Content)
+	}
+	for i := range rec.Request.Files {
+		fields = append(fields, &rec.Request.Files[i].Content)
+	}
+	if rec.PromptDebug != nil {
+		fields = append(fields, &rec.PromptDebug.FullPrompt)
+		for i := range rec.PromptDebug.Messages {
+			fields = append(fields, &rec.PromptDebug.Messages[i].Content)
+		}
+	}
+	for _, field := range fields {
+		if err := apply(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplayRecording executa novamente a requisição gravada pelo mesmo fluxo do WebSocket,
+// com um provedor simulado que devolve a resposta (ou o erro) original.
+func ReplayRecording(rec Recording, logger *zap.Logger) ResponsePayload {
+	config := DefaultHandlerConfig()
+	c := &Client{
+		send:          make(chan []byte, 1024),
+		done:          make(chan struct{}),
+		llmManager:    replayManager{recorded: rec.Response},
+		fileProcessor: utils.NewFileProcessor(logger).WithConfig(config.FileProcessing),
+		config:        config,
+		logger:        logger,
+		lastActivity:  time.Now(),
+	}
+	return c.generateResponse(context.Background(), rec.Request, c)
+}
+
+// replayManager fornece o provedor simulado usado na reprodução.
+type replayManager struct {
+	recorded ResponsePayload
+}
+
+func (m replayManager) GetClient(provider, model string) (llmclient.LLMClient, error) {
+	// Erros de validação do provedor são reproduzidos na própria seleção do cliente
+	if m.recorded.Status == "error" && m.recorded.ErrorCategory == utils.ErrorCategoryClient {
+		return nil, errors.New(m.recorded.Response)
+	}
+	return replayClient{recorded: m.recorded}, nil
+}
+
+// ConfiguredProviders aceita todos os provedores, já que nenhum é chamado de fato.
+func (m replayManager) ConfiguredProviders() []string {
+	return catalog.Providers()
+}
+
+// replayClient devolve a resposta gravada em vez de chamar um provedor real.
+type replayClient struct {
+	recorded ResponsePayload
+}
+
+func (c replayClient) SendPrompt(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, error) {
+	if c.recorded.Status == "error" {
+		message := strings.TrimPrefix(c.recorded.Response, llmErrorPrefix)
+		return "", &utils.CategorizedError{Category: c.recorded.ErrorCategory, Err: errors.New(message)}
+	}
+	return c.recorded.Response, nil
+}
+
+func (c replayClient) GetModelName() string {
+	return "replay"
+}
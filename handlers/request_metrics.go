@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/metrics"
+	"github.com/webchatcomllm/utils"
+)
+
+var (
+	llmRequestsTotal    = metrics.Default.Counter("llm_requests_total", "Requisições de chat concluídas, por provedor e status")
+	filesProcessedTotal = metrics.Default.Counter("files_processed_total", "Arquivos recebidos, por tipo e resultado do processamento")
+)
+
+// metricUnknown agrupa os valores de label fora do conjunto conhecido, para que valores
+// enviados pelo cliente não criem séries sem limite.
+const metricUnknown = "unknown"
+
+// knownFileTypes são os tipos de utils.FileType aceitos na label type.
+var knownFileTypes = map[string]bool{
+	string(utils.FileTypeText): true, string(utils.FileTypeImage): true, string(utils.FileTypePDF): true,
+	string(utils.FileTypeDocx): true, string(utils.FileTypeXlsx): true, string(utils.FileTypeCode): true,
+	string(utils.FileTypeMarkdown): true, string(utils.FileTypeYAML): true, string(utils.FileTypeJSON): true,
+	string(utils.FileTypeXML): true, string(utils.FileTypeCSV): true, string(utils.FileTypeDiff): true,
+	string(utils.FileTypeLog): true, string(utils.FileTypeBinary): true,
+}
+
+// metricProvider normaliza o provedor da requisição (aliases e maiúsculas) para a label
+// provider; provedores fora do catálogo viram "unknown".
+func metricProvider(provider string) string {
+	p := catalog.ResolveProvider(provider)
+	if !slices.Contains(catalog.Providers(), p) {
+		return metricUnknown
+	}
+	return p
+}
+
+// recordRequest contabiliza a requisição concluída pelo status da resposta (completed ou error).
+func recordRequest(req RequestPayload, resp ResponsePayload) {
+	llmRequestsTotal.Inc(metrics.Labels{"provider": metricProvider(req.Provider), "status": resp.Status})
+}
+
+// recordFile contabiliza um arquivo recebido pelo tipo identificado no processamento. Antes
+// dele, o tipo informado pelo cliente só é usado se for um tipo conhecido.
+func recordFile(fileType, result string) {
+	if !knownFileTypes[fileType] {
+		fileType = metricUnknown
+	}
+	filesProcessedTotal.Inc(metrics.Labels{"type": fileType, "result": result})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// RequestProcessor executa lógica customizada sobre cada requisição de chat antes do envio
+// ao provedor. Pode alterar o payload (enriquecer contexto, reescrever o prompt) ou recusá-lo
+// retornando um erro, cuja mensagem é exibida ao usuário.
+type RequestProcessor interface {
+	Process(ctx context.Context, req *RequestPayload) error
+}
+
+// RequestProcessorFunc adapta uma função ao RequestProcessor.
+type RequestProcessorFunc func(ctx context.Context, req *RequestPayload) error
+
+func (f RequestProcessorFunc) Process(ctx context.Context, req *RequestPayload) error {
+	return f(ctx, req)
+}
+
+// NoopRequestProcessor não altera a requisição. É a cadeia padrão.
+type NoopRequestProcessor struct{}
+
+func (NoopRequestProcessor) Process(context.Context, *RequestPayload) error { return nil }
+
+// RequestProcessorChain executa os processadores em ordem, parando no primeiro erro.
+type RequestProcessorChain []RequestProcessor
+
+func (c RequestProcessorChain) Process(ctx context.Context, req *RequestPayload) error {
+	for _, p := range c {
+		if err := p.Process(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	requestProcessorsMu sync.RWMutex
+	requestProcessors   = map[string]RequestProcessor{
+		"noop": NoopRequestProcessor{},
+	}
+)
+
+// RegisterRequestProcessor disponibiliza um processador pelo nome para uso em REQUEST_PROCESSORS.
+// Deve ser chamado antes da criação dos handlers, tipicamente em main.
+func RegisterRequestProcessor(name string, p RequestProcessor) {
+	requestProcessorsMu.Lock()
+	defer requestProcessorsMu.Unlock()
+	requestProcessors[strings.ToLower(strings.TrimSpace(name))] = p
+}
+
+// buildRequestProcessorChain monta a cadeia com os processadores registrados, na ordem
+// informada. Nomes desconhecidos são ignorados com um aviso.
+func buildRequestProcessorChain(names []string, logger *zap.Logger) RequestProcessorChain {
+	requestProcessorsMu.RLock()
+	defer requestProcessorsMu.RUnlock()
+
+	chain := make(RequestProcessorChain, 0, len(names))
+	for _, name := range names {
+		p, ok := requestProcessors[strings.ToLower(name)]
+		if !ok {
+			logger.Warn("Processador de requisição desconhecido ignorado", zap.String("name", name))
+			continue
+		}
+		chain = append(chain, p)
+	}
+	if len(chain) > 0 {
+		logger.Info("Cadeia de processadores de requisição configurada", zap.Strings("processors", names))
+	}
+	return chain
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultLLMRequestTimeout é usado quando LLM_REQUEST_TIMEOUT não define um valor válido.
+const defaultLLMRequestTimeout = 5 * time.Minute
+
+// maxTimeoutSeconds é o maior timeoutSeconds representável como time.Duration.
+const maxTimeoutSeconds = math.MaxInt64 / int64(time.Second)
+
+// requestTimeout retorna o timeout da chamada ao LLM: o timeoutSeconds da requisição, quando
+// informado, limitado a LLMMaxRequestTimeout; caso contrário, LLMRequestTimeout.
+func requestTimeout(req RequestPayload, cfg HandlerConfig, logger *zap.Logger) time.Duration {
+	timeout, clamped := effectiveRequestTimeout(req, cfg)
+	if clamped {
+		logger.Debug("timeoutSeconds acima do máximo permitido, limitado",
+			zap.Int("requested_seconds", req.TimeoutSeconds),
+			zap.Duration("max", cfg.LLMMaxRequestTimeout),
+		)
+	}
+	return timeout
+}
+
+// effectiveRequestTimeout calcula o timeout de requestTimeout e indica se o timeoutSeconds da
+// requisição foi reduzido ao máximo permitido.
+func effectiveRequestTimeout(req RequestPayload, cfg HandlerConfig) (time.Duration, bool) {
+	timeout := cfg.LLMRequestTimeout
+	if timeout <= 0 {
+		timeout = defaultLLMRequestTimeout
+	}
+	if req.TimeoutSeconds <= 0 {
+		return timeout, false
+	}
+
+	// A comparação é feita em segundos, antes da multiplicação, que transbordaria com valores
+	// enormes e resultaria em um timeout negativo ou curto demais
+	seconds := int64(req.TimeoutSeconds)
+	if cfg.LLMMaxRequestTimeout > 0 && seconds > int64(cfg.LLMMaxRequestTimeout/time.Second) {
+		return cfg.LLMMaxRequestTimeout, true
+	}
+	if seconds > maxTimeoutSeconds {
+		seconds = maxTimeoutSeconds
+	}
+	return time.Duration(seconds) * time.Second, false
+}
+
+// fileProcessingTimeout retorna o orçamento de tempo dos arquivos: FileProcessingTimeout, ou o
+// timeoutSeconds da requisição (já limitado ao máximo) quando este for menor. Assim o cliente
+// pode encurtar o processamento, mas nunca estendê-lo além do configurado no servidor.
+func fileProcessingTimeout(req RequestPayload, cfg HandlerConfig) time.Duration {
+	if req.TimeoutSeconds <= 0 {
+		return cfg.FileProcessingTimeout
+	}
+	requested, _ := effectiveRequestTimeout(req, cfg)
+	if cfg.FileProcessingTimeout > 0 && cfg.FileProcessingTimeout < requested {
+		return cfg.FileProcessingTimeout
+	}
+	return requested
+}
+
+// requestTimedOut indica se a chamada falhou por ter excedido o timeout da requisição, e não
+// por um erro do provedor ou pela desconexão do cliente.
+func requestTimedOut(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// timeoutMessage explica ao usuário que o provedor não respondeu a tempo.
+func timeoutMessage(timeout time.Duration) string {
+	return fmt.Sprintf("O provedor não respondeu dentro do tempo limite de %s. Tente uma pergunta mais curta, "+
+		"envie menos arquivos ou aumente timeoutSeconds na requisição.", timeout)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/webchatcomllm/utils"
+)
+
+// startTimings ativa a medição por fases da requisição quando DEBUG_TIMINGS está ligado.
+func startTimings(ctx context.Context, cfg HandlerConfig) context.Context {
+	if !cfg.DebugTimings {
+		return ctx
+	}
+	return utils.WithRequestTimings(ctx, utils.NewRequestTimings())
+}
+
+// recordPhase soma à fase o tempo decorrido desde start.
+func recordPhase(ctx context.Context, phase string, start time.Time) {
+	utils.RecordPhase(ctx, phase, time.Since(start))
+}
+
+// attachTimings inclui as fases medidas, com o total desde start, nos metadados da resposta.
+func attachTimings(ctx context.Context, resp *ResponsePayload, start time.Time) {
+	timings := utils.RequestTimingsFrom(ctx)
+	if timings == nil {
+		return
+	}
+	timings.Add(utils.PhaseTotal, time.Since(start))
+	if resp.Metadata == nil {
+		resp.Metadata = make(map[string]interface{})
+	}
+	resp.Metadata["timings"] = timings.Milliseconds()
+}
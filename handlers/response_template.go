@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	llmclient "github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/models"
+	"go.uber.org/zap"
+)
+
+const (
+	TemplateFormatJSON     = "json"
+	TemplateFormatXML      = "xml"
+	TemplateFormatKeyValue = "keyvalue"
+)
+
+// ResponseTemplate descreve o formato estruturado exigido da resposta do modelo.
+type ResponseTemplate struct {
+	// Format é "json", "xml" ou "keyvalue" (linhas "chave: valor")
+	Format string `json:"format"`
+	// Fields lista as chaves/elementos obrigatórios
+	Fields []string `json:"fields,omitempty"`
+	// Root é o elemento raiz esperado nas respostas XML
+	Root string `json:"root,omitempty"`
+	// Example é um exemplo da resposta esperada, repassado ao modelo
+	Example string `json:"example,omitempty"`
+}
+
+var (
+	codeFencePattern = regexp.MustCompile("(?s)^\\s*```[\\w-]*\\n(.*?)\\n?```\\s*$")
+	keyValuePattern  = regexp.MustCompile(`^\s*([^:=]+?)\s*[:=]\s*(.*)$`)
+)
+
+// validate verifica se o template pode ser aplicado.
+func (t *ResponseTemplate) validate() error {
+	switch strings.ToLower(t.Format) {
+	case TemplateFormatJSON, TemplateFormatXML, TemplateFormatKeyValue:
+		return nil
+	default:
+		return fmt.Errorf("formato de template inválido: %q (use json, xml ou keyvalue)", t.Format)
+	}
+}
+
+// instruction gera as instruções de formatação acrescentadas ao prompt de sistema.
+func (t *ResponseTemplate) instruction() string {
+	var b strings.Builder
+	switch strings.ToLower(t.Format) {
+	case TemplateFormatJSON:
+		b.WriteString("Responda exclusivamente com um objeto JSON válido, sem texto antes ou depois e sem blocos de código.")
+		if len(t.Fields) > 0 {
+			b.WriteString(fmt.Sprintf(" O objeto deve conter as chaves: %s.", strings.Join(t.Fields, ", ")))
+		}
+	case TemplateFormatXML:
+		b.WriteString("Responda exclusivamente com um documento XML bem formado, sem texto antes ou depois e sem blocos de código.")
+		if t.Root != "" {
+			b.WriteString(fmt.Sprintf(" O elemento raiz deve ser <%s>.", t.Root))
+		}
+		if len(t.Fields) > 0 {
+			b.WriteString(fmt.Sprintf(" Inclua os elementos: %s.", strings.Join(t.Fields, ", ")))
+		}
+	case TemplateFormatKeyValue:
+		b.WriteString("Responda exclusivamente com linhas no formato \"chave: valor\", uma por linha, sem outros textos.")
+		if len(t.Fields) > 0 {
+			b.WriteString(fmt.Sprintf(" Use as chaves: %s.", strings.Join(t.Fields, ", ")))
+		}
+	}
+	if t.Example != "" {
+		b.WriteString("\nExemplo de resposta:\n")
+		b.WriteString(t.Example)
+	}
+	return b.String()
+}
+
+// matches verifica, em melhor esforço, se a resposta segue a estrutura do template.
+func (t *ResponseTemplate) matches(response string) bool {
+	body := strings.TrimSpace(response)
+	if m := codeFencePattern.FindStringSubmatch(body); m != nil {
+		body = strings.TrimSpace(m[1])
+	}
+
+	switch strings.ToLower(t.Format) {
+	case TemplateFormatJSON:
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &obj); err != nil {
+			return false
+		}
+		for _, field := range t.Fields {
+			if _, ok := obj[field]; !ok {
+				return false
+			}
+		}
+		return true
+
+	case TemplateFormatXML:
+		elements, root, ok := xmlElements(body)
+		if !ok || (t.Root != "" && root != t.Root) {
+			return false
+		}
+		for _, field := range t.Fields {
+			if !elements[field] {
+				return false
+			}
+		}
+		return true
+
+	case TemplateFormatKeyValue:
+		keys := make(map[string]bool)
+		for _, line := range strings.Split(body, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			m := keyValuePattern.FindStringSubmatch(line)
+			if m == nil {
+				return false
+			}
+			keys[strings.ToLower(m[1])] = true
+		}
+		if len(keys) == 0 {
+			return false
+		}
+		for _, field := range t.Fields {
+			if !keys[strings.ToLower(field)] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// xmlElements percorre o documento e retorna os nomes dos elementos, o elemento raiz
+// e se o XML é bem formado com uma única raiz.
+func xmlElements(body string) (map[string]bool, string, bool) {
+	decoder := xml.NewDecoder(strings.NewReader(body))
+	elements := make(map[string]bool)
+	root := ""
+	depth := 0
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", false
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if root != "" {
+					return nil, "", false
+				}
+				root = el.Name.Local
+			}
+			elements[el.Name.Local] = true
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && strings.TrimSpace(string(el)) != "" {
+				return nil, "", false
+			}
+		}
+	}
+	return elements, root, root != ""
+}
+
+// sendWithTemplate envia o prompt e, se a resposta não seguir o template, pede uma única
+// correção ao modelo. O segundo retorno indica se a resposta final segue o template.
+func sendWithTemplate(ctx context.Context, llmClient llmclient.LLMClient, prompt preparedPrompt, history []models.Message, tmpl *ResponseTemplate, logger *zap.Logger) (llmResult, bool, error) {
+	result, err := sendToLLM(ctx, llmClient, prompt, history, nil)
+	if err != nil || tmpl.matches(result.Response) {
+		return result, err == nil, err
+	}
+
+	logger.Info("Resposta fora do template, solicitando correção", zap.String("format", tmpl.Format))
+
+	retryHistory := append(append([]models.Message{}, history...),
+		models.Message{Role: "user", Content: prompt.FullPrompt},
+		models.Message{Role: "assistant", Content: result.Response},
+	)
+	retryPrompt := preparedPrompt{
+		FullPrompt: "A resposta anterior não seguiu o formato exigido. Responda novamente seguindo estritamente estas instruções:\n" + tmpl.instruction(),
+	}
+
+	retry, err := sendToLLM(ctx, llmClient, retryPrompt, retryHistory, nil)
+	if err != nil {
+		logger.Warn("Falha na correção do template, mantendo a primeira resposta", zap.Error(err))
+		return result, false, nil
+	}
+	retry.Citations = append(result.Citations, retry.Citations...)
+	return retry, tmpl.matches(retry.Response), nil
+}
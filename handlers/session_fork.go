@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/store"
+	"go.uber.org/zap"
+)
+
+// handleFork atende {"type": "fork", "sessionId": ..., "turnIndex": N}: cria uma nova sessão
+// com o histórico da sessão original até o turno N (inclusive) e responde com o ID gerado. A
+// sessão original não é alterada, e a nova é independente dela daí em diante.
+func (c *Client) handleFork(req RequestPayload) {
+	if c.conversations == nil {
+		c.sendError("Armazenamento de conversas indisponível.")
+		return
+	}
+	// Só a partir de IDs emitidos pelo servidor: conhecer o ID é o que dá acesso à sessão
+	if !store.IssuedSessionID(req.SessionID) {
+		c.sendError("Informe um sessionId emitido pelo servidor para criar a ramificação.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	msgs, err := c.conversations.Load(ctx, req.SessionID)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		c.sendError("Sessão não encontrada.")
+		return
+	}
+	if err != nil {
+		c.logger.Error("Erro ao carregar sessão para ramificação", zap.String("session_id", req.SessionID), zap.Error(err))
+		c.sendError("Erro ao carregar a sessão.")
+		return
+	}
+
+	// Sessões com mensagens regeneradas são ramificadas a partir do ramo atual
+	msgs, _ = store.Branch(msgs, "")
+	turns := countTurns(msgs)
+	if req.TurnIndex != nil {
+		if *req.TurnIndex < 0 || *req.TurnIndex >= turns {
+			c.sendError(fmt.Sprintf("turnIndex inválido: a sessão tem %d turno(s) (0 a %d).", turns, turns-1))
+			return
+		}
+		msgs = historyUpToTurn(msgs, *req.TurnIndex)
+		turns = *req.TurnIndex + 1
+	}
+
+	forkID, err := store.NewSessionID()
+	if err == nil {
+		err = c.conversations.Save(ctx, forkID, msgs)
+	}
+	if err != nil {
+		c.logger.Error("Erro ao criar ramificação da sessão", zap.String("session_id", req.SessionID), zap.Error(err))
+		c.sendError("Erro ao criar a ramificação da conversa.")
+		return
+	}
+
+	c.logger.Info("Sessão ramificada",
+		zap.String("client_id", c.id),
+		zap.String("parent_session_id", req.SessionID),
+		zap.String("session_id", forkID),
+		zap.Int("turns", turns),
+	)
+	c.sendJSON(ResponsePayload{
+		Type:   "forked",
+		Status: "ok",
+		Metadata: map[string]interface{}{
+			"sessionId":       forkID,
+			"parentSessionId": req.SessionID,
+			"turns":           turns,
+			"messages":        len(msgs),
+		},
+	})
+}
+
+// countTurns conta os turnos do histórico: cada mensagem do usuário inicia um turno.
+func countTurns(msgs []models.Message) int {
+	turns := 0
+	for _, msg := range msgs {
+		if msg.Role == "user" {
+			turns++
+		}
+	}
+	return turns
+}
+
+// historyUpToTurn retorna as mensagens até o fim do turno indicado, antes da próxima mensagem
+// do usuário. Mensagens anteriores ao primeiro turno (ex.: system) são mantidas.
+func historyUpToTurn(msgs []models.Message, turnIndex int) []models.Message {
+	turn := -1
+	for i, msg := range msgs {
+		if msg.Role == "user" {
+			turn++
+			if turn > turnIndex {
+				return msgs[:i]
+			}
+		}
+	}
+	return msgs
+}
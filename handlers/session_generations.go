@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/webchatcomllm/store"
+	"go.uber.org/zap"
+)
+
+// Estados informados ao cliente na resposta de uma mensagem "resume".
+const (
+	resumeStatusRunning   = "running"   // a geração continua e a resposta será entregue nesta conexão
+	resumeStatusDelivered = "delivered" // a resposta já estava pronta e foi reenviada
+	resumeStatusNone      = "none"      // nada pendente para a sessão
+)
+
+// sessionGeneration é uma geração em andamento vinculada a um sessionId. Ela sobrevive à
+// conexão que a iniciou: se o cliente reconectar dentro do prazo de retomada, a resposta é
+// entregue na nova conexão; caso contrário, a geração é cancelada.
+type sessionGeneration struct {
+	sessionID string
+	cancel    context.CancelFunc
+
+	mu       sync.Mutex
+	owner    *Client
+	orphaned *time.Timer
+	result   *ResponsePayload // resposta pronta aguardando reconexão
+	canceled bool
+}
+
+// sendProgress repassa o progresso à conexão atual da sessão, se houver.
+func (g *sessionGeneration) sendProgress(message string, current, total, percentage int, eta time.Duration) {
+	g.mu.Lock()
+	owner := g.owner
+	g.mu.Unlock()
+	if owner != nil {
+		owner.sendProgress(message, current, total, percentage, eta)
+	}
+}
+
+// generationRegistry acompanha as gerações ativas por sessão, compartilhado entre conexões.
+type generationRegistry struct {
+	mu        sync.Mutex
+	bySession map[string]*sessionGeneration
+	grace     time.Duration
+	logger    *zap.Logger
+}
+
+func newGenerationRegistry(grace time.Duration, logger *zap.Logger) *generationRegistry {
+	return &generationRegistry{
+		bySession: make(map[string]*sessionGeneration),
+		grace:     grace,
+		logger:    logger,
+	}
+}
+
+// start registra uma nova geração para a sessão. Uma geração anterior da mesma sessão ainda em
+// andamento é cancelada, já que a nova mensagem a substitui.
+func (r *generationRegistry) start(sessionID string, owner *Client) (context.Context, *sessionGeneration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	gen := &sessionGeneration{sessionID: sessionID, cancel: cancel, owner: owner}
+
+	r.mu.Lock()
+	previous := r.bySession[sessionID]
+	r.bySession[sessionID] = gen
+	r.mu.Unlock()
+
+	if previous != nil {
+		r.logger.Info("Geração anterior da sessão cancelada por nova mensagem",
+			zap.String("session_id", sessionID))
+		previous.stop()
+	}
+	return ctx, gen
+}
+
+// finish entrega a resposta à conexão atual da sessão. Sem conexão, a resposta fica guardada
+// até a reconexão ou o fim do prazo de retomada. Gerações canceladas não são entregues.
+func (r *generationRegistry) finish(gen *sessionGeneration, response ResponsePayload) {
+	// A geração terminou; cancelar apenas libera os recursos do contexto
+	gen.cancel()
+
+	gen.mu.Lock()
+	if gen.canceled {
+		gen.mu.Unlock()
+		r.logger.Debug("Resposta de geração cancelada descartada", zap.String("session_id", gen.sessionID))
+		return
+	}
+	owner := gen.owner
+	if owner == nil || owner.isClosed() {
+		// A conexão pode ter fechado sem que detach tenha passado por esta geração ainda; o
+		// prazo de retomada é agendado aqui para que a resposta guardada não fique para sempre
+		gen.owner = nil
+		gen.result = &response
+		r.orphanLocked(gen)
+		gen.mu.Unlock()
+		r.logger.Info("Resposta guardada até a reconexão da sessão", zap.String("session_id", gen.sessionID))
+		return
+	}
+	gen.mu.Unlock()
+
+	r.remove(gen)
+	owner.sendJSON(response)
+}
+
+// detach desvincula as gerações da conexão encerrada e agenda o cancelamento para quando o
+// prazo de retomada expirar sem reconexão.
+func (r *generationRegistry) detach(owner *Client) {
+	r.mu.Lock()
+	var orphans []*sessionGeneration
+	for _, gen := range r.bySession {
+		gen.mu.Lock()
+		if gen.owner == owner {
+			gen.owner = nil
+			orphans = append(orphans, gen)
+		}
+		gen.mu.Unlock()
+	}
+	r.mu.Unlock()
+
+	for _, gen := range orphans {
+		gen.mu.Lock()
+		r.orphanLocked(gen)
+		gen.mu.Unlock()
+	}
+}
+
+// orphanLocked agenda o cancelamento da geração sem conexão ao fim do prazo de retomada, se
+// ainda não agendado. Deve ser chamado com gen.mu travado.
+func (r *generationRegistry) orphanLocked(gen *sessionGeneration) {
+	if gen.orphaned != nil || gen.canceled {
+		return
+	}
+	r.logger.Info("Geração sem conexão aguardando retomada",
+		zap.String("session_id", gen.sessionID),
+		zap.Duration("grace", r.grace))
+	gen.orphaned = time.AfterFunc(r.grace, func() {
+		r.logger.Info("Prazo de retomada expirado, geração cancelada", zap.String("session_id", gen.sessionID))
+		r.remove(gen)
+		gen.stop()
+	})
+}
+
+// resume vincula a geração da sessão à nova conexão, reenviando a resposta se já estiver pronta.
+func (r *generationRegistry) resume(sessionID string, owner *Client) string {
+	r.mu.Lock()
+	gen := r.bySession[sessionID]
+	r.mu.Unlock()
+	if gen == nil {
+		return resumeStatusNone
+	}
+
+	gen.mu.Lock()
+	if gen.canceled {
+		gen.mu.Unlock()
+		return resumeStatusNone
+	}
+	if gen.orphaned != nil {
+		gen.orphaned.Stop()
+		gen.orphaned = nil
+	}
+	result := gen.result
+	gen.owner = owner
+	gen.mu.Unlock()
+
+	if result == nil {
+		return resumeStatusRunning
+	}
+	r.remove(gen)
+	owner.sendJSON(*result)
+	return resumeStatusDelivered
+}
+
+// remove retira a geração do registro, se ela ainda for a atual da sessão.
+func (r *generationRegistry) remove(gen *sessionGeneration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.bySession[gen.sessionID] == gen {
+		delete(r.bySession, gen.sessionID)
+	}
+}
+
+// stop cancela a geração e descarta a resposta guardada.
+func (g *sessionGeneration) stop() {
+	g.mu.Lock()
+	g.canceled = true
+	g.result = nil
+	if g.orphaned != nil {
+		g.orphaned.Stop()
+	}
+	g.mu.Unlock()
+	g.cancel()
+}
+
+// handleResume atende {"type": "resume", "sessionId": ...}, enviado pelo cliente ao reconectar.
+// Só IDs emitidos pelo servidor são aceitos: quem conhece o ID é o dono da sessão, e um ID
+// inventado não pode assumir a geração de outra conexão.
+func (c *Client) handleResume(req RequestPayload) {
+	if req.SessionID == "" {
+		c.sendError("Informe o sessionId da conversa a retomar.")
+		return
+	}
+	if !store.IssuedSessionID(req.SessionID) {
+		c.logger.Warn("Retomada recusada: ID de sessão não emitido pelo servidor", zap.String("client_id", c.id))
+		c.sendError("ID de sessão inválido. Use um ID emitido pelo servidor em POST /api/sessions.")
+		return
+	}
+	status := c.generations.resume(req.SessionID, c)
+	c.logger.Info("Retomada de sessão",
+		zap.String("client_id", c.id),
+		zap.String("session_id", req.SessionID),
+		zap.String("status", status))
+	c.sendJSON(ResponsePayload{Type: "resume", Status: status})
+}
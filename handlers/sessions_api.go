@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/store"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// sessionResponse é o histórico gravado de uma sessão.
+type sessionResponse struct {
+	SessionID string           `json:"sessionId"`
+	Messages  []models.Message `json:"messages"`
+}
+
+// NewSessionHandler cria o handler de POST /api/sessions, que emite um novo ID de sessão. Só
+// IDs emitidos pelo servidor são aceitos em sessionId, e conhecê-lo dá acesso à sessão.
+func NewSessionHandler(logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID, err := store.NewSessionID()
+		if err != nil {
+			logger.Error("Erro ao emitir ID de sessão", zap.Error(err))
+			writeAPIError(w, http.StatusInternalServerError, "Erro ao criar a sessão.", utils.ErrorCategoryServer)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sessionResponse{SessionID: sessionID, Messages: []models.Message{}})
+	}
+}
+
+// SessionsAPIHandler cria o handler de GET /api/sessions/{id}, que retorna o histórico gravado
+// da sessão. IDs não emitidos pelo servidor recebem 404, como sessões inexistentes.
+func SessionsAPIHandler(conversations store.ConversationStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID := r.PathValue("id")
+		if !store.IssuedSessionID(sessionID) {
+			writeAPIError(w, http.StatusNotFound, "Sessão não encontrada.", utils.ErrorCategoryClient)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		msgs, err := conversations.Load(ctx, sessionID)
+		if errors.Is(err, store.ErrSessionNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Sessão não encontrada.", utils.ErrorCategoryClient)
+			return
+		}
+		if err != nil {
+			logger.Error("Erro ao carregar sessão", zap.String("session_id", sessionID), zap.Error(err))
+			writeAPIError(w, http.StatusInternalServerError, "Erro ao carregar a sessão.", utils.ErrorCategoryServer)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sessionResponse{SessionID: sessionID, Messages: msgs})
+	}
+}
+
+// loadSessionHistory preenche o histórico de uma requisição com sessionId e sem histórico com o
+// histórico gravado da sessão, permitindo continuar a conversa após recarregar a página. Com
+// parentMessageId, usa apenas o ramo que termina nessa mensagem. Sem sessão gravada, com um ID
+// não emitido pelo servidor, ou em caso de erro, a requisição segue sem histórico.
+func loadSessionHistory(conversations store.ConversationStore, req *RequestPayload, logger *zap.Logger) {
+	if conversations == nil || req.SessionID == "" || len(req.History) > 0 {
+		return
+	}
+	// validateChatRequest já recusa esses IDs; a verificação protege outros chamadores
+	if !store.IssuedSessionID(req.SessionID) {
+		logger.Warn("ID de sessão não emitido pelo servidor, histórico não carregado")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	msgs, err := conversations.Load(ctx, req.SessionID)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return
+	}
+	if err != nil {
+		logger.Error("Erro ao carregar histórico da sessão", zap.String("session_id", req.SessionID), zap.Error(err))
+		return
+	}
+	branch, ok := store.Branch(msgs, req.ParentMessageID)
+	if !ok {
+		logger.Warn("Mensagem de origem não encontrada na sessão, usando o histórico completo",
+			zap.String("session_id", req.SessionID),
+			zap.String("parent_message_id", req.ParentMessageID),
+		)
+		branch, _ = store.Branch(msgs, "")
+	}
+	logger.Debug("Histórico carregado da sessão", zap.String("session_id", req.SessionID), zap.Int("messages", len(branch)))
+	req.History = branch
+}
+
+// assignMessageIDs identifica a resposta com um ID aleatório. Quando o turno é gravado em uma
+// sessão, também gera o ID da pergunta e informa a conversa, para que o cliente possa
+// referenciar as mensagens em parentMessageId.
+func assignMessageIDs(conversations store.ConversationStore, req *RequestPayload, response *ResponsePayload, logger *zap.Logger) {
+	messageID, err := store.NewMessageID()
+	if err != nil {
+		logger.Error("Erro ao gerar ID da resposta", zap.Error(err))
+		return
+	}
+	response.MessageID = messageID
+	if conversations == nil || req.SessionID == "" || response.Status != "completed" {
+		return
+	}
+	promptID, err := store.NewMessageID()
+	if err != nil {
+		logger.Error("Erro ao gerar ID da pergunta", zap.Error(err))
+		return
+	}
+	req.promptMessageID = promptID
+	response.ConversationID = req.SessionID
+	response.PromptMessageID = promptID
+}
+
+// persistTurn grava a pergunta e a resposta de uma requisição concluída na sessão informada.
+// Falhas são apenas registradas: a resposta já foi gerada e é entregue normalmente. IDs não
+// emitidos pelo servidor não são gravados.
+func persistTurn(conversations store.ConversationStore, req RequestPayload, response ResponsePayload, logger *zap.Logger) {
+	if conversations == nil || req.SessionID == "" || response.Status != "completed" {
+		return
+	}
+	if !store.IssuedSessionID(req.SessionID) {
+		logger.Warn("ID de sessão não emitido pelo servidor, turno não gravado")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := conversations.Append(ctx, req.SessionID,
+		models.Message{Role: "user", Content: req.Prompt, ID: req.promptMessageID, ParentID: req.ParentMessageID},
+		models.Message{Role: "assistant", Content: response.Response, ID: response.MessageID, ParentID: req.promptMessageID},
+	)
+	if err != nil {
+		logger.Error("Erro ao gravar turno da conversa", zap.String("session_id", req.SessionID), zap.Error(err))
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/webchatcomllm/metrics"
+	"go.uber.org/zap"
+)
+
+var slowRequestsTotal = metrics.Default.Counter("llm_slow_requests_total", "Requisições que excederam SLOW_REQUEST_MS")
+
+// logSlowRequest registra com nível warn as requisições concluídas acima de SlowRequestThreshold,
+// com o contexto necessário para investigar a latência.
+func logSlowRequest(req RequestPayload, resp ResponsePayload, elapsed time.Duration, cfg HandlerConfig, logger *zap.Logger) {
+	if cfg.SlowRequestThreshold <= 0 || elapsed < cfg.SlowRequestThreshold {
+		return
+	}
+	slowRequestsTotal.Inc(metrics.Labels{"provider": metricProvider(req.Provider)})
+	logger.Warn("Requisição lenta",
+		zap.Bool("slow_request", true),
+		zap.String("provider", req.Provider),
+		zap.String("model", req.Model),
+		zap.Duration("duration", elapsed),
+		zap.Duration("threshold", cfg.SlowRequestThreshold),
+		zap.String("status", resp.Status),
+		zap.Int("files_count", len(req.Files)),
+		zap.Int("prompt_tokens", resp.PromptTokens),
+		zap.Int("completion_tokens", resp.CompletionTokens),
+		zap.Int("total_tokens", resp.TotalTokens),
+	)
+}
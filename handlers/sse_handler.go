@@ -14,20 +14,38 @@ import (
 	"go.uber.org/zap"
 )
 
+// ChatStreamPath é a rota do chat via Server-Sent Events, alternativa ao WebSocket para redes
+// cujos proxies bloqueiam o upgrade.
+const ChatStreamPath = "/api/chat/stream"
+
+// ChatStreamHandler cria o handler de POST /api/chat/stream. Ele aceita o mesmo payload do chat
+// e sempre responde em text/event-stream: "progress" durante o processamento dos arquivos,
+// "chunk" para cada trecho gerado e "done" (ou "error") ao final. A desconexão do cliente
+// cancela o contexto da requisição e, com ele, a chamada ao provedor.
+func ChatStreamHandler(llmManager manager.LLMManager, logger *zap.Logger) http.HandlerFunc {
+	api := newChatAPI(llmManager, logger)
+	api.stream = true
+	return api.serveHTTP
+}
+
 type ClientV2 struct {
 	id            string
 	managedConn   *utils.ManagedConnection
 	llmManager    manager.LLMManager
 	fileProcessor *utils.FileProcessor
+	config        HandlerConfig
 	logger        *zap.Logger
 	mu            sync.Mutex
 	messageQueue  [][]byte
 	lastActivity  time.Time
+	deadLetters   *deadLetterLog
 }
 
 func WebSocketHandlerV2(llmManager manager.LLMManager, logger *zap.Logger) http.HandlerFunc {
-	fileProcessor := utils.NewFileProcessor(logger)
+	handlerConfig := LoadHandlerConfig()
+	fileProcessor := utils.NewFileProcessor(logger).WithConfig(handlerConfig.FileProcessing)
 	clientRegistry := &sync.Map{}
+	deadLetters := newDeadLetterLog(handlerConfig.DeadLetterLog, logger)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		clientID := fmt.Sprintf("client_%d", time.Now().UnixNano())
@@ -45,6 +63,8 @@ func WebSocketHandlerV2(llmManager manager.LLMManager, logger *zap.Logger) http.
 		}
 
 		config := utils.DefaultConnectionConfig()
+		config.SendTimeout = handlerConfig.SendTimeout
+		config.CircuitBreaker = utils.LoadCircuitBreakerConfig("")
 		managedConn := utils.NewManagedConnection(logger, config)
 		managedConn.SetConnection(conn)
 
@@ -53,9 +73,11 @@ func WebSocketHandlerV2(llmManager manager.LLMManager, logger *zap.Logger) http.
 			managedConn:   managedConn,
 			llmManager:    llmManager,
 			fileProcessor: fileProcessor,
+			config:        handlerConfig,
 			logger:        logger,
 			messageQueue:  make([][]byte, 0),
 			lastActivity:  time.Now(),
+			deadLetters:   deadLetters,
 		}
 
 		// Registra cliente
@@ -86,6 +108,9 @@ func (c *ClientV2) healthCheck() {
 			if err := c.managedConn.Send([]byte(`{"type":"ping"}`)); err != nil {
 				c.logger.Error("Erro ao enviar ping", zap.Error(err))
 			}
+
+		case <-c.managedConn.Done():
+			return
 		}
 	}
 }
@@ -95,11 +120,10 @@ func (c *ClientV2) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.managedConn.SendQueue:
-			if !ok {
-				return
-			}
+		case <-c.managedConn.Done():
+			return
 
+		case message := <-c.managedConn.SendQueue:
 			if err := c.writeMessage(message); err != nil {
 				c.logger.Error("Erro ao escrever mensagem", zap.Error(err))
 
@@ -115,8 +139,7 @@ func (c *ClientV2) writePump() {
 }
 
 func (c *ClientV2) writeMessage(data []byte) error {
-	c.managedConn.Conn.SetWriteDeadline(time.Now().Add(45 * time.Second))
-	return c.managedConn.Conn.WriteMessage(websocket.TextMessage, data)
+	return c.managedConn.WriteMessage(websocket.TextMessage, data)
 }
 
 func (c *ClientV2) readPump() {
@@ -147,6 +170,10 @@ func (c *ClientV2) handleMessage(payload []byte) {
 	}
 
 	// Validações...
+	if provider, applied := c.config.resolveProvider(req.Provider); applied {
+		c.logger.Info("Provedor não especificado, usando provedor padrão", zap.String("provider", provider))
+		req.Provider = provider
+	}
 	if req.Provider == "" {
 		c.sendError("Provedor não especificado")
 		return
@@ -157,27 +184,49 @@ func (c *ClientV2) handleMessage(payload []byte) {
 }
 
 func (c *ClientV2) processMessage(req RequestPayload) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	timeout := requestTimeout(req, c.config, c.logger)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	ctx = startTimings(ctx, c.config)
 
 	client, err := c.llmManager.GetClient(req.Provider, req.Model)
 	if err != nil {
-		c.sendError(err.Error())
+		c.sendJSON(ResponsePayload{Status: "error", Response: err.Error(), ErrorCategory: clientErrorCategory(err)})
 		return
 	}
+	applyProviderParams(client, req, c.logger)
 
 	response, err := client.SendPrompt(ctx, req.Prompt, req.History, 0)
+	recordPhase(ctx, utils.PhaseLLM, start)
+	recordProviderOutcome(ctx, req.Provider, err)
 	if err != nil {
-		c.sendError("Erro ao processar: " + err.Error())
+		if requestTimedOut(ctx) {
+			c.sendJSON(ResponsePayload{Status: "error", Response: timeoutMessage(timeout), ErrorCategory: utils.ErrorCategoryTimeout})
+			return
+		}
+		c.sendJSON(ResponsePayload{
+			Status:        "error",
+			Response:      "Erro ao processar: " + err.Error(),
+			ErrorCategory: utils.ErrorCategoryOf(err),
+		})
 		return
 	}
 
-	c.sendJSON(ResponsePayload{
+	isMarkdown := detectMarkdown(response)
+	if req.PlainText {
+		response = utils.StripMarkdown(response)
+		isMarkdown = false
+	}
+
+	payload := ResponsePayload{
 		Status:     "completed",
 		Response:   response,
-		IsMarkdown: detectMarkdown(response),
+		IsMarkdown: isMarkdown,
 		Provider:   req.Provider,
-	})
+	}
+	attachTimings(ctx, &payload, start)
+	c.sendJSON(payload)
 }
 
 func (c *ClientV2) sendJSON(v interface{}) {
@@ -189,12 +238,14 @@ func (c *ClientV2) sendJSON(v interface{}) {
 
 	if err := c.managedConn.Send(data); err != nil {
 		c.logger.Error("Erro ao enviar JSON", zap.Error(err))
+		c.deadLetters.record(c.id, deadLetterSendFailed, data, err)
 	}
 }
 
 func (c *ClientV2) sendError(message string) {
 	c.sendJSON(ResponsePayload{
-		Status:   "error",
-		Response: message,
+		Status:        "error",
+		Response:      message,
+		ErrorCategory: utils.ErrorCategoryClient,
 	})
 }
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// maxStopPatternLength limita o tamanho da expressão de parada aceita na requisição
+const maxStopPatternLength = 500
+
+// stopPatternWindow é quanto do texto já recebido é reexaminado junto com cada novo trecho.
+// Limitar a busca ao fim do texto mantém o custo por trecho constante em respostas longas;
+// correspondências mais longas que a janela não são detectadas.
+const stopPatternWindow = 8 * maxStopPatternLength
+
+// errStoppedByPattern interrompe o streaming quando o texto gerado casa com StopPattern.
+var errStoppedByPattern = errors.New("streaming interrompido pelo padrão de parada")
+
+// compileStopPattern valida a expressão de parada da requisição.
+func compileStopPattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > maxStopPatternLength {
+		return nil, fmt.Errorf("stopPattern muito longo (limite de %d caracteres)", maxStopPatternLength)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("stopPattern inválido: %w", err)
+	}
+	return re, nil
+}
+
+// stopPatternFilter repassa os trechos ao destino até que o texto acumulado case com a
+// expressão. Cada trecho é examinado com os últimos stopPatternWindow bytes anteriores a ele.
+// O trecho em que o padrão se completa é cortado no fim da correspondência e o restante da
+// geração é descartado.
+type stopPatternFilter struct {
+	re   *regexp.Regexp
+	next func(chunk string) error
+	text strings.Builder
+	// stopped indica que o padrão foi encontrado
+	stopped bool
+}
+
+func (f *stopPatternFilter) onChunk(chunk string) error {
+	if f.stopped {
+		return errStoppedByPattern
+	}
+	before := f.text.Len()
+	f.text.WriteString(chunk)
+
+	text := f.text.String()
+	start := max(before-stopPatternWindow, 0)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	loc := f.re.FindStringIndex(text[start:])
+	if loc == nil {
+		return f.next(chunk)
+	}
+
+	full := text[:start+loc[1]]
+	f.text.Reset()
+	f.text.WriteString(full)
+	if len(full) > before {
+		if err := f.next(full[before:]); err != nil {
+			return err
+		}
+	}
+	f.stopped = true
+	return errStoppedByPattern
+}
+
+// generateWithStopPattern aplica StopPattern a uma geração em streaming. Quando o padrão é
+// encontrado, a chamada ao provedor é cancelada e o texto até o fim da correspondência é
+// retornado como resultado, com StoppedByPattern marcado.
+func generateWithStopPattern(ctx context.Context, pattern string, onChunk func(chunk string) error, logger *zap.Logger, gen func(ctx context.Context, onChunk func(chunk string) error) (llmResult, error)) (llmResult, error) {
+	if pattern == "" {
+		return gen(ctx, onChunk)
+	}
+	re, err := compileStopPattern(pattern)
+	if err != nil {
+		return llmResult{}, err
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	filter := &stopPatternFilter{re: re, next: onChunk}
+	result, err := gen(streamCtx, filter.onChunk)
+	if !filter.stopped {
+		return result, err
+	}
+	cancel()
+
+	logger.Info("Streaming interrompido pelo padrão de parada",
+		zap.String("pattern", pattern),
+		zap.Int("response_length", filter.text.Len()),
+	)
+	result.Response = filter.text.String()
+	result.StoppedByPattern = true
+	return result, nil
+}
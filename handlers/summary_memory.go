@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/webchatcomllm/llm/manager"
+	"github.com/webchatcomllm/models"
+	"go.uber.org/zap"
+)
+
+const summaryMaxTokens = 1024
+
+// summaryMemory mantém o resumo acumulado das mensagens mais antigas de uma conversa,
+// substituindo-as por uma única mensagem de contexto quando o histórico cresce demais.
+type summaryMemory struct {
+	mu          sync.Mutex
+	summary     string
+	covered     int    // quantidade de mensagens do início do histórico cobertas pelo resumo
+	coveredHash string // impressão digital dessas mensagens, para detectar troca de conversa
+}
+
+// apply retorna o histórico compactado. Em caso de falha no resumo, o histórico original é mantido.
+func (m *summaryMemory) apply(ctx context.Context, history []models.Message, provider, model string, cfg HandlerConfig, llmManager manager.LLMManager, logger *zap.Logger) []models.Message {
+	if !cfg.SummaryMemoryEnabled || len(history) <= cfg.SummaryMemoryThreshold {
+		return history
+	}
+
+	// Mensagens de sistema/desenvolvedor iniciais são instruções do integrador e nunca são resumidas
+	leading := 0
+	for leading < len(history) && history[leading].IsInstruction() {
+		leading++
+	}
+	conversation := history[leading:]
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summary, covered := "", 0
+	if m.covered > 0 && m.covered <= len(conversation) && m.coveredHash == hashMessages(conversation[:m.covered]) {
+		summary, covered = m.summary, m.covered
+	}
+
+	// Só gera um novo resumo quando a parte ainda não resumida volta a passar do limite
+	if len(conversation)-covered > cfg.SummaryMemoryThreshold {
+		toCover := len(conversation) - cfg.SummaryMemoryKeepRecent
+		if toCover > covered {
+			newSummary, err := summarize(ctx, summary, conversation[covered:toCover], provider, model, cfg, llmManager)
+			if err != nil {
+				logger.Warn("Falha ao resumir histórico, mantendo histórico completo", zap.Error(err))
+				return history
+			}
+			logger.Info("Histórico antigo resumido",
+				zap.Int("mensagens_resumidas", toCover-covered),
+				zap.Int("mensagens_cobertas", toCover),
+				zap.Int("tamanho_resumo", len(newSummary)),
+			)
+			summary, covered = newSummary, toCover
+			m.summary, m.covered, m.coveredHash = summary, covered, hashMessages(conversation[:covered])
+		}
+	}
+
+	if covered == 0 {
+		return history
+	}
+
+	result := make([]models.Message, 0, leading+1+len(conversation)-covered)
+	result = append(result, history[:leading]...)
+	result = append(result, models.Message{
+		Role:    "system",
+		Content: "Resumo da conversa até aqui (mensagens anteriores foram condensadas):\n" + summary,
+	})
+	return append(result, conversation[covered:]...)
+}
+
+// summarize gera um novo resumo incorporando o resumo anterior e as mensagens informadas.
+func summarize(ctx context.Context, previous string, messages []models.Message, provider, model string, cfg HandlerConfig, llmManager manager.LLMManager) (string, error) {
+	// SUMMARY_MODEL vale mesmo sem SUMMARY_PROVIDER; o modelo da requisição só é reaproveitado
+	// quando o provedor também é o da requisição
+	summaryProvider := firstNonEmpty(cfg.SummaryProvider, provider)
+	summaryModel := cfg.SummaryModel
+	if summaryModel == "" && cfg.SummaryProvider == "" {
+		summaryModel = model
+	}
+
+	client, err := llmManager.GetClient(summaryProvider, summaryModel)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("Resuma de forma concisa a conversa abaixo entre um usuário e um assistente. ")
+	b.WriteString("Preserve fatos, decisões, nomes, números e pendências relevantes para continuar a conversa. ")
+	b.WriteString("Responda apenas com o resumo.\n\n")
+	if previous != "" {
+		b.WriteString("Resumo anterior:\n")
+		b.WriteString(previous)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Mensagens:\n")
+	for _, msg := range messages {
+		role := "Usuário"
+		if msg.Role == "assistant" {
+			role = "Assistente"
+		}
+		b.WriteString(fmt.Sprintf("%s: %s\n", role, msg.Content))
+	}
+
+	summary, err := client.SendPrompt(ctx, b.String(), nil, summaryMaxTokens)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(summary) == "" {
+		return "", fmt.Errorf("resumo vazio retornado pelo provedor")
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+// hashMessages calcula uma impressão digital estável de uma sequência de mensagens.
+func hashMessages(messages []models.Message) string {
+	h := sha256.New()
+	for _, msg := range messages {
+		h.Write([]byte(msg.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(msg.Content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/models"
+)
+
+// languageInstruction gera a instrução de idioma anexada ao prompt de sistema.
+func languageInstruction(language string) string {
+	return fmt.Sprintf("Responda sempre em %s, independentemente do idioma da pergunta, do histórico ou dos arquivos anexados.", language)
+}
+
+// applySystemInstructions monta o prompt de sistema (ver applyBaseSystemPrompt) e acrescenta
+// as instruções da requisição e da configuração (idioma forçado e template de resposta).
+func applySystemInstructions(history []models.Message, req RequestPayload, cfg HandlerConfig) []models.Message {
+	history = applyBaseSystemPrompt(history, req, cfg)
+	if language := firstNonEmpty(req.ResponseLanguage, cfg.ForceResponseLanguage); language != "" {
+		history = withSystemInstruction(history, languageInstruction(language))
+	}
+	if req.ResponseTemplate != nil {
+		history = withSystemInstruction(history, req.ResponseTemplate.instruction())
+	}
+	return history
+}
+
+// applyBaseSystemPrompt monta a base do prompt de sistema, em camadas e nesta ordem:
+// SYSTEM_PROMPT_PREFIX e, em seguida, o prompt de sistema da requisição (primeira mensagem do
+// histórico enviado, com papel system) ou, se a requisição não trouxer um, o prompt padrão do
+// provedor (<PROVEDOR>_SYSTEM_PROMPT).
+func applyBaseSystemPrompt(history []models.Message, req RequestPayload, cfg HandlerConfig) []models.Message {
+	rest := history
+	var own string
+	if len(req.History) > 0 && req.History[0].Role == "system" && len(history) > 0 && history[0].Role == "system" {
+		own, rest = history[0].Content, history[1:]
+	} else {
+		own = cfg.ProviderSystemPrompts[catalog.ResolveProvider(req.Provider)]
+	}
+
+	var parts []string
+	for _, part := range []string{cfg.SystemPromptPrefix, own} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return history
+	}
+
+	result := make([]models.Message, 0, len(rest)+1)
+	result = append(result, models.Message{Role: "system", Content: strings.Join(parts, "\n\n")})
+	return append(result, rest...)
+}
+
+// withSystemInstruction compõe a instrução com o prompt de sistema já presente no histórico.
+// Se a primeira mensagem for de sistema, a instrução é acrescentada a ela; caso contrário,
+// uma nova mensagem de sistema é inserida no início. O histórico original não é alterado.
+func withSystemInstruction(history []models.Message, instruction string) []models.Message {
+	instruction = strings.TrimSpace(instruction)
+	if instruction == "" {
+		return history
+	}
+
+	result := make([]models.Message, 0, len(history)+1)
+	if len(history) > 0 && history[0].Role == "system" {
+		result = append(result, models.Message{
+			Role:    "system",
+			Content: history[0].Content + "\n\n" + instruction,
+		})
+		return append(result, history[1:]...)
+	}
+
+	result = append(result, models.Message{Role: "system", Content: instruction})
+	return append(result, history...)
+}
+
+// firstNonEmpty retorna o primeiro valor não vazio.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/webchatcomllm/models"
+)
+
+func TestApplyBaseSystemPromptLayering(t *testing.T) {
+	user := models.Message{Role: "user", Content: "oi"}
+	own := models.Message{Role: "system", Content: "Sistema da requisição"}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		defaults map[string]string
+		history  []models.Message
+		want     string // conteúdo da mensagem de sistema; vazio quando não há
+	}{
+		{"nada configurado", "", nil, []models.Message{user}, ""},
+		{"só prefixo", "Prefixo", nil, []models.Message{user}, "Prefixo"},
+		{"só padrão do provedor", "", map[string]string{"OPENAI": "Padrão OpenAI"}, []models.Message{user}, "Padrão OpenAI"},
+		{"só sistema da requisição", "", nil, []models.Message{own, user}, "Sistema da requisição"},
+		{"prefixo e padrão", "Prefixo", map[string]string{"OPENAI": "Padrão OpenAI"}, []models.Message{user}, "Prefixo\n\nPadrão OpenAI"},
+		{"prefixo e sistema da requisição", "Prefixo", nil, []models.Message{own, user}, "Prefixo\n\nSistema da requisição"},
+		{"requisição substitui o padrão", "", map[string]string{"OPENAI": "Padrão OpenAI"}, []models.Message{own, user}, "Sistema da requisição"},
+		{"as três camadas", "Prefixo", map[string]string{"OPENAI": "Padrão OpenAI"}, []models.Message{own, user}, "Prefixo\n\nSistema da requisição"},
+		{"padrão de outro provedor", "", map[string]string{"CLAUDE": "Padrão Claude"}, []models.Message{user}, ""},
+		{"camadas em branco", "  ", map[string]string{"OPENAI": "\n"}, []models.Message{user}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := HandlerConfig{SystemPromptPrefix: tt.prefix, ProviderSystemPrompts: tt.defaults}
+			req := RequestPayload{Provider: "OPENAI", History: tt.history}
+			got := applyBaseSystemPrompt(tt.history, req, cfg)
+
+			if tt.want == "" {
+				if len(got) != len(tt.history) || (len(got) > 0 && got[0].Role == "system" && tt.history[0].Role != "system") {
+					t.Fatalf("histórico alterado: %+v", got)
+				}
+				return
+			}
+			if len(got) == 0 || got[0].Role != "system" || got[0].Content != tt.want {
+				t.Fatalf("sistema = %+v, want %q", got, tt.want)
+			}
+			if got[len(got)-1] != user {
+				t.Fatalf("última mensagem = %+v, want a do usuário", got[len(got)-1])
+			}
+			systems := 0
+			for _, msg := range got {
+				if msg.Role == "system" {
+					systems++
+				}
+			}
+			if systems != 1 {
+				t.Fatalf("%d mensagens de sistema, want 1: %+v", systems, got)
+			}
+		})
+	}
+}
+
+func TestApplyBaseSystemPromptResolvesProviderAlias(t *testing.T) {
+	cfg := HandlerConfig{ProviderSystemPrompts: map[string]string{"STACKSPOT": "Padrão StackSpot"}}
+	got := applyBaseSystemPrompt(nil, RequestPayload{Provider: "GPT-5"}, cfg)
+	if len(got) != 1 || got[0].Content != "Padrão StackSpot" {
+		t.Fatalf("histórico = %+v, want o padrão do StackSpot", got)
+	}
+}
+
+// Na troca para um provedor alternativo, o prompt de sistema é refeito com o padrão do novo
+// provedor, sem acumular o do anterior.
+func TestApplySystemInstructionsAfterFallback(t *testing.T) {
+	cfg := HandlerConfig{
+		SystemPromptPrefix:    "Prefixo",
+		ProviderSystemPrompts: map[string]string{"OPENAI": "Padrão OpenAI", "CLAUDE": "Padrão Claude"},
+	}
+	base := []models.Message{{Role: "user", Content: "oi"}}
+	req := RequestPayload{Provider: "OPENAI", History: base}
+
+	first := applySystemInstructions(base, req, cfg)
+	req.Provider = "CLAUDE"
+	second := applySystemInstructions(base, req, cfg)
+
+	if first[0].Content != "Prefixo\n\nPadrão OpenAI" {
+		t.Fatalf("primeira tentativa = %q", first[0].Content)
+	}
+	if second[0].Content != "Prefixo\n\nPadrão Claude" {
+		t.Fatalf("após a troca = %q", second[0].Content)
+	}
+	if len(base) != 1 || base[0].Role != "user" {
+		t.Fatalf("histórico base alterado: %+v", base)
+	}
+}
+
+func TestApplySystemInstructionsLanguageAfterLayers(t *testing.T) {
+	cfg := HandlerConfig{
+		SystemPromptPrefix:    "Prefixo",
+		ProviderSystemPrompts: map[string]string{"OPENAI": "Padrão OpenAI"},
+		ForceResponseLanguage: "português",
+	}
+	got := applySystemInstructions(nil, RequestPayload{Provider: "OPENAI"}, cfg)
+	want := "Prefixo\n\nPadrão OpenAI\n\n" + languageInstruction("português")
+	if len(got) != 1 || got[0].Content != want {
+		t.Fatalf("sistema = %+v, want %q", got, want)
+	}
+
+	got = applySystemInstructions(nil, RequestPayload{Provider: "OPENAI", ResponseLanguage: "inglês"}, cfg)
+	if got[0].Content != "Prefixo\n\nPadrão OpenAI\n\n"+languageInstruction("inglês") {
+		t.Fatalf("idioma da requisição não aplicado: %q", got[0].Content)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/webchatcomllm/llm/catalog"
+	"go.uber.org/zap"
+)
+
+// imageExtensions identifica imagens quando o cliente não informa o tipo do arquivo
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".bmp": true,
+}
+
+// hasImages indica se algum arquivo da requisição é uma imagem.
+func hasImages(files []FilePayload) bool {
+	for _, file := range files {
+		if strings.HasPrefix(file.ContentType, "image/") || file.FileType == "image" ||
+			imageExtensions[strings.ToLower(filepath.Ext(file.Name))] {
+			return true
+		}
+	}
+	return false
+}
+
+// applyVisionRouting troca o provedor da requisição pelo provedor de visão configurado quando
+// há imagens e o modelo escolhido não as interpreta. Retorna o aviso exibido ao usuário, ou
+// vazio se a requisição não foi alterada.
+func applyVisionRouting(req *RequestPayload, cfg HandlerConfig, logger *zap.Logger) string {
+	if cfg.VisionProvider == "" || !hasImages(req.Files) || catalog.SupportsVision(req.Provider, req.Model) {
+		return ""
+	}
+	if !catalog.SupportsVision(cfg.VisionProvider, cfg.VisionModel) {
+		logger.Warn("VISION_PROVIDER configurado não suporta imagens, roteamento ignorado",
+			zap.String("vision_provider", cfg.VisionProvider),
+			zap.String("vision_model", cfg.VisionModel),
+		)
+		return ""
+	}
+
+	logger.Info("Imagens enviadas a modelo sem visão, usando provedor de visão",
+		zap.String("provider", req.Provider),
+		zap.String("model", req.Model),
+		zap.String("vision_provider", cfg.VisionProvider),
+		zap.String("vision_model", cfg.VisionModel),
+	)
+
+	notice := fmt.Sprintf("O modelo selecionado não interpreta imagens; esta mensagem foi respondida por %s.", cfg.VisionProvider)
+	req.Provider = cfg.VisionProvider
+	req.Model = cfg.VisionModel
+	req.providerNotice = notice
+	return notice
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -11,8 +12,11 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	llmclient "github.com/webchatcomllm/llm/client"
 	"github.com/webchatcomllm/llm/manager"
+	"github.com/webchatcomllm/metrics"
 	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/store"
 	"github.com/webchatcomllm/utils"
 	"go.uber.org/zap"
 )
@@ -23,10 +27,12 @@ const (
 	MaxFilesPerRequest = 50
 
 	// WebSocket timeouts otimizados
-	writeWait      = 45 * time.Second
-	pongWait       = 120 * time.Second
-	pingPeriod     = 30 * time.Second
-	maxMessageSize = 1024 * 1024 // 1MB
+	writeWait  = 45 * time.Second
+	pongWait   = 120 * time.Second
+	pingPeriod = 30 * time.Second
+	// Padrões de WS_MAX_MESSAGE_SIZE e WS_HARD_MESSAGE_SIZE
+	defaultMaxMessageSize       = 1024 * 1024 // 1MB
+	defaultHardMessageSizeLimit = 4 * defaultMaxMessageSize
 )
 
 // Upgrader com configurações robustas
@@ -49,15 +55,61 @@ type FilePayload struct {
 	Size        int64                  `json:"size"`
 	IsBase64    bool                   `json:"isBase64"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	// NoTruncate envia o arquivo inteiro, ignorando o limite de caracteres por arquivo
+	NoTruncate bool `json:"noTruncate,omitempty"`
+}
+
+// password retorna a senha informada em metadata.password para abrir arquivos protegidos
+func (f FilePayload) password() string {
+	password, _ := f.Metadata["password"].(string)
+	return password
 }
 
 type RequestPayload struct {
-	Type     string           `json:"type,omitempty"` // ping, pong, message
-	Provider string           `json:"provider"`
-	Model    string           `json:"model"`
-	Prompt   string           `json:"prompt"`
-	History  []models.Message `json:"history"`
-	Files    []FilePayload    `json:"files,omitempty"`
+	Type        string           `json:"type,omitempty"` // ping, pong, message, switch_provider
+	Provider    string           `json:"provider"`
+	Model       string           `json:"model"`
+	Prompt      string           `json:"prompt"`
+	History     []models.Message `json:"history"`
+	Files       []FilePayload    `json:"files,omitempty"`
+	DebugPrompt bool             `json:"debugPrompt,omitempty"`
+	// DebugRawResponse inclui o corpo bruto da resposta do provedor nos metadados
+	DebugRawResponse bool `json:"debugRawResponse,omitempty"`
+	// NativeDocuments pede o envio de PDFs diretamente ao provedor, quando suportado
+	NativeDocuments bool `json:"nativeDocuments,omitempty"`
+	// FileTools pede que arquivos CSV/JSON sejam consultados pela ferramenta query_file
+	FileTools bool `json:"fileTools,omitempty"`
+	// ResponseLanguage sobrescreve o idioma forçado pelo servidor para esta requisição
+	ResponseLanguage string `json:"responseLanguage,omitempty"`
+	// PlainText converte a resposta em texto puro, para clientes que não renderizam Markdown
+	PlainText bool `json:"plainText,omitempty"`
+	// ResponseTemplate exige uma resposta estruturada (JSON, XML ou chave-valor)
+	ResponseTemplate *ResponseTemplate `json:"responseTemplate,omitempty"`
+	// ProviderParams são repassados ao corpo da requisição do provedor (temperature, top_p...),
+	// restritos à lista de parâmetros permitidos de cada provedor no catálogo
+	ProviderParams map[string]interface{} `json:"providerParams,omitempty"`
+	// StopPattern (expressão regular) encerra o streaming assim que o texto gerado a contém
+	StopPattern string `json:"stopPattern,omitempty"`
+	// SessionID identifica a conversa no armazenamento de conversas; vazio não grava o histórico
+	SessionID string `json:"sessionId,omitempty"`
+	// ParentMessageID é a mensagem gravada à qual esta pergunta responde, para ramificar a
+	// conversa (regenerar ou editar); vazio continua a partir da última mensagem da sessão
+	ParentMessageID string `json:"parentMessageId,omitempty"`
+	// TurnIndex é o último turno (a partir de 0) copiado por uma mensagem "fork"; vazio copia todos
+	TurnIndex *int `json:"turnIndex,omitempty"`
+	// Stream entrega a resposta em trechos (status "streaming") antes da resposta completa
+	Stream bool `json:"stream,omitempty"`
+	// MetadataFormat sobrescreve FILE_METADATA_FORMAT: "markdown", "json" ou "both"
+	MetadataFormat string `json:"metadataFormat,omitempty"`
+	// TimeoutSeconds sobrescreve LLM_REQUEST_TIMEOUT, limitado a LLM_MAX_REQUEST_TIMEOUT
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// FallbackProviders são tentados em ordem quando o provedor falha ("PROVEDOR" ou "PROVEDOR:modelo")
+	FallbackProviders []string `json:"fallbackProviders,omitempty"`
+
+	// providerNotice explica ao usuário uma troca automática de provedor
+	providerNotice string
+	// promptMessageID é o ID com que a pergunta é gravada na sessão
+	promptMessageID string
 }
 
 type ResponsePayload struct {
@@ -66,6 +118,30 @@ type ResponsePayload struct {
 	Response   string `json:"response"`
 	IsMarkdown bool   `json:"isMarkdown"`
 	Provider   string `json:"provider"`
+	// Model é o ID concreto do modelo usado, já com aliases resolvidos
+	Model    string                 `json:"model,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// ErrorCategory classifica erros (network, timeout, rate_limit, auth, server, client)
+	ErrorCategory utils.ErrorCategory `json:"errorCategory,omitempty"`
+	// Citations lista as fontes informadas pelo provedor, quando houver
+	Citations []models.Citation `json:"citations,omitempty"`
+	// TemplateMatched indica se a resposta segue o ResponseTemplate solicitado
+	TemplateMatched *bool `json:"templateMatched,omitempty"`
+	// Consumo de tokens informado pelo provedor, quando disponível
+	PromptTokens     int `json:"promptTokens,omitempty"`
+	CompletionTokens int `json:"completionTokens,omitempty"`
+	TotalTokens      int `json:"totalTokens,omitempty"`
+	// TokensEstimated indica contagem estimada localmente (provedores que não informam consumo)
+	TokensEstimated bool `json:"tokensEstimated,omitempty"`
+	// TruncatedByTimeout indica uma resposta parcial, interrompida por MAX_STREAM_DURATION
+	TruncatedByTimeout bool `json:"truncatedByTimeout,omitempty"`
+	// StoppedByPattern indica que o streaming foi encerrado ao encontrar o StopPattern
+	StoppedByPattern bool `json:"stoppedByPattern,omitempty"`
+	// MessageID identifica a resposta; com sessão, ConversationID é o sessionId e
+	// PromptMessageID, o ID com que a pergunta foi gravada
+	MessageID       string `json:"messageId,omitempty"`
+	ConversationID  string `json:"conversationId,omitempty"`
+	PromptMessageID string `json:"promptMessageId,omitempty"`
 }
 
 type ProgressPayload struct {
@@ -75,31 +151,76 @@ type ProgressPayload struct {
 	Current    int    `json:"current,omitempty"`
 	Total      int    `json:"total,omitempty"`
 	Percentage int    `json:"percentage,omitempty"`
+	// EtaSeconds estima o tempo restante do processamento; omitido enquanto não há estimativa
+	EtaSeconds int `json:"etaSeconds,omitempty"`
 }
 
 // Client representa uma conexão WebSocket com proteção contra race conditions
 type Client struct {
+	id            string
 	conn          *websocket.Conn
 	send          chan []byte
 	llmManager    manager.LLMManager
 	fileProcessor *utils.FileProcessor
+	config        HandlerConfig
 	logger        *zap.Logger
 	mu            sync.Mutex
 	closed        bool
+	// done é fechado em close() para encerrar as goroutines da conexão imediatamente
+	done          chan struct{}
 	lastActivity  time.Time
 	messageQueue  [][]byte
 	queueMu       sync.Mutex
+	memory        summaryMemory
+	recorder      *requestRecorder
+	processors    RequestProcessorChain
+	deadLetters   *deadLetterLog
+	conversations store.ConversationStore
+	// active é o provedor escolhido por "switch_provider"; lastProvider, o da última mensagem
+	active       activeProvider
+	lastProvider string
+	rateLimiter  *messageRateLimiter
+	generations  *generationRegistry
+	// contentRoutes escolhe provedor/modelo pelo tipo de conteúdo (CONTENT_ROUTES)
+	contentRoutes map[string]contentRoute
+	// extractions limita as extrações de arquivo simultâneas da conexão
+	extractions extractionSlots
+	// connectedAt e sessionID (a última sessão usada, protegida por mu) aparecem em
+	// /api/admin/connections
+	connectedAt time.Time
+	sessionID   string
 }
 
 // WebSocketHandler cria o handler HTTP para WebSocket
-func WebSocketHandler(llmManager manager.LLMManager, logger *zap.Logger) http.HandlerFunc {
-	fileProcessor := utils.NewFileProcessor(logger)
+func WebSocketHandler(llmManager manager.LLMManager, conversations store.ConversationStore, logger *zap.Logger) http.HandlerFunc {
+	handlerConfig := LoadHandlerConfig()
+	fileProcessor := utils.NewFileProcessor(logger).WithConfig(handlerConfig.FileProcessing)
+	limiter := newConnectionLimiter(handlerConfig.MaxConnections)
+	recorder := newRequestRecorder(handlerConfig.RecordRequestsDir, handlerConfig.RecordGzipThreshold, logger)
+	processors := buildRequestProcessorChain(handlerConfig.RequestProcessors, logger)
+	deadLetters := newDeadLetterLog(handlerConfig.DeadLetterLog, logger)
+	generations := newGenerationRegistry(handlerConfig.SessionResumeGrace, logger)
+	contentRoutes := buildContentRoutes(handlerConfig.ContentRoutes, logger)
+	metrics.Default.GaugeFunc("ws_active_connections", "Conexões WebSocket ativas", func() float64 {
+		return float64(limiter.Active())
+	})
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Detecta browser
 		userAgent := r.UserAgent()
 		isFirefox := strings.Contains(strings.ToLower(userAgent), "firefox")
 
+		// Limite global de conexões, verificado antes do upgrade
+		admitted := limiter.tryAcquire()
+		if !admitted && handlerConfig.ConnectionOverflowMode != OverflowQueue {
+			logger.Warn("Limite de conexões atingido, recusando conexão",
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.Int("max_connections", handlerConfig.MaxConnections),
+			)
+			limiter.rejectOverflow(w, handlerConfig.ConnectionRetryAfter)
+			return
+		}
+
 		logger.Info("Nova tentativa de conexão WebSocket",
 			zap.String("remote_addr", r.RemoteAddr),
 			zap.String("user_agent", userAgent),
@@ -123,27 +244,54 @@ func WebSocketHandler(llmManager manager.LLMManager, logger *zap.Logger) http.Ha
 				zap.String("user_agent", userAgent),
 				zap.Bool("is_firefox", isFirefox),
 			)
+			if admitted {
+				limiter.release()
+			}
 			return
 		}
 
+		if !admitted {
+			if !limiter.waitForSlot(conn, handlerConfig.ConnectionQueueTimeout, logger) {
+				conn.Close()
+				return
+			}
+		}
+		defer limiter.release()
+
 		// Cria cliente
 		client := &Client{
+			id:            fmt.Sprintf("client_%d", time.Now().UnixNano()),
 			conn:          conn,
 			send:          make(chan []byte, 256),
+			done:          make(chan struct{}),
 			llmManager:    llmManager,
 			fileProcessor: fileProcessor,
+			config:        handlerConfig,
+			recorder:      recorder,
+			processors:    processors,
+			deadLetters:   deadLetters,
+			conversations: conversations,
 			logger:        logger,
 			closed:        false,
 			lastActivity:  time.Now(),
 			messageQueue:  make([][]byte, 0),
+			rateLimiter:   newMessageRateLimiter(handlerConfig.MessagesPerSecond),
+			generations:   generations,
+			contentRoutes: contentRoutes,
+			extractions:   newExtractionSlots(handlerConfig.MaxConcurrentExtractions),
+			connectedAt:   time.Now(),
 		}
 
 		logger.Info("Cliente WebSocket conectado com sucesso",
 			zap.String("remote_addr", conn.RemoteAddr().String()),
 			zap.String("user_agent", userAgent),
 			zap.Bool("is_firefox", isFirefox),
+			zap.Int64("conexoes_ativas", limiter.Active()),
 		)
 
+		liveClients.add(client)
+		defer liveClients.remove(client)
+
 		// Inicia goroutines
 		go client.writePump()
 		go client.healthCheck()
@@ -155,12 +303,14 @@ func WebSocketHandler(llmManager manager.LLMManager, logger *zap.Logger) http.Ha
 func (c *Client) readPump() {
 	defer func() {
 		c.close()
+		c.generations.detach(c)
 		c.logger.Info("Cliente desconectado (readPump)",
 			zap.String("remote_addr", c.conn.RemoteAddr().String()))
 	}()
 
 	// Configurações otimizadas
-	c.conn.SetReadLimit(maxMessageSize)
+	maxSize, hardLimit := c.config.messageSizeLimits()
+	c.conn.SetReadLimit(int64(hardLimit))
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		c.lastActivity = time.Now()
@@ -172,7 +322,13 @@ func (c *Client) readPump() {
 	for {
 		messageType, message, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err,
+			if errors.Is(err, websocket.ErrReadLimit) {
+				// A biblioteca já enviou o fechamento com o código 1009, tratado pelo frontend
+				c.logger.Warn("Mensagem excede o limite rígido, conexão encerrada",
+					zap.Int("limite_bytes", hardLimit))
+			} else if websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+				c.logger.Warn("Cliente encerrou a conexão por mensagem grande demais (1009)", zap.Error(err))
+			} else if websocket.IsUnexpectedCloseError(err,
 				websocket.CloseGoingAway,
 				websocket.CloseAbnormalClosure,
 				websocket.CloseNormalClosure,
@@ -186,6 +342,14 @@ func (c *Client) readPump() {
 
 		c.lastActivity = time.Now()
 
+		if len(message) > maxSize {
+			c.logger.Warn("Mensagem muito grande recusada",
+				zap.Int("tamanho_bytes", len(message)),
+				zap.Int("limite_bytes", maxSize))
+			c.sendError(messageTooBigError(len(message), maxSize))
+			continue
+		}
+
 		if messageType == websocket.TextMessage {
 			c.handleMessage(message)
 		}
@@ -202,11 +366,12 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
+		case message := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			// nil encerra a conexão após as mensagens anteriores (ver closeWithReconnect)
+			if message == nil {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "reconnect"))
 				return
 			}
 
@@ -215,9 +380,7 @@ func (c *Client) writePump() {
 					zap.Error(err))
 
 				// Adiciona mensagem à fila para reenvio
-				c.queueMu.Lock()
-				c.messageQueue = append(c.messageQueue, message)
-				c.queueMu.Unlock()
+				c.enqueue(message)
 
 				return
 			}
@@ -225,6 +388,9 @@ func (c *Client) writePump() {
 			c.logger.Debug("Mensagem enviada com sucesso",
 				zap.Int("size", len(message)))
 
+		case <-c.done:
+			return
+
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -244,16 +410,15 @@ func (c *Client) healthCheck() {
 
 	for {
 		select {
-		case <-ticker.C:
-			if c.isClosed() {
-				return
-			}
+		case <-c.done:
+			return
 
+		case <-ticker.C:
 			// Verifica inatividade
 			if time.Since(c.lastActivity) > 5*time.Minute {
 				c.logger.Warn("Cliente inativo, fechando conexão",
 					zap.Duration("inactive_for", time.Since(c.lastActivity)))
-				c.close()
+				c.closeWithReconnect(reconnectIdleTimeout, "Conexão encerrada por inatividade.")
 				return
 			}
 
@@ -301,11 +466,35 @@ func (c *Client) close() {
 	}
 
 	c.closed = true
-	close(c.send)
+	// c.send não é fechado: um sendJSON concorrente escreveria em canal fechado. O writePump,
+	// único escritor da conexão, termina ao observar c.done.
+	close(c.done)
 	c.conn.Close()
 
+	c.queueMu.Lock()
+	pending := c.messageQueue
+	c.messageQueue = nil
+	c.queueMu.Unlock()
+
 	c.logger.Info("Conexão fechada",
-		zap.Int("queued_messages", len(c.messageQueue)))
+		zap.Int("queued_messages", len(pending)))
+	for _, message := range pending {
+		c.deadLetters.record(c.id, deadLetterConnectionClosed, message, nil)
+	}
+}
+
+// enqueue adiciona a mensagem à fila de reenvio, descartando a mais antiga quando a fila
+// atinge MaxQueuedMessages.
+func (c *Client) enqueue(message []byte) {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	if max := c.config.MaxQueuedMessages; max > 0 && len(c.messageQueue) >= max {
+		dropped := c.messageQueue[0]
+		c.messageQueue = c.messageQueue[1:]
+		c.deadLetters.record(c.id, deadLetterQueueFull, dropped, nil)
+	}
+	c.messageQueue = append(c.messageQueue, message)
 }
 
 // isClosed verifica se a conexão está fechada
@@ -317,6 +506,12 @@ func (c *Client) isClosed() bool {
 
 // handleMessage processa uma mensagem recebida
 func (c *Client) handleMessage(payload []byte) {
+	// O limite vem antes do parse para que uma inundação não custe CPU com JSON
+	if allowed, violations := c.rateLimiter.allow(); !allowed {
+		c.rejectRateLimited(violations)
+		return
+	}
+
 	var req RequestPayload
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.logger.Error("Erro ao decodificar payload",
@@ -346,7 +541,36 @@ func (c *Client) handleMessage(payload []byte) {
 		return
 	}
 
+	if req.Type == "switch_provider" {
+		c.handleSwitchProvider(req)
+		return
+	}
+
+	if req.Type == "resume" {
+		c.handleResume(req)
+		return
+	}
+
+	if req.Type == "fork" {
+		c.handleFork(req)
+		return
+	}
+
 	// VALIDAÇÃO DETALHADA
+	c.applyActiveProvider(&req)
+	if provider, applied := c.config.resolveProvider(req.Provider); applied {
+		c.logger.Info("Provedor não especificado, usando provedor padrão",
+			zap.String("provider", provider),
+		)
+		req.Provider = provider
+	}
+
+	if err := c.processors.Process(context.Background(), &req); err != nil {
+		c.logger.Info("Requisição recusada por processador", zap.Error(err))
+		c.sendError(err.Error())
+		return
+	}
+
 	if req.Provider == "" {
 		c.logger.Error("Provider vazio recebido",
 			zap.String("payload_raw", string(payload)),
@@ -357,118 +581,196 @@ func (c *Client) handleMessage(payload []byte) {
 		return
 	}
 
-	if req.Prompt == "" && len(req.Files) == 0 {
-		c.sendError("Mensagem vazia. Digite algo ou anexe arquivos.")
+	resolveModelAlias(&req, c.logger)
+	extractPastedImages(&req, c.logger)
+	if err := validateChatRequest(req); err != nil {
+		c.sendError(err.Error())
+		return
+	}
+
+	if notice := applyVisionRouting(&req, c.config, c.logger); notice != "" {
+		c.sendJSON(ResponsePayload{Type: "status", Status: "info", Response: notice, Provider: req.Provider})
+	}
+	notice, err := applyMaintenanceRouting(&req, c.config, c.logger)
+	if err != nil {
+		c.sendJSON(c.errorResponse(err.Error(), utils.ErrorCategoryMaintenance))
 		return
 	}
+	if notice != "" {
+		c.sendJSON(ResponsePayload{Type: "status", Status: "info", Response: notice, Provider: req.Provider})
+	}
 
 	c.logger.Info("Mensagem válida recebida",
 		zap.String("provider", req.Provider),
 		zap.String("model", req.Model),
 	)
 
-	// Valida número de arquivos
-	if len(req.Files) > MaxFilesPerRequest {
-		c.sendError(fmt.Sprintf("Número máximo de arquivos excedido. Limite: %d", MaxFilesPerRequest))
-		return
-	}
-
 	// Processa em goroutine separada
 	go c.processMessage(req)
 }
 
-// processMessage processa a requisição do LLM
+// processMessage processa a requisição do LLM. Com sessionId, a geração fica vinculada à
+// sessão e sua resposta pode ser entregue a uma nova conexão após uma reconexão.
 func (c *Client) processMessage(req RequestPayload) {
-	// Processa arquivos se houver
-	fileContext := ""
-	if len(req.Files) > 0 {
-		var err error
-		fileContext, err = processFilesAdvanced(req.Files, c.fileProcessor, c, c.logger)
-		if err != nil {
-			c.sendError(err.Error())
-			return
-		}
+	start := time.Now()
+	loadSessionHistory(c.conversations, &req, c.logger)
+	ctx := context.Background()
+	var progress progressReporter = c
+	var gen *sessionGeneration
+	if req.SessionID != "" {
+		c.mu.Lock()
+		c.sessionID = req.SessionID
+		c.mu.Unlock()
+		ctx, gen = c.generations.start(req.SessionID, c)
+		progress = gen
 	}
-
-	// Monta prompt completo
-	fullPrompt := req.Prompt
-	if fileContext != "" {
-		fullPrompt = fileContext + "\n\n---\n\n**Pergunta do usuário:**\n" + req.Prompt
+	ctx = startTimings(ctx, c.config)
+
+	response := c.generateResponse(ctx, req, progress)
+	assignMessageIDs(c.conversations, &req, &response, c.logger)
+	attachTimings(ctx, &response, start)
+	recordRequest(req, response)
+	logSlowRequest(req, response, time.Since(start), c.config, c.logger)
+	c.recorder.record(req, response)
+	persistTurn(c.conversations, req, response, c.logger)
+	if gen != nil {
+		c.generations.finish(gen, response)
+		return
 	}
+	c.sendJSON(response)
+}
 
-	// Obtém cliente LLM
+// generateResponse executa a requisição e retorna a resposta final, de sucesso ou de erro
+func (c *Client) generateResponse(parent context.Context, req RequestPayload, progress progressReporter) ResponsePayload {
+	// Valida provedor/modelo antes do processamento de arquivos, que pode ser demorado
 	client, err := c.llmManager.GetClient(req.Provider, req.Model)
 	if err != nil {
-		c.sendError(err.Error())
-		return
+		c.logger.Warn("Provedor inválido, requisição recusada antes do processamento de arquivos",
+			zap.String("provider", req.Provider),
+			zap.String("model", req.Model),
+			zap.Int("files_count", len(req.Files)),
+		)
+		return c.errorResponse(err.Error(), clientErrorCategory(err))
 	}
+	applyProviderParams(client, req, c.logger)
+
+	filesStart := time.Now()
+	prompt, err := preparePrompt(parent, req, client, c.fileProcessor, c.extractions, c.config, progress, c.logger)
+	recordPhase(parent, utils.PhaseFileProcessing, filesStart)
+	if err != nil {
+		return c.errorResponse(err.Error(), utils.ErrorCategoryClient)
+	}
+	client, routing := c.applyContentRouting(&req, prompt, client)
 
 	// Envia para LLM
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	timeout := requestTimeout(req, c.config, c.logger)
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
+	ctx = withQueueProgress(ctx, progress)
 
-	llmResponse, err := client.SendPrompt(ctx, fullPrompt, req.History, 0)
+	baseHistory := c.memory.apply(ctx, req.History, req.Provider, req.Model, c.config, c.llmManager, c.logger)
+	baseHistory = capHistory(baseHistory, c.config, c.logger)
+	var history []models.Message
+
+	prepare := func(llmClient llmclient.LLMClient) (preparedPrompt, error) {
+		return preparePrompt(parent, req, llmClient, c.fileProcessor, c.extractions, c.config, progress, c.logger)
+	}
+	onChunk := wsChunkHandler(req, progress)
+	llmStart := time.Now()
+	result, err := generateWithFallback(ctx, &req, client, &prompt, c.llmManager, c.config, c.logger, prepare,
+		func(ctx context.Context, client llmclient.LLMClient, prompt preparedPrompt) (llmResult, error) {
+			// O prompt padrão é o do provedor da tentativa, que muda na troca para um alternativo
+			history = applySystemInstructions(baseHistory, req, c.config)
+			if onChunk == nil {
+				return generate(ctx, client, prompt, history, req.ResponseTemplate, nil, c.logger)
+			}
+			return generateWithDeadline(ctx, c.config.MaxStreamDuration, c.logger, func(ctx context.Context) (llmResult, error) {
+				return generateWithStopPattern(ctx, req.StopPattern, onChunk, c.logger, func(ctx context.Context, onChunk func(chunk string) error) (llmResult, error) {
+					return generate(ctx, client, prompt, history, req.ResponseTemplate, onChunk, c.logger)
+				})
+			})
+		})
+	recordPhase(ctx, utils.PhaseLLM, llmStart)
 	if err != nil {
-		c.sendError("Erro ao processar resposta do LLM: " + err.Error())
-		return
+		if requestTimedOut(ctx) {
+			return c.errorResponse(timeoutMessage(timeout), utils.ErrorCategoryTimeout)
+		}
+		noteProviderError(req.Provider, err, c.config, c.logger)
+		return c.errorResponse(llmErrorMessage(req.Provider, err), utils.ErrorCategoryOf(err))
 	}
 
-	// Detecta Markdown
-	isMarkdown := detectMarkdown(llmResponse)
+	response := buildChatResponse(req, prompt, history, result, c.config)
+	if routing != nil {
+		response.Metadata["routing"] = routing
+	}
 
 	c.logger.Info("Resposta LLM processada",
 		zap.String("provider", req.Provider),
-		zap.Bool("is_markdown", isMarkdown),
-		zap.Int("response_length", len(llmResponse)),
+		zap.Bool("is_markdown", response.IsMarkdown),
+		zap.Int("response_length", len(response.Response)),
 		zap.Int("files_processed", len(req.Files)),
+		zap.Int("native_documents", len(prompt.Attachments)),
+		zap.Int("citations", len(result.Citations)),
 	)
 
-	c.sendJSON(ResponsePayload{
-		Type:       "message",
-		Status:     "completed",
-		Response:   llmResponse,
-		IsMarkdown: isMarkdown,
-		Provider:   req.Provider,
-	})
+	return response
+}
+
+// messageTooBigError explica ao usuário como contornar o limite de tamanho das mensagens.
+// O histórico é reenviado a cada mensagem, então conversas longas acabam atingindo o limite.
+func messageTooBigError(size, limit int) string {
+	return fmt.Sprintf("Mensagem muito grande (%d KB; limite de %d KB). O histórico da conversa ficou grande demais: "+
+		"inicie uma nova conversa ou envie menos arquivos por mensagem. Para arquivos grandes, use POST /api/chat, "+
+		"que aceita até %d MB por requisição.", size/1024, limit/1024, MaxTotalUploadSize/(1024*1024))
 }
 
 // sendJSON envia um objeto JSON para o cliente
 func (c *Client) sendJSON(v interface{}) {
-	if c.isClosed() {
-		c.logger.Warn("Tentativa de enviar para conexão fechada")
-		return
-	}
-
 	data, err := json.Marshal(v)
 	if err != nil {
 		c.logger.Error("Erro ao serializar JSON", zap.Error(err))
 		return
 	}
 
+	if c.isClosed() {
+		c.logger.Warn("Tentativa de enviar para conexão fechada")
+		c.deadLetters.record(c.id, deadLetterConnectionClosed, data, nil)
+		return
+	}
+
 	select {
 	case c.send <- data:
 		// Sucesso
-	case <-time.After(5 * time.Second):
-		c.logger.Warn("Timeout ao enviar mensagem para cliente")
+	case <-c.done:
+		c.deadLetters.record(c.id, deadLetterConnectionClosed, data, nil)
+	case <-time.After(c.config.SendTimeout):
+		c.logger.Warn("Timeout ao enviar mensagem para cliente",
+			zap.Duration("send_timeout", c.config.SendTimeout))
 		// Adiciona à fila
-		c.queueMu.Lock()
-		c.messageQueue = append(c.messageQueue, data)
-		c.queueMu.Unlock()
+		c.enqueue(data)
 	}
 }
 
-// sendError envia uma mensagem de erro
+// sendError envia uma mensagem de erro de validação/entrada do cliente
 func (c *Client) sendError(message string) {
-	c.logger.Warn("Enviando erro para cliente", zap.String("error", message))
-	c.sendJSON(ResponsePayload{
-		Type:     "message",
-		Status:   "error",
-		Response: message,
-	})
+	c.sendJSON(c.errorResponse(message, utils.ErrorCategoryClient))
+}
+
+// errorResponse monta o payload de erro enviado ao cliente
+func (c *Client) errorResponse(message string, category utils.ErrorCategory) ResponsePayload {
+	c.logger.Warn("Enviando erro para cliente",
+		zap.String("error", message),
+		zap.String("category", string(category)))
+	return ResponsePayload{
+		Type:          "message",
+		Status:        "error",
+		Response:      message,
+		ErrorCategory: category,
+	}
 }
 
 // sendProgress envia progresso
-func (c *Client) sendProgress(message string, current, total, percentage int) {
+func (c *Client) sendProgress(message string, current, total, percentage int, eta time.Duration) {
 	c.sendJSON(ProgressPayload{
 		Type:       "progress",
 		Status:     "processing",
@@ -476,44 +778,136 @@ func (c *Client) sendProgress(message string, current, total, percentage int) {
 		Current:    current,
 		Total:      total,
 		Percentage: percentage,
+		EtaSeconds: etaSeconds(eta),
 	})
 }
 
-// truncate trunca uma string para debug
+// truncate trunca uma string para debug, sem dividir caracteres multibyte
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
 	}
-	return s[:max] + "..."
+	return utils.TruncateUTF8(s, max) + "..."
+}
+
+// fileProcessingOptions agrupa os limites aplicados ao processamento de uma requisição
+type fileProcessingOptions struct {
+	MaxImages int
+	// MaxContextChars limita o conteúdo somado dos arquivos; 0 desativa o limite
+	MaxContextChars int
+	// IncludeEmbeddedImages anexa as imagens extraídas de DOCX/PDF (modelos com visão)
+	IncludeEmbeddedImages bool
+	// ImageMode define como as imagens entram na requisição (imageInline, imageParts ou imageOmitted)
+	ImageMode string
+	// Timeout limita o tempo de decodificação e processamento dos arquivos; 0 desativa
+	Timeout time.Duration
+	// Slots limita as extrações simultâneas da conexão; nil desativa
+	Slots extractionSlots
+	// MetadataFormat define como os metadados de cada arquivo entram no contexto
+	MetadataFormat string
+	// DetectPromptInjection isola o conteúdo dos arquivos com padrões de injeção de instruções
+	DetectPromptInjection bool
+	// Untrusted são os marcadores da requisição; vazio sorteia um nonce próprio
+	Untrusted untrustedBlock
+	// MaxListedFailures limita as falhas listadas individualmente no contexto; 0 lista todas
+	MaxListedFailures int
+}
+
+// base64DecodedSize calcula o tamanho do conteúdo decodificado a partir do texto em base64,
+// sem decodificá-lo.
+func base64DecodedSize(encoded string) int64 {
+	n := int64(len(encoded)) / 4 * 3
+	if strings.HasSuffix(encoded, "==") {
+		n -= 2
+	} else if strings.HasSuffix(encoded, "=") {
+		n--
+	}
+	return n
 }
 
-// processFilesAdvanced processa múltiplos arquivos
-func processFilesAdvanced(files []FilePayload, fp *utils.FileProcessor, c *Client, logger *zap.Logger) (string, error) {
+// foldEmbeddedOCR leva ao documento o texto do OCR das imagens embutidas que não serão
+// anexadas. Um documento só com imagens passa a ter esse texto como conteúdo.
+func foldEmbeddedOCR(pf *utils.ProcessedFile, images []utils.ProcessedFile) {
+	text := utils.EmbeddedOCRText(images)
+	if text == "" {
+		return
+	}
+	pf.Metadata[utils.MetadataOCRText] = text
+	if pf.ImagesOnly {
+		pf.ImagesOnly = false
+		pf.Content = "[documento sem texto extraível; o texto abaixo foi obtido por OCR das imagens]"
+	}
+}
+
+// fileContext é o resultado do processamento dos arquivos de uma requisição.
+type fileContext struct {
+	Text string
+	// Trimmed indica se o conteúdo foi reduzido para respeitar MaxContextChars
+	Trimmed bool
+	// Types conta os arquivos incluídos no contexto por tipo
+	Types map[utils.FileType]int
+	// Images são as imagens enviadas como partes multimodais (ImageMode imageParts)
+	Images []models.Attachment
+	// SuspiciousFiles são os arquivos com possíveis tentativas de injeção de instruções
+	SuspiciousFiles []string
+}
+
+// processFilesAdvanced processa múltiplos arquivos e monta o contexto enviado ao provedor. O
+// processamento termina quando ctx é cancelado, como na desconexão do cliente.
+func processFilesAdvanced(ctx context.Context, files []FilePayload, fp *utils.FileProcessor, opts fileProcessingOptions, progress progressReporter, logger *zap.Logger) (fileContext, error) {
 	if len(files) == 0 {
-		return "", nil
+		return fileContext{}, nil
 	}
 
-	c.sendProgress("Iniciando processamento dos arquivos...", 0, len(files), 0)
+	progress.sendProgress("Iniciando processamento dos arquivos...", 0, len(files), 0, 0)
+	eta := newETAEstimator()
 
 	var totalSize int64
 	var contextBuilder strings.Builder
 	var processedFiles []utils.ProcessedFile
 	var failedFiles []string
+	imageCount := 0
+
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = time.Now().Add(opts.Timeout)
+	}
 
 	contextBuilder.WriteString("# 📁 CONTEXTO DE ARQUIVOS FORNECIDO PELO USUÁRIO\n\n")
 	contextBuilder.WriteString("## 📑 ÍNDICE DE ARQUIVOS:\n\n")
 
 	for i, file := range files {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return fileContext{}, fileProcessingTimeoutError(i, len(files), opts.Timeout, logger)
+		}
+		if err := ctx.Err(); err != nil {
+			return fileContext{}, err
+		}
+
+		if i > 0 {
+			eta.complete()
+		}
 		percentage := ((i + 1) * 100) / len(files)
-		c.sendProgress(fmt.Sprintf("Processando arquivo %d de %d: %s", i+1, len(files), file.Name), i+1, len(files), percentage)
+		progress.sendProgress(fmt.Sprintf("Processando arquivo %d de %d: %s", i+1, len(files), file.Name), i+1, len(files), percentage, eta.remaining(i, len(files)))
 
 		var content []byte
 		var err error
 
 		if file.IsBase64 {
+			// Checa os limites pelo tamanho estimado antes de alocar o conteúdo decodificado
+			estimated := base64DecodedSize(file.Content)
+			if estimated > MaxFileSize && !strings.HasPrefix(file.ContentType, "image/") && file.ContentType != "application/pdf" {
+				failedFiles = append(failedFiles, fmt.Sprintf("%s (tamanho excede %dMB)", file.Name, MaxFileSize/1024/1024))
+				recordFile(file.FileType, "rejected")
+				continue
+			}
+			if totalSize+estimated > MaxTotalUploadSize {
+				return fileContext{}, fmt.Errorf("tamanho total dos arquivos excede o limite de %d MB", MaxTotalUploadSize/1024/1024)
+			}
 			content, err = base64.StdEncoding.DecodeString(file.Content)
 			if err != nil {
 				failedFiles = append(failedFiles, fmt.Sprintf("%s (erro ao decodificar base64)", file.Name))
+				recordFile(file.FileType, "failed")
 				logger.Warn("Erro ao decodificar base64", zap.String("file", file.Name), zap.Error(err))
 				continue
 			}
@@ -524,25 +918,99 @@ func processFilesAdvanced(files []FilePayload, fp *utils.FileProcessor, c *Clien
 		fileSize := int64(len(content))
 		if fileSize > MaxFileSize && !strings.HasPrefix(file.ContentType, "image/") && file.ContentType != "application/pdf" {
 			failedFiles = append(failedFiles, fmt.Sprintf("%s (tamanho excede %dMB)", file.Name, MaxFileSize/1024/1024))
+			recordFile(file.FileType, "rejected")
 			continue
 		}
 
 		totalSize += fileSize
 		if totalSize > MaxTotalUploadSize {
-			return "", fmt.Errorf("tamanho total dos arquivos excede o limite de %d MB", MaxTotalUploadSize/1024/1024)
+			return fileContext{}, fmt.Errorf("tamanho total dos arquivos excede o limite de %d MB", MaxTotalUploadSize/1024/1024)
 		}
 
-		processed, err := fp.ProcessFile(file.Name, content)
+		if !opts.Slots.tryAcquire() {
+			progress.sendProgress(fmt.Sprintf("Aguardando %d extrações em andamento nesta conexão: %s", opts.Slots.inUse(), file.Name), i+1, len(files), percentage, eta.remaining(i, len(files)))
+			if !opts.Slots.acquireUntil(deadline) {
+				return fileContext{}, fileProcessingTimeoutError(i, len(files), opts.Timeout, logger)
+			}
+		}
+		processed, err := processFileUntil(ctx, deadline, fp, file.Name, content, file.password(), opts.Slots.release)
+		if errors.Is(err, errFileProcessingTimeout) {
+			return fileContext{}, fileProcessingTimeoutError(i, len(files), opts.Timeout, logger)
+		}
+		if errors.Is(err, context.Canceled) {
+			return fileContext{}, err
+		}
 		if err != nil {
 			failedFiles = append(failedFiles, fmt.Sprintf("%s (%s)", file.Name, err.Error()))
+			recordFile(file.FileType, "failed")
 			logger.Warn("Erro ao processar arquivo", zap.String("file", file.Name), zap.Error(err))
 			continue
 		}
 
+		if !file.NoTruncate {
+			fp.TruncateContent(processed)
+		}
+
+		if processed.FileType == utils.FileTypeImage {
+			if opts.MaxImages > 0 && imageCount >= opts.MaxImages {
+				failedFiles = append(failedFiles, fmt.Sprintf("%s (limite de %d imagens por requisição excedido)", file.Name, opts.MaxImages))
+				recordFile(string(processed.FileType), "rejected")
+				logger.Warn("Imagem descartada por exceder o limite",
+					zap.String("file", file.Name),
+					zap.Int("max_images", opts.MaxImages))
+				continue
+			}
+			imageCount++
+		}
+
+		// Imagens embutidas que cabem no limite da requisição; o texto do OCR das demais entra
+		// no contexto do próprio documento
+		included := 0
+		if opts.IncludeEmbeddedImages {
+			included = len(processed.EmbeddedImages)
+			if opts.MaxImages > 0 {
+				included = max(0, min(included, opts.MaxImages-imageCount))
+			}
+		}
+		foldEmbeddedOCR(processed, processed.EmbeddedImages[included:])
+
+		// Sem as imagens, o aviso de que foram anexadas seria falso e o documento não tem texto
+		if processed.ImagesOnly && included == 0 {
+			reason := "sem texto extraível; o modelo não aceita imagens"
+			if opts.IncludeEmbeddedImages {
+				reason = fmt.Sprintf("sem texto extraível; limite de %d imagens por requisição excedido", opts.MaxImages)
+			}
+			failedFiles = append(failedFiles, fmt.Sprintf("%s (%s)", file.Name, reason))
+			recordFile(string(processed.FileType), "rejected")
+			logger.Warn("Documento apenas com imagens descartado", zap.String("file", file.Name), zap.String("reason", reason))
+			continue
+		}
+
 		processedFiles = append(processedFiles, *processed)
+		recordFile(string(processed.FileType), "processed")
+
+		if opts.IncludeEmbeddedImages {
+			if included < len(processed.EmbeddedImages) {
+				logger.Warn("Imagens embutidas descartadas por exceder o limite",
+					zap.String("file", file.Name),
+					zap.Int("max_images", opts.MaxImages))
+			}
+			imageCount += included
+			processedFiles = append(processedFiles, processed.EmbeddedImages[:included]...)
+		}
+	}
+
+	var contextTrimmed bool
+	if opts.MaxContextChars > 0 {
+		var dropped []string
+		processedFiles, dropped, contextTrimmed = fitContextBudget(processedFiles, opts.MaxContextChars, fp, logger)
+		failedFiles = append(failedFiles, dropped...)
+		if contextTrimmed {
+			progress.sendProgress("Arquivos excedem o limite de contexto; parte do conteúdo foi reduzida", len(files), len(files), 100, 0)
+		}
 	}
 
-	c.sendProgress("Gerando contexto dos arquivos...", len(files), len(files), 100)
+	progress.sendProgress("Gerando contexto dos arquivos...", len(files), len(files), 100, 0)
 
 	for i, pf := range processedFiles {
 		icon := getFileIcon(pf.FileType)
@@ -552,40 +1020,91 @@ func processFilesAdvanced(files []FilePayload, fp *utils.FileProcessor, c *Clien
 
 	if len(failedFiles) > 0 {
 		contextBuilder.WriteString("\n### ⚠️ Arquivos com falha no processamento:\n")
-		for _, failed := range failedFiles {
+		listed := failedFiles
+		if opts.MaxListedFailures > 0 && len(listed) > opts.MaxListedFailures {
+			listed = listed[:opts.MaxListedFailures]
+		}
+		for _, failed := range listed {
 			contextBuilder.WriteString(fmt.Sprintf("- %s\n", failed))
 		}
+		if omitted := len(failedFiles) - len(listed); omitted > 0 {
+			contextBuilder.WriteString(fmt.Sprintf("- ... e mais %d arquivos com falha\n", omitted))
+		}
 	}
 
 	contextBuilder.WriteString("\n---\n\n")
 
+	var images []models.Attachment
+	var suspiciousFiles []string
+	untrusted := opts.Untrusted
+	if opts.DetectPromptInjection && untrusted.nonce == "" {
+		untrusted = newUntrustedBlock()
+	}
 	for i, pf := range processedFiles {
 		contextBuilder.WriteString(fmt.Sprintf("## 📄 ARQUIVO %d/%d: %s\n\n", i+1, len(processedFiles), pf.Name))
 
-		if len(pf.Metadata) > 0 {
-			contextBuilder.WriteString("**Metadados:**\n")
-			for key, value := range pf.Metadata {
-				contextBuilder.WriteString(fmt.Sprintf("- %s: %v\n", key, value))
+		writeFileMetadata(&contextBuilder, pf, opts.MetadataFormat)
+
+		// Nas imagens, apenas o texto do OCR é verificado; nos documentos, também o das imagens
+		// embutidas
+		ocrText, _ := pf.Metadata[utils.MetadataOCRText].(string)
+		scanned := pf.Content
+		if pf.FileType == utils.FileTypeImage {
+			scanned = ocrText
+		} else if ocrText != "" {
+			scanned += "\n" + ocrText
+		}
+
+		var suspicious bool
+		content := pf.Content
+		if opts.DetectPromptInjection && scanned != "" {
+			if patterns := detectPromptInjection(scanned); len(patterns) > 0 {
+				suspicious = true
+				suspiciousFiles = append(suspiciousFiles, pf.Name)
+				logger.Warn("Possível injeção de instruções em arquivo",
+					zap.String("file", pf.Name),
+					zap.Strings("patterns", patterns),
+				)
+				contextBuilder.WriteString(untrusted.start(pf.Name))
+				content = escapeUntrusted(content)
+				ocrText = escapeUntrusted(ocrText)
 			}
-			contextBuilder.WriteString("\n")
 		}
 
-		switch pf.FileType {
-		case utils.FileTypeImage:
+		switch {
+		case pf.FileType == utils.FileTypeImage && opts.ImageMode == imageParts:
+			images = append(images, models.Attachment{Name: pf.Name, MediaType: pf.ContentType, Data: pf.Content})
+			contextBuilder.WriteString(fmt.Sprintf("*Nota: Imagem anexada para análise visual (imagem %d da mensagem).*\n\n", len(images)))
+
+		case pf.FileType == utils.FileTypeImage && opts.ImageMode == imageOmitted:
+			contextBuilder.WriteString("*Nota: O modelo selecionado não interpreta imagens; o conteúdo desta imagem não foi enviado.*\n\n")
+
+		case pf.FileType == utils.FileTypeImage:
 			contextBuilder.WriteString(fmt.Sprintf("![%s](data:%s;base64,%s)\n\n", pf.Name, pf.ContentType, pf.Content))
 			contextBuilder.WriteString("*Nota: Imagem anexada para análise visual.*\n\n")
 
-		case utils.FileTypeCode, utils.FileTypeJSON, utils.FileTypeYAML, utils.FileTypeXML:
+		case pf.FileType == utils.FileTypeCode, pf.FileType == utils.FileTypeJSON, pf.FileType == utils.FileTypeYAML,
+			pf.FileType == utils.FileTypeXML, pf.FileType == utils.FileTypeDiff:
 			lang := getLanguageFromFileType(pf.FileType, pf.Metadata)
-			contextBuilder.WriteString(fmt.Sprintf("```%s\n%s\n```\n\n", lang, pf.Content))
+			contextBuilder.WriteString(fmt.Sprintf("```%s\n%s\n```\n\n", lang, content))
 
-		case utils.FileTypePDF, utils.FileTypeDocx, utils.FileTypeXlsx:
-			contextBuilder.WriteString(fmt.Sprintf("```\n%s\n```\n\n", pf.Content))
+		case pf.FileType == utils.FileTypePDF, pf.FileType == utils.FileTypeDocx, pf.FileType == utils.FileTypeXlsx:
+			contextBuilder.WriteString(fmt.Sprintf("```\n%s\n```\n\n", content))
 
 		default:
-			contextBuilder.WriteString(fmt.Sprintf("```\n%s\n```\n\n", pf.Content))
+			contextBuilder.WriteString(fmt.Sprintf("```\n%s\n```\n\n", content))
+		}
+		switch {
+		case ocrText == "":
+		case pf.FileType == utils.FileTypeImage:
+			contextBuilder.WriteString(fmt.Sprintf("**Texto extraído da imagem (OCR):**\n```\n%s\n```\n\n", ocrText))
+		default:
+			contextBuilder.WriteString(fmt.Sprintf("**Texto extraído das imagens do documento (OCR):**\n```\n%s\n```\n\n", ocrText))
 		}
 
+		if suspicious {
+			contextBuilder.WriteString(untrusted.end())
+		}
 		contextBuilder.WriteString("---\n\n")
 	}
 
@@ -599,7 +1118,11 @@ func processFilesAdvanced(files []FilePayload, fp *utils.FileProcessor, c *Clien
 		zap.Int64("total_size", totalSize),
 	)
 
-	return contextBuilder.String(), nil
+	types := make(map[utils.FileType]int)
+	for _, pf := range processedFiles {
+		types[pf.FileType]++
+	}
+	return fileContext{Text: contextBuilder.String(), Trimmed: contextTrimmed, Types: types, Images: images, SuspiciousFiles: suspiciousFiles}, nil
 }
 
 // detectMarkdown detecta se o texto contém markdown
@@ -632,6 +1155,8 @@ func getFileIcon(fileType utils.FileType) string {
 		utils.FileTypeXML:      "📰",
 		utils.FileTypeMarkdown: "📝",
 		utils.FileTypeCSV:      "📈",
+		utils.FileTypeDiff:     "🔀",
+		utils.FileTypeLog:      "🧾",
 		utils.FileTypeText:     "📄",
 		utils.FileTypeBinary:   "📦",
 	}
@@ -654,6 +1179,8 @@ func getLanguageFromFileType(fileType utils.FileType, metadata map[string]interf
 		return "yaml"
 	case utils.FileTypeXML:
 		return "xml"
+	case utils.FileTypeDiff:
+		return "diff"
 	default:
 		return ""
 	}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func TestWebSocketHandlerNoGoroutineLeak(t *testing.T) {
+	server := httptest.NewServer(WebSocketHandler(nil, nil, zap.NewNop()))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	baseline := runtime.NumGoroutine()
+	const connections = 50
+	for i := 0; i < connections; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("conexão %d: %v", i, err)
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.Close()
+	}
+
+	// readPump, writePump e healthCheck devem terminar logo após o fechamento, sem esperar
+	// pelos tickers
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= baseline {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			buf = buf[:runtime.Stack(buf, true)]
+			t.Fatalf("%d goroutines após fechar %d conexões, esperado até %d\n%s", n, connections, baseline, buf)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+// chunkReporter recebe os trechos de uma resposta em streaming.
+type chunkReporter interface {
+	sendChunk(provider, chunk string)
+}
+
+// sendChunk envia um trecho da resposta com status "streaming"; a resposta completa segue
+// depois, com status "completed".
+func (c *Client) sendChunk(provider, chunk string) {
+	c.sendJSON(ResponsePayload{Type: "chunk", Status: "streaming", Response: chunk, Provider: provider})
+}
+
+// sendChunk repassa o trecho à conexão atual da sessão. Trechos gerados sem conexão são
+// descartados: a resposta completa é entregue na retomada.
+func (g *sessionGeneration) sendChunk(provider, chunk string) {
+	g.mu.Lock()
+	owner := g.owner
+	g.mu.Unlock()
+	if owner != nil {
+		owner.sendChunk(provider, chunk)
+	}
+}
+
+// wsChunkHandler define o destino dos trechos gerados em uma requisição WebSocket. Com
+// "stream": true, os trechos são repassados ao cliente; sem streaming, StopPattern ainda
+// precisa acompanhar os trechos, que então são descartados. Retorna nil quando nenhum dos
+// dois se aplica, mantendo a chamada sem streaming.
+func wsChunkHandler(req RequestPayload, progress progressReporter) func(chunk string) error {
+	if reporter, ok := progress.(chunkReporter); ok && req.Stream {
+		return func(chunk string) error {
+			reporter.sendChunk(req.Provider, chunk)
+			return nil
+		}
+	}
+	if req.StopPattern != "" {
+		return func(string) error { return nil }
+	}
+	return nil
+}
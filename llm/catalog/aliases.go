@@ -0,0 +1,74 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// providerAliases mapeia nomes de exibição aceitos no campo "provider" para o provedor
+// interno. "GPT-5" é o nome com que o agente StackSpot aparece no frontend.
+//
+// Os aliases valem apenas para o campo provider, nunca para o modelo: uma requisição com
+// provider "OPENAI" e model "gpt-5" continua indo para a OpenAI.
+var (
+	aliasMu         sync.RWMutex
+	providerAliases = map[string]string{
+		"GPT-5": ProviderStackSpot,
+	}
+)
+
+// ResolveProvider normaliza o nome do provedor (maiúsculas) e resolve aliases. Nomes de
+// provedores reais têm precedência, e um alias nunca pode coincidir com eles.
+func ResolveProvider(provider string) string {
+	p := strings.ToUpper(strings.TrimSpace(provider))
+	aliasMu.RLock()
+	defer aliasMu.RUnlock()
+	if target, ok := providerAliases[p]; ok {
+		return target
+	}
+	return p
+}
+
+// ProviderAliases retorna uma cópia da tabela de aliases.
+func ProviderAliases() map[string]string {
+	aliasMu.RLock()
+	defer aliasMu.RUnlock()
+	aliases := make(map[string]string, len(providerAliases))
+	for alias, target := range providerAliases {
+		aliases[alias] = target
+	}
+	return aliases
+}
+
+// ConfigureProviderAliases acrescenta aliases no formato "ALIAS=PROVEDOR" (PROVIDER_ALIASES).
+// Retorna erro se o alias coincidir com um provedor real, apontar para um provedor
+// desconhecido ou estiver malformado; nesse caso a tabela não é alterada.
+func ConfigureProviderAliases(entries []string) error {
+	known := make(map[string]bool)
+	for _, p := range Providers() {
+		known[p] = true
+	}
+
+	aliases := ProviderAliases()
+	for _, entry := range entries {
+		alias, target, ok := strings.Cut(entry, "=")
+		alias = strings.ToUpper(strings.TrimSpace(alias))
+		target = strings.ToUpper(strings.TrimSpace(target))
+		if !ok || alias == "" || target == "" {
+			return fmt.Errorf("alias de provedor inválido: %q (use ALIAS=PROVEDOR)", entry)
+		}
+		if known[alias] {
+			return fmt.Errorf("alias %q coincide com um provedor existente", alias)
+		}
+		if !known[target] {
+			return fmt.Errorf("alias %q aponta para provedor desconhecido %q", alias, target)
+		}
+		aliases[alias] = target
+	}
+
+	aliasMu.Lock()
+	providerAliases = aliases
+	aliasMu.Unlock()
+	return nil
+}
@@ -0,0 +1,80 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/webchatcomllm/config"
+)
+
+func TestResolveProviderAmbiguousGPT5(t *testing.T) {
+	if got := ResolveProvider("GPT-5"); got != ProviderStackSpot {
+		t.Fatalf("ResolveProvider(GPT-5) = %q, want %q", got, ProviderStackSpot)
+	}
+	if got := ResolveProvider(" gpt-5 "); got != ProviderStackSpot {
+		t.Fatalf("ResolveProvider(gpt-5) = %q, want %q", got, ProviderStackSpot)
+	}
+
+	// O alias vale só para o campo provider: um modelo OpenAI chamado gpt-5 continua na OpenAI
+	if got := ResolveProvider(ProviderOpenAI); got != ProviderOpenAI {
+		t.Fatalf("ResolveProvider(OPENAI) = %q", got)
+	}
+	if meta, ok := Resolve(ProviderOpenAI, "gpt-5"); ok && meta.Provider != ProviderOpenAI {
+		t.Fatalf("Resolve(OPENAI, gpt-5) resolveu para %q", meta.Provider)
+	}
+
+	meta, ok := Resolve("GPT-5", config.StackSpotDefaultModel)
+	if !ok || meta.Provider != ProviderStackSpot {
+		t.Fatalf("Resolve(GPT-5, %q) = %+v, %v", config.StackSpotDefaultModel, meta, ok)
+	}
+}
+
+func TestConfigureProviderAliases(t *testing.T) {
+	original := ProviderAliases()
+	t.Cleanup(func() {
+		aliasMu.Lock()
+		providerAliases = original
+		aliasMu.Unlock()
+	})
+
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr string
+	}{
+		{"coincide com provedor real", []string{"OPENAI=CLAUDE"}, "coincide"},
+		{"provedor desconhecido", []string{"FOO=NOPE"}, "desconhecido"},
+		{"malformado", []string{"FOO"}, "inválido"},
+		{"alvo vazio", []string{"FOO="}, "inválido"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ConfigureProviderAliases(tt.entries)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ConfigureProviderAliases(%v) = %v, want erro com %q", tt.entries, err, tt.wantErr)
+			}
+			if got := ProviderAliases(); len(got) != len(original) {
+				t.Fatalf("tabela alterada após erro: %v", got)
+			}
+		})
+	}
+
+	if err := ConfigureProviderAliases([]string{"sonnet = claude"}); err != nil {
+		t.Fatalf("ConfigureProviderAliases: %v", err)
+	}
+	if got := ResolveProvider("Sonnet"); got != ProviderClaude {
+		t.Fatalf("ResolveProvider(Sonnet) = %q, want %q", got, ProviderClaude)
+	}
+	if got := ResolveProvider("GPT-5"); got != ProviderStackSpot {
+		t.Fatalf("alias embutido perdido: ResolveProvider(GPT-5) = %q", got)
+	}
+}
+
+func TestGetMaxTokensUnknownModel(t *testing.T) {
+	if got := GetMaxTokens(ProviderGemini, "modelo-inexistente"); got != 4096 {
+		t.Fatalf("GetMaxTokens de modelo desconhecido = %d, want 4096", got)
+	}
+	if got := GetMaxTokens(ProviderStackSpot, config.StackSpotDefaultModel); got != 8192 {
+		t.Fatalf("GetMaxTokens(STACKSPOT) = %d, want 8192", got)
+	}
+}
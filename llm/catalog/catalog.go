@@ -2,6 +2,7 @@ package catalog
 
 import (
 	"strings"
+	"sync"
 
 	"github.com/webchatcomllm/config"
 )
@@ -11,62 +12,258 @@ const (
 	ProviderStackSpot = "STACKSPOT"
 	ProviderOpenAI    = "OPENAI"
 	ProviderClaude    = "CLAUDE"
+	ProviderGemini    = "GEMINI"
+	ProviderOllama    = "OLLAMA"
 )
 
+// Providers retorna os nomes internos dos provedores suportados.
+func Providers() []string {
+	return []string{ProviderStackSpot, ProviderOpenAI, ProviderClaude, ProviderGemini, ProviderOllama}
+}
+
+// displayNames são os nomes exibidos aos usuários para cada provedor.
+var displayNames = map[string]string{
+	ProviderStackSpot: "GPT-5",
+	ProviderOpenAI:    "OpenAI",
+	ProviderClaude:    "Claude",
+	ProviderGemini:    "Gemini",
+	ProviderOllama:    "Ollama",
+}
+
+// DisplayName retorna o nome exibido do provedor (ou alias), ou o próprio nome se não houver um.
+func DisplayName(provider string) string {
+	p := ResolveProvider(provider)
+	if name, ok := displayNames[p]; ok {
+		return name
+	}
+	return p
+}
+
+// DefaultMaxImages é o limite de imagens por requisição quando o modelo não é conhecido.
+const DefaultMaxImages = 10
+
 // ModelMeta guarda metadados dos modelos
 type ModelMeta struct {
 	ID        string
 	Provider  string
 	MaxTokens int
+	MaxImages int
+	// SupportsVision indica se o modelo interpreta imagens
+	SupportsVision bool
+	// ContextWindow é o limite de tokens de entrada e saída somados; 0 quando desconhecido
+	ContextWindow int
+	// DeveloperRole indica se o modelo aceita mensagens com papel "developer" (OpenAI)
+	DeveloperRole bool
 }
 
+// registryMu protege registry, que LoadFromFile pode alterar.
+var registryMu sync.RWMutex
+
 var registry = []ModelMeta{
 	// StackSpot (Exibido como "GPT-5")
 	{
 		ID:        config.StackSpotDefaultModel,
 		Provider:  ProviderStackSpot,
 		MaxTokens: 8192,
+		MaxImages: 5,
+		// O agente recebe apenas texto; imagens chegariam como base64 no prompt
+		SupportsVision: false,
+		ContextWindow:  128000,
 	},
 	// OpenAI
 	{
 		ID:        config.OpenAIDefaultModel,
 		Provider:  ProviderOpenAI,
 		MaxTokens: 4096,
+		MaxImages: 10,
+
+		SupportsVision: true,
+		ContextWindow:  128000,
+	},
+	{
+		ID:        "gpt-4.1",
+		Provider:  ProviderOpenAI,
+		MaxTokens: 4096,
+		MaxImages: 10,
+
+		SupportsVision: true,
+		ContextWindow:  1047576,
+		DeveloperRole:  true,
+	},
+	{
+		ID:        "gpt-5",
+		Provider:  ProviderOpenAI,
+		MaxTokens: 4096,
+		MaxImages: 10,
+
+		SupportsVision: true,
+		ContextWindow:  400000,
+		DeveloperRole:  true,
+	},
+	{
+		ID:        "o1",
+		Provider:  ProviderOpenAI,
+		MaxTokens: 4096,
+		MaxImages: 10,
+
+		SupportsVision: true,
+		ContextWindow:  200000,
+		DeveloperRole:  true,
+	},
+	{
+		ID:        "o3",
+		Provider:  ProviderOpenAI,
+		MaxTokens: 4096,
+		MaxImages: 10,
+
+		SupportsVision: true,
+		ContextWindow:  200000,
+		DeveloperRole:  true,
+	},
+	{
+		ID:        "o4-mini",
+		Provider:  ProviderOpenAI,
+		MaxTokens: 4096,
+		MaxImages: 10,
+
+		SupportsVision: true,
+		ContextWindow:  200000,
+		DeveloperRole:  true,
 	},
 	// Claude
 	{
 		ID:        config.ClaudeSonnet4,
 		Provider:  ProviderClaude,
 		MaxTokens: 4096,
+		MaxImages: 20,
+
+		SupportsVision: true,
+		ContextWindow:  200000,
 	},
 	{
 		ID:        config.ClaudeSonnet45,
 		Provider:  ProviderClaude,
 		MaxTokens: 4096,
+		MaxImages: 20,
+
+		SupportsVision: true,
+		ContextWindow:  200000,
+	},
+	// Gemini
+	{
+		ID:        config.GeminiDefaultModel,
+		Provider:  ProviderGemini,
+		MaxTokens: 8192,
+		MaxImages: 16,
+
+		SupportsVision: true,
+		ContextWindow:  1048576,
+	},
+	{
+		ID:        config.GeminiPro,
+		Provider:  ProviderGemini,
+		MaxTokens: 8192,
+		MaxImages: 16,
+
+		SupportsVision: true,
+		ContextWindow:  1048576,
+	},
+	// Ollama: o servidor local aceita qualquer modelo instalado; este é o usado nos limites
+	{
+		ID:        config.OllamaDefaultModel,
+		Provider:  ProviderOllama,
+		MaxTokens: 4096,
+		MaxImages: 5,
+		// As imagens não são enviadas ao servidor local
+		SupportsVision: false,
+		// O servidor corta a entrada que excede num_ctx em vez de recusá-la
+		ContextWindow: 0,
 	},
 }
 
-// Resolve encontra metadados de um modelo pelo provedor e ID.
+// Resolve encontra metadados de um modelo pelo provedor (ou alias) e ID.
 func Resolve(provider, modelID string) (ModelMeta, bool) {
-	p := strings.ToUpper(provider)
-	m := strings.ToLower(modelID)
-
+	p := ResolveProvider(provider)
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	for _, meta := range registry {
-		// Mapeamento especial para o nome de exibição "GPT-5"
-		if p == "GPT-5" && meta.Provider == ProviderStackSpot {
+		if meta.Provider == p && strings.EqualFold(meta.ID, modelID) {
 			return meta, true
 		}
-		if meta.Provider == p && meta.ID == m {
+	}
+	return ModelMeta{}, false
+}
+
+// Models retorna os modelos registrados do provedor (ou alias), na ordem do catálogo.
+func Models(provider string) []ModelMeta {
+	p := ResolveProvider(provider)
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var models []ModelMeta
+	for _, meta := range registry {
+		if meta.Provider == p {
+			models = append(models, meta)
+		}
+	}
+	return models
+}
+
+// resolveOrDefault usa o modelo informado ou, se não for encontrado, o primeiro modelo
+// registrado do provedor (no StackSpot, o modelo é definido pelo agente).
+func resolveOrDefault(provider, modelID string) (ModelMeta, bool) {
+	if meta, ok := Resolve(provider, modelID); ok {
+		return meta, true
+	}
+	p := ResolveProvider(provider)
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, meta := range registry {
+		if meta.Provider == p {
 			return meta, true
 		}
 	}
 	return ModelMeta{}, false
 }
 
-// GetMaxTokens retorna o limite de tokens de um modelo.
+// GetMaxTokens retorna o limite de tokens de um modelo. Modelos fora do catálogo usam o
+// fallback genérico de 4096 tokens, e não o limite de outro modelo do provedor.
 func GetMaxTokens(provider, modelID string) int {
 	if meta, ok := Resolve(provider, modelID); ok {
 		return meta.MaxTokens
 	}
 	return 4096 // Fallback genérico
 }
+
+// GetMaxImages retorna o limite de imagens por requisição de um modelo.
+// Se o modelo não for encontrado, usa o primeiro modelo registrado do provedor.
+func GetMaxImages(provider, modelID string) int {
+	if meta, ok := resolveOrDefault(provider, modelID); ok {
+		return meta.MaxImages
+	}
+	return DefaultMaxImages
+}
+
+// SupportsVision indica se o modelo interpreta imagens. Se o modelo não for encontrado,
+// usa o primeiro modelo registrado do provedor; provedores desconhecidos são considerados sem visão.
+func SupportsVision(provider, modelID string) bool {
+	if meta, ok := resolveOrDefault(provider, modelID); ok {
+		return meta.SupportsVision
+	}
+	return false
+}
+
+// SupportsDeveloperRole indica se o modelo aceita mensagens com papel "developer". Modelos
+// fora do catálogo não aceitam, e essas mensagens seguem como "system".
+func SupportsDeveloperRole(provider, modelID string) bool {
+	meta, ok := Resolve(provider, modelID)
+	return ok && meta.DeveloperRole
+}
+
+// GetContextWindow retorna a janela de contexto de um modelo, ou 0 quando desconhecida.
+// Se o modelo não for encontrado, usa o primeiro modelo registrado do provedor.
+func GetContextWindow(provider, modelID string) int {
+	if meta, ok := resolveOrDefault(provider, modelID); ok {
+		return meta.ContextWindow
+	}
+	return 0
+}
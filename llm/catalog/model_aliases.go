@@ -0,0 +1,99 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/webchatcomllm/config"
+)
+
+// modelAliases mapeia, por provedor, nomes estáveis de modelo para o ID concreto atual. Assim
+// os clientes não precisam trocar o ID quando o provedor descontinua um modelo.
+var (
+	modelAliasMu sync.RWMutex
+	modelAliases = map[string]map[string]string{
+		ProviderOpenAI: {
+			"gpt-latest": config.OpenAIDefaultModel,
+		},
+		ProviderClaude: {
+			"claude-latest": config.ClaudeSonnet45,
+		},
+		ProviderGemini: {
+			"gemini-latest": config.GeminiDefaultModel,
+		},
+	}
+)
+
+// ResolveModel troca um alias de modelo pelo ID concreto do provedor. Modelos que não são
+// aliases (inclusive vazios) são retornados sem alteração.
+func ResolveModel(provider, model string) string {
+	modelAliasMu.RLock()
+	defer modelAliasMu.RUnlock()
+	if id, ok := modelAliases[ResolveProvider(provider)][strings.ToLower(strings.TrimSpace(model))]; ok {
+		return id
+	}
+	return model
+}
+
+// ModelAliases retorna uma cópia dos aliases de modelo, por provedor.
+func ModelAliases() map[string]map[string]string {
+	modelAliasMu.RLock()
+	defer modelAliasMu.RUnlock()
+	aliases := make(map[string]map[string]string, len(modelAliases))
+	for provider, entries := range modelAliases {
+		aliases[provider] = make(map[string]string, len(entries))
+		for alias, id := range entries {
+			aliases[provider][alias] = id
+		}
+	}
+	return aliases
+}
+
+// ConfigureModelAliases acrescenta ou substitui aliases no formato "PROVEDOR:alias=modelo"
+// (MODEL_ALIASES). Retorna erro para entradas malformadas ou provedores desconhecidos; nesse
+// caso a tabela não é alterada.
+func ConfigureModelAliases(entries []string) error {
+	known := make(map[string]bool)
+	for _, p := range Providers() {
+		known[p] = true
+	}
+
+	aliases := ModelAliases()
+	for _, entry := range entries {
+		key, id, ok := strings.Cut(entry, "=")
+		provider, alias, hasProvider := strings.Cut(key, ":")
+		provider = ResolveProvider(provider)
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		id = strings.TrimSpace(id)
+		if !ok || !hasProvider || alias == "" || id == "" {
+			return fmt.Errorf("alias de modelo inválido: %q (use PROVEDOR:alias=modelo)", entry)
+		}
+		if !known[provider] {
+			return fmt.Errorf("alias de modelo %q usa provedor desconhecido %q", alias, provider)
+		}
+		if aliases[provider] == nil {
+			aliases[provider] = make(map[string]string)
+		}
+		aliases[provider][alias] = id
+	}
+
+	modelAliasMu.Lock()
+	modelAliases = aliases
+	modelAliasMu.Unlock()
+	return nil
+}
+
+// setModelAliases acrescenta ou substitui aliases já validados, por provedor.
+func setModelAliases(entries map[string]map[string]string) {
+	modelAliasMu.Lock()
+	defer modelAliasMu.Unlock()
+	for provider, aliases := range entries {
+		if modelAliases[provider] == nil {
+			modelAliases[provider] = make(map[string]string)
+		}
+		for alias, id := range aliases {
+			modelAliases[provider][alias] = id
+		}
+	}
+}
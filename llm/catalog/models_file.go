@@ -0,0 +1,119 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// modelFileEntry é uma entrada do arquivo de modelos (MODELS_CONFIG_PATH).
+type modelFileEntry struct {
+	ID             string `json:"id"`
+	Provider       string `json:"provider"`
+	MaxTokens      int    `json:"maxTokens"`
+	MaxImages      int    `json:"maxImages"`
+	SupportsVision bool   `json:"supportsVision"`
+	ContextWindow  int    `json:"contextWindow"`
+	DeveloperRole  bool   `json:"developerRole"`
+	// Aliases são nomes estáveis de modelo ("claude-latest") que passam a apontar para esta entrada
+	Aliases []string `json:"aliases"`
+}
+
+// LoadFromFile lê modelos de um arquivo JSON (uma lista de objetos com id, provider, maxTokens,
+// maxImages, supportsVision, contextWindow, developerRole e aliases) e os mescla ao catálogo: uma entrada com o mesmo
+// provedor e ID de um modelo existente o substitui; as demais são acrescentadas. Os aliases
+// substituem os de mesmo nome no provedor. O arquivo é validado por inteiro antes de qualquer
+// alteração; em caso de erro, o catálogo não muda.
+func LoadFromFile(path string) error {
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		return fmt.Errorf("arquivo de modelos %s: formato YAML não suportado, use JSON", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("arquivo de modelos: %w", err)
+	}
+
+	var entries []modelFileEntry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&entries); err != nil {
+		return fmt.Errorf("arquivo de modelos %s inválido: %w", path, err)
+	}
+
+	known := make(map[string]bool)
+	for _, p := range Providers() {
+		known[p] = true
+	}
+	seen := make(map[string]bool)
+	metas := make([]ModelMeta, 0, len(entries))
+	aliases := make(map[string]map[string]string)
+	for i, e := range entries {
+		provider := strings.ToUpper(strings.TrimSpace(e.Provider))
+		id := strings.TrimSpace(e.ID)
+		switch {
+		case id == "":
+			return fmt.Errorf("arquivo de modelos %s: entrada %d sem id", path, i+1)
+		case !known[provider]:
+			return fmt.Errorf("arquivo de modelos %s: modelo %q usa provedor desconhecido %q (provedores: %s)",
+				path, id, e.Provider, strings.Join(Providers(), ", "))
+		case e.MaxTokens <= 0:
+			return fmt.Errorf("arquivo de modelos %s: modelo %q precisa de maxTokens positivo", path, id)
+		case e.MaxImages < 0 || e.ContextWindow < 0:
+			return fmt.Errorf("arquivo de modelos %s: modelo %q tem limites negativos", path, id)
+		}
+		key := provider + "/" + strings.ToLower(id)
+		if seen[key] {
+			return fmt.Errorf("arquivo de modelos %s: modelo %q repetido para %s", path, id, provider)
+		}
+		seen[key] = true
+
+		for _, alias := range e.Aliases {
+			alias = strings.ToLower(strings.TrimSpace(alias))
+			if alias == "" {
+				return fmt.Errorf("arquivo de modelos %s: modelo %q tem alias vazio", path, id)
+			}
+			if aliases[provider] == nil {
+				aliases[provider] = make(map[string]string)
+			}
+			if other, ok := aliases[provider][alias]; ok {
+				return fmt.Errorf("arquivo de modelos %s: alias %q repetido para %s (modelos %q e %q)", path, alias, provider, other, id)
+			}
+			aliases[provider][alias] = id
+		}
+
+		meta := ModelMeta{
+			ID:             id,
+			Provider:       provider,
+			MaxTokens:      e.MaxTokens,
+			MaxImages:      e.MaxImages,
+			SupportsVision: e.SupportsVision,
+			ContextWindow:  e.ContextWindow,
+			DeveloperRole:  e.DeveloperRole,
+		}
+		if meta.MaxImages == 0 {
+			meta.MaxImages = DefaultMaxImages
+		}
+		metas = append(metas, meta)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	setModelAliases(aliases)
+	for _, meta := range metas {
+		replaced := false
+		for i, existing := range registry {
+			if existing.Provider == meta.Provider && strings.EqualFold(existing.ID, meta.ID) {
+				registry[i] = meta
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			registry = append(registry, meta)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package catalog
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// restoreRegistry devolve o catálogo embutido ao fim do teste.
+func restoreRegistry(t *testing.T) {
+	t.Helper()
+	registryMu.RLock()
+	original := append([]ModelMeta(nil), registry...)
+	registryMu.RUnlock()
+	aliases := ModelAliases()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = original
+		registryMu.Unlock()
+		modelAliasMu.Lock()
+		modelAliases = aliases
+		modelAliasMu.Unlock()
+	})
+}
+
+func writeModelsFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFromFileReplacesBuiltIn(t *testing.T) {
+	restoreRegistry(t)
+	before := len(Models(ProviderOpenAI))
+
+	path := writeModelsFile(t, "models.json", `[
+		{"id": "GPT-4o", "provider": "openai", "maxTokens": 1234, "maxImages": 2, "supportsVision": false}
+	]`)
+	if err := LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, ok := Resolve(ProviderOpenAI, "gpt-4o")
+	if !ok || meta.MaxTokens != 1234 || meta.MaxImages != 2 || meta.SupportsVision {
+		t.Fatalf("Resolve(OPENAI, gpt-4o) = %+v, %v", meta, ok)
+	}
+	if got := len(Models(ProviderOpenAI)); got != before {
+		t.Fatalf("modelos OpenAI = %d, want %d (substituição não deveria acrescentar)", got, before)
+	}
+}
+
+func TestLoadFromFileAppends(t *testing.T) {
+	restoreRegistry(t)
+	before := len(Models(ProviderClaude))
+
+	path := writeModelsFile(t, "models.json", `[
+		{"id": "claude-novo", "provider": "CLAUDE", "maxTokens": 4096, "contextWindow": 200000, "supportsVision": true}
+	]`)
+	if err := LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, ok := Resolve(ProviderClaude, "claude-novo")
+	if !ok || meta.ContextWindow != 200000 || !meta.SupportsVision {
+		t.Fatalf("Resolve(CLAUDE, claude-novo) = %+v, %v", meta, ok)
+	}
+	if meta.MaxImages != DefaultMaxImages {
+		t.Fatalf("MaxImages = %d, want o padrão %d", meta.MaxImages, DefaultMaxImages)
+	}
+	if got := len(Models(ProviderClaude)); got != before+1 {
+		t.Fatalf("modelos Claude = %d, want %d", got, before+1)
+	}
+}
+
+func TestLoadFromFileAliases(t *testing.T) {
+	restoreRegistry(t)
+
+	path := writeModelsFile(t, "models.json", `[
+		{"id": "claude-novo", "provider": "CLAUDE", "maxTokens": 4096, "aliases": ["Claude-Latest", "claude-estavel"]}
+	]`)
+	if err := LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, alias := range []string{"claude-latest", "claude-estavel"} {
+		if got := ResolveModel(ProviderClaude, alias); got != "claude-novo" {
+			t.Fatalf("ResolveModel(CLAUDE, %s) = %q, want claude-novo", alias, got)
+		}
+	}
+	// Os aliases dos outros provedores não mudam
+	if got := ResolveModel(ProviderOpenAI, "gpt-latest"); got == "claude-novo" {
+		t.Fatalf("alias da OpenAI alterado: %q", got)
+	}
+}
+
+func TestLoadFromFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"provedor desconhecido", "models.json", `[{"id": "x", "provider": "NOPE", "maxTokens": 10}]`, "provedor desconhecido"},
+		{"entrada repetida", "models.json", `[
+			{"id": "m1", "provider": "OPENAI", "maxTokens": 10},
+			{"id": "M1", "provider": "openai", "maxTokens": 20}
+		]`, "repetido"},
+		{"sem id", "models.json", `[{"provider": "OPENAI", "maxTokens": 10}]`, "sem id"},
+		{"maxTokens ausente", "models.json", `[{"id": "x", "provider": "OPENAI"}]`, "maxTokens positivo"},
+		{"campo desconhecido", "models.json", `[{"id": "x", "provider": "OPENAI", "maxTokens": 10, "tokens": 1}]`, "inválido"},
+		{"alias repetido", "models.json", `[
+			{"id": "m1", "provider": "OPENAI", "maxTokens": 10, "aliases": ["estavel"]},
+			{"id": "m2", "provider": "OPENAI", "maxTokens": 10, "aliases": ["Estavel"]}
+		]`, "alias \"estavel\" repetido"},
+		{"alias vazio", "models.json", `[{"id": "m1", "provider": "OPENAI", "maxTokens": 10, "aliases": [" "]}]`, "alias vazio"},
+		{"YAML", "models.yaml", "- id: x\n  provider: OPENAI\n", "YAML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreRegistry(t)
+			before := len(Models(ProviderOpenAI))
+
+			err := LoadFromFile(writeModelsFile(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("erro = %v, want contendo %q", err, tt.want)
+			}
+			// O arquivo é validado antes de qualquer alteração
+			if got := len(Models(ProviderOpenAI)); got != before {
+				t.Fatalf("catálogo alterado após erro: %d modelos, want %d", got, before)
+			}
+		})
+	}
+}
+
+func TestLoadFromFileMissing(t *testing.T) {
+	err := LoadFromFile(filepath.Join(t.TempDir(), "ausente.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("erro = %v, want fs.ErrNotExist", err)
+	}
+}
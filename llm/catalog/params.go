@@ -0,0 +1,97 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// providerParams lista, por provedor, os parâmetros que podem ser repassados da requisição
+// ao corpo enviado à API. Campos que alteram o modelo, as mensagens, o streaming ou o destino
+// da chamada nunca entram na lista.
+var providerParams = map[string]map[string]bool{
+	ProviderOpenAI: {
+		"temperature":       true,
+		"top_p":             true,
+		"frequency_penalty": true,
+		"presence_penalty":  true,
+		"seed":              true,
+		"stop":              true,
+		"logit_bias":        true,
+		"reasoning_effort":  true,
+		"user":              true,
+	},
+	ProviderClaude: {
+		"temperature":    true,
+		"top_p":          true,
+		"top_k":          true,
+		"stop_sequences": true,
+		"metadata":       true,
+	},
+	// No Gemini, os parâmetros entram em generationConfig
+	ProviderGemini: {
+		"temperature":      true,
+		"topP":             true,
+		"topK":             true,
+		"stopSequences":    true,
+		"seed":             true,
+		"presencePenalty":  true,
+		"frequencyPenalty": true,
+	},
+	// No Ollama, os parâmetros entram em options
+	ProviderOllama: {
+		"temperature":    true,
+		"top_p":          true,
+		"top_k":          true,
+		"seed":           true,
+		"stop":           true,
+		"num_ctx":        true,
+		"repeat_penalty": true,
+	},
+}
+
+// AllowedParams retorna, em ordem alfabética, os parâmetros aceitos pelo provedor.
+func AllowedParams(provider string) []string {
+	var keys []string
+	for key := range providerParams[ResolveProvider(provider)] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateProviderParams retorna erro se algum parâmetro não estiver na lista do provedor.
+func ValidateProviderParams(provider string, params map[string]interface{}) error {
+	allowed := providerParams[ResolveProvider(provider)]
+	var rejected []string
+	for key := range params {
+		if !allowed[key] {
+			rejected = append(rejected, key)
+		}
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+	sort.Strings(rejected)
+	if len(allowed) == 0 {
+		return fmt.Errorf("o provedor %s não aceita parâmetros adicionais (recebido: %s)",
+			provider, strings.Join(rejected, ", "))
+	}
+	return fmt.Errorf("parâmetros não permitidos para %s: %s (permitidos: %s)",
+		provider, strings.Join(rejected, ", "), strings.Join(AllowedParams(provider), ", "))
+}
+
+// MergeProviderParams copia para body apenas os parâmetros permitidos ao provedor, sem
+// sobrescrever campos já definidos pelo cliente.
+func MergeProviderParams(provider string, body map[string]interface{}, params map[string]interface{}) {
+	allowed := providerParams[ResolveProvider(provider)]
+	for key, value := range params {
+		if !allowed[key] {
+			continue
+		}
+		if _, exists := body[key]; exists {
+			continue
+		}
+		body[key] = value
+	}
+}
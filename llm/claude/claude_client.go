@@ -11,28 +11,40 @@ import (
 
 	"github.com/webchatcomllm/config"
 	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/llm/client"
 	"github.com/webchatcomllm/models"
 	"github.com/webchatcomllm/utils"
 	"go.uber.org/zap"
 )
 
+// maxLoggedBody limita o corpo registrado em log quando a resposta não pode ser interpretada.
+const maxLoggedBody = 4000
+
 type Client struct {
-	apiKey      string
-	model       string
-	logger      *zap.Logger
-	httpClient  *http.Client
-	maxAttempts int
-	backoff     time.Duration
+	apiKey     string
+	model      string
+	logger     *zap.Logger
+	httpClient *http.Client
+	// streamClient não tem timeout total: a duração do streaming é limitada pelo contexto
+	streamClient *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	headers      http.Header
+	usage        *models.Usage
+	params       map[string]interface{}
+	rawResponse  []byte
+	images       []models.Attachment
 }
 
 func NewClient(apiKey, model string, logger *zap.Logger, maxAttempts int, backoff time.Duration) *Client {
 	return &Client{
-		apiKey:      apiKey,
-		model:       model,
-		logger:      logger,
-		httpClient:  utils.NewHTTPClient(logger, 90*time.Second),
-		maxAttempts: maxAttempts,
-		backoff:     backoff,
+		apiKey:       apiKey,
+		model:        model,
+		logger:       logger,
+		httpClient:   utils.NewHTTPClient(logger, 90*time.Second),
+		streamClient: utils.NewHTTPClient(logger, 0),
+		maxAttempts:  maxAttempts,
+		backoff:      backoff,
 	}
 }
 
@@ -40,65 +52,329 @@ func (c *Client) GetModelName() string {
 	return c.model
 }
 
+// SetProviderParams define os parâmetros da requisição mesclados ao corpo enviado à API.
+func (c *Client) SetProviderParams(params map[string]interface{}) {
+	c.params = params
+}
+
+// SetImages define as imagens enviadas como blocos "image" da mensagem do usuário.
+func (c *Client) SetImages(images []models.Attachment) {
+	c.images = images
+}
+
+// supportedImageTypes são os formatos de imagem aceitos pela API da Anthropic.
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// validateImages recusa, antes da chamada, imagens em formatos que a API rejeitaria.
+func validateImages(images []models.Attachment) error {
+	for _, img := range images {
+		if !supportedImageTypes[img.MediaType] {
+			return &utils.CategorizedError{
+				Category: utils.ErrorCategoryClient,
+				Err:      fmt.Errorf("formato de imagem não suportado pela Claude: %s em %s (use JPEG, PNG, GIF ou WebP)", img.MediaType, img.Name),
+			}
+		}
+	}
+	return nil
+}
+
+// WithExtraHeaders define cabeçalhos adicionais enviados em todas as requisições ao provedor.
+func (c *Client) WithExtraHeaders(headers http.Header) *Client {
+	c.headers = headers
+	return c
+}
+
 func (c *Client) SendPrompt(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, error) {
+	return c.SendPromptWithAttachments(ctx, prompt, history, maxTokens, nil)
+}
+
+// SupportsAttachment indica os tipos aceitos como bloco de documento nativo.
+func (c *Client) SupportsAttachment(mediaType string) bool {
+	return mediaType == "application/pdf"
+}
+
+// SendPromptWithAttachments envia o prompt com arquivos anexados como blocos "document".
+func (c *Client) SendPromptWithAttachments(ctx context.Context, prompt string, history []models.Message, maxTokens int, attachments []models.Attachment) (string, error) {
 	if maxTokens <= 0 {
 		maxTokens = catalog.GetMaxTokens(catalog.ProviderClaude, c.model)
 	}
+	if err := client.CheckInputSize(catalog.ProviderClaude, c.model, prompt, history, maxTokens); err != nil {
+		return "", err
+	}
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderClaude)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 
-	messages := buildMessages(prompt, history)
+	if err := validateImages(c.images); err != nil {
+		return "", err
+	}
+	system, messages := buildMessages(prompt, history, append(append([]models.Attachment(nil), c.images...), attachments...))
 
 	reqBody := map[string]interface{}{
 		"model":      c.model,
 		"messages":   messages,
 		"max_tokens": maxTokens,
 	}
+	if system != "" {
+		reqBody["system"] = system
+	}
+	catalog.MergeProviderParams(catalog.ProviderClaude, reqBody, c.params)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return "", fmt.Errorf("erro ao serializar request: %w", err)
 	}
 
-	responseText, err := utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (string, error) {
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.ClaudeAPIURL, utils.NewJSONReader(jsonData))
-		if err != nil {
-			return "", fmt.Errorf("erro ao criar requisição: %w", err)
+	resp, err := c.post(ctx, c.httpClient, jsonData)
+	if err != nil {
+		return "", utils.CategorizeError(err)
+	}
+	responseText, body, usage, err := parseClaudeResponse(resp, c.logger)
+	c.rawResponse = body
+	c.usage = usage
+	return responseText, utils.CategorizeError(err)
+}
+
+// LastRawResponse retorna o corpo bruto da última resposta sem streaming.
+func (c *Client) LastRawResponse() []byte {
+	return c.rawResponse
+}
+
+// LastUsage retorna o consumo de tokens da última chamada, quando informado pela API.
+func (c *Client) LastUsage() *models.Usage {
+	return c.usage
+}
+
+// SendPromptStream envia o prompt com "stream": true e repassa a onChunk o texto de cada
+// content_block_delta. Os tokens de entrada chegam em message_start e os de saída, acumulados,
+// em message_delta. Apenas a abertura da conexão passa pelo retry.
+func (c *Client) SendPromptStream(ctx context.Context, prompt string, history []models.Message, maxTokens int, onChunk func(chunk string) error) (string, error) {
+	if maxTokens <= 0 {
+		maxTokens = catalog.GetMaxTokens(catalog.ProviderClaude, c.model)
+	}
+	if err := client.CheckInputSize(catalog.ProviderClaude, c.model, prompt, history, maxTokens); err != nil {
+		return "", err
+	}
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderClaude)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	if err := validateImages(c.images); err != nil {
+		return "", err
+	}
+	system, messages := buildMessages(prompt, history, c.images)
+	reqBody := map[string]interface{}{
+		"model":      c.model,
+		"messages":   messages,
+		"max_tokens": maxTokens,
+		"stream":     true,
+	}
+	if system != "" {
+		reqBody["system"] = system
+	}
+	catalog.MergeProviderParams(catalog.ProviderClaude, reqBody, c.params)
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("erro ao serializar request: %w", err)
+	}
+
+	resp, err := c.post(ctx, c.streamClient, jsonData)
+	if err != nil {
+		return "", utils.CategorizeError(err)
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	usage := &models.Usage{}
+	err = utils.ReadSSE(resp.Body, func(event, data string) error {
+		switch event {
+		case "message_start":
+			var start struct {
+				Message struct {
+					Usage claudeUsage `json:"usage"`
+				} `json:"message"`
+			}
+			if err := json.Unmarshal([]byte(data), &start); err == nil {
+				usage.PromptTokens = start.Message.Usage.InputTokens
+				usage.CompletionTokens = start.Message.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			var delta struct {
+				Delta struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"delta"`
+			}
+			if err := json.Unmarshal([]byte(data), &delta); err != nil {
+				return fmt.Errorf("erro ao decodificar evento do stream: %w", err)
+			}
+			if delta.Delta.Type != "text_delta" || delta.Delta.Text == "" {
+				return nil
+			}
+			full.WriteString(delta.Delta.Text)
+			return onChunk(delta.Delta.Text)
+		case "message_delta":
+			var msgDelta struct {
+				Usage claudeUsage `json:"usage"`
+			}
+			if err := json.Unmarshal([]byte(data), &msgDelta); err == nil && msgDelta.Usage.OutputTokens > 0 {
+				usage.CompletionTokens = msgDelta.Usage.OutputTokens
+			}
+		case "message_stop":
+			return io.EOF
+		case "error":
+			return streamError(data)
+		case "ping":
+			// Mantém a conexão ativa; não traz conteúdo
 		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("x-api-key", c.apiKey)
-		req.Header.Set("anthropic-version", config.ClaudeAPIVersion)
+		return nil
+	})
+	if err != nil {
+		return full.String(), utils.CategorizeError(err)
+	}
 
-		resp, err := c.httpClient.Do(req)
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	c.usage = usage
+	return full.String(), nil
+}
+
+// streamErrorStatus associa os tipos de erro enviados no meio do stream ao status HTTP que a
+// API usaria antes de iniciá-lo, para que a classificação de retry e categoria seja a mesma.
+var streamErrorStatus = map[string]int{
+	"overloaded_error":      529,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+}
+
+// streamError converte o evento "error" do stream em *utils.APIError.
+func streamError(data string) error {
+	var event struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal([]byte(data), &event)
+	status, ok := streamErrorStatus[event.Error.Type]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	return utils.NewAPIError(catalog.ProviderClaude, status, []byte(data))
+}
+
+// claudeUsage é o formato de consumo de tokens retornado pela API da Anthropic.
+type claudeUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// post envia o payload com retry e backoff, usado pelas chamadas com e sem streaming. Respostas
+// com status diferente de 200 viram *utils.APIError; no streaming, apenas a abertura da
+// conexão é repetida.
+func (c *Client) post(ctx context.Context, httpClient *http.Client, payload []byte) (*http.Response, error) {
+	return utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (*http.Response, error) {
+		resp, err := c.do(ctx, httpClient, payload)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return parseClaudeResponse(resp)
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			return nil, utils.NewAPIError(catalog.ProviderClaude, resp.StatusCode, body).WithRetryAfter(resp.Header)
+		}
+		return resp, nil
 	})
+}
 
-	return responseText, err
+// do envia o payload serializado ao endpoint de mensagens.
+func (c *Client) do(ctx context.Context, httpClient *http.Client, payload []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.ClaudeAPIURL, utils.NewJSONReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", config.ClaudeAPIVersion)
+	utils.ApplyHeaders(req, c.headers)
+	return httpClient.Do(req)
 }
 
-func buildMessages(prompt string, history []models.Message) []map[string]string {
-	var messages []map[string]string
+// buildMessages converte o histórico para o formato da API. Mensagens com papel "system" ou
+// "developer" não são aceitas em messages e são retornadas separadamente para o campo system.
+func buildMessages(prompt string, history []models.Message, attachments []models.Attachment) (string, []map[string]interface{}) {
+	var systemParts []string
+	var messages []map[string]interface{}
 	for _, msg := range history {
+		if msg.IsInstruction() {
+			systemParts = append(systemParts, msg.Content)
+			continue
+		}
 		role := "user"
 		if msg.Role == "assistant" {
 			role = "assistant"
 		}
-		messages = append(messages, map[string]string{"role": role, "content": msg.Content})
+		messages = append(messages, map[string]interface{}{"role": role, "content": msg.Content})
 	}
-	messages = append(messages, map[string]string{"role": "user", "content": prompt})
-	return messages
+	messages = append(messages, map[string]interface{}{"role": "user", "content": buildUserContent(prompt, attachments)})
+	return strings.Join(systemParts, "\n\n"), messages
 }
 
-func parseClaudeResponse(resp *http.Response) (string, error) {
+// buildUserContent retorna o texto puro ou, havendo anexos, os blocos de conteúdo da mensagem:
+// imagens como blocos "image" e demais arquivos como "document".
+func buildUserContent(prompt string, attachments []models.Attachment) interface{} {
+	if len(attachments) == 0 {
+		return prompt
+	}
+
+	var blocks []map[string]interface{}
+	for _, att := range attachments {
+		if strings.HasPrefix(att.MediaType, "image/") {
+			blocks = append(blocks, map[string]interface{}{
+				"type": "image",
+				"source": map[string]string{
+					"type":       "base64",
+					"media_type": att.MediaType,
+					"data":       att.Data,
+				},
+			})
+			continue
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type":  "document",
+			"title": att.Name,
+			"source": map[string]string{
+				"type":       "base64",
+				"media_type": att.MediaType,
+				"data":       att.Data,
+			},
+		})
+	}
+	// A Anthropic recomenda posicionar imagens e documentos antes da pergunta.
+	blocks = append(blocks, map[string]interface{}{"type": "text", "text": prompt})
+	return blocks
+}
+
+// parseClaudeResponse extrai o texto e o consumo de tokens de uma resposta sem streaming; o
+// status já foi verificado por post.
+func parseClaudeResponse(resp *http.Response, logger *zap.Logger) (string, []byte, *models.Usage, error) {
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("erro ao ler resposta: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", &utils.APIError{StatusCode: resp.StatusCode, Message: string(body)}
+		return "", nil, nil, fmt.Errorf("erro ao ler resposta: %w", err)
 	}
 
 	var result struct {
@@ -106,10 +382,21 @@ func parseClaudeResponse(resp *http.Response) (string, error) {
 			Type string `json:"type"`
 			Text string `json:"text"`
 		} `json:"content"`
+		Usage *claudeUsage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("erro ao decodificar resposta: %w", err)
+		logger.Debug("Resposta da Claude não pôde ser decodificada", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return "", body, nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+	}
+
+	var usage *models.Usage
+	if result.Usage != nil {
+		usage = &models.Usage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+			TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
+		}
 	}
 
 	var responseText strings.Builder
@@ -120,8 +407,151 @@ func parseClaudeResponse(resp *http.Response) (string, error) {
 	}
 
 	if responseText.Len() == 0 {
-		return "", fmt.Errorf("resposta vazia da API")
+		logger.Debug("Resposta da Claude sem blocos de texto", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return "", body, usage, fmt.Errorf("resposta vazia da API")
+	}
+
+	return responseText.String(), body, usage, nil
+}
+
+// claudeContentBlock é um bloco de conteúdo da resposta: texto ou chamada de ferramenta.
+type claudeContentBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// SendPromptWithTools envia o prompt com as ferramentas em "tools" e executa os blocos tool_use
+// da resposta, devolvendo os resultados como blocos tool_result em uma mensagem do usuário. Na
+// última rodada, tool_choice "none" obriga a resposta em texto.
+func (c *Client) SendPromptWithTools(ctx context.Context, prompt string, history []models.Message, maxTokens int, tools []models.Tool, execute client.ToolExecutor) (string, error) {
+	if maxTokens <= 0 {
+		maxTokens = catalog.GetMaxTokens(catalog.ProviderClaude, c.model)
+	}
+	if err := client.CheckInputSize(catalog.ProviderClaude, c.model, prompt, history, maxTokens); err != nil {
+		return "", err
+	}
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderClaude)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	if err := validateImages(c.images); err != nil {
+		return "", err
 	}
+	system, messages := buildMessages(prompt, history, c.images)
 
-	return responseText.String(), nil
+	toolDefs := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		toolDefs = append(toolDefs, map[string]interface{}{
+			"name":         tool.Name,
+			"description":  tool.Description,
+			"input_schema": tool.Parameters,
+		})
+	}
+	total := &models.Usage{}
+
+	for round := 1; ; round++ {
+		reqBody := map[string]interface{}{
+			"model":      c.model,
+			"messages":   messages,
+			"max_tokens": maxTokens,
+			"tools":      toolDefs,
+		}
+		if system != "" {
+			reqBody["system"] = system
+		}
+		if round >= client.MaxToolRounds {
+			reqBody["tool_choice"] = map[string]string{"type": "none"}
+		}
+		catalog.MergeProviderParams(catalog.ProviderClaude, reqBody, c.params)
+
+		jsonData, err := json.Marshal(reqBody)
+		if err != nil {
+			return "", fmt.Errorf("erro ao serializar request: %w", err)
+		}
+
+		resp, err := c.post(ctx, c.httpClient, jsonData)
+		if err != nil {
+			return "", utils.CategorizeError(err)
+		}
+		blocks, body, usage, err := parseClaudeBlocks(resp, c.logger)
+		c.rawResponse = body
+		if usage != nil {
+			total.PromptTokens += usage.PromptTokens
+			total.CompletionTokens += usage.CompletionTokens
+			total.TotalTokens += usage.TotalTokens
+		}
+		c.usage = total
+		if err != nil {
+			return "", utils.CategorizeError(err)
+		}
+
+		var text strings.Builder
+		var results []map[string]interface{}
+		ignoredCalls := false
+		for _, block := range blocks {
+			switch block.Type {
+			case "text":
+				text.WriteString(block.Text)
+			case "tool_use":
+				// Na última rodada as chamadas não são executadas, mesmo que o modelo ignore tool_choice
+				if round >= client.MaxToolRounds {
+					ignoredCalls = true
+					continue
+				}
+				c.logger.Debug("Ferramenta chamada pelo modelo", zap.String("tool", block.Name), zap.Int("round", round))
+				results = append(results, map[string]interface{}{
+					"type":        "tool_result",
+					"tool_use_id": block.ID,
+					"content":     execute(ctx, models.ToolCall{ID: block.ID, Name: block.Name, Arguments: block.Input}),
+				})
+			}
+		}
+
+		if len(results) == 0 {
+			if text.Len() == 0 && ignoredCalls {
+				return "", utils.CategorizeError(client.ErrToolRoundsExceeded)
+			}
+			if text.Len() == 0 {
+				return "", utils.CategorizeError(fmt.Errorf("resposta vazia da API"))
+			}
+			return text.String(), nil
+		}
+		messages = append(messages,
+			map[string]interface{}{"role": "assistant", "content": blocks},
+			map[string]interface{}{"role": "user", "content": results},
+		)
+	}
+}
+
+// parseClaudeBlocks extrai os blocos de conteúdo da resposta e o consumo de tokens.
+func parseClaudeBlocks(resp *http.Response, logger *zap.Logger) ([]claudeContentBlock, []byte, *models.Usage, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("erro ao ler resposta: %w", err)
+	}
+
+	var result struct {
+		Content []claudeContentBlock `json:"content"`
+		Usage   *claudeUsage         `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		logger.Debug("Resposta da Claude não pôde ser decodificada", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return nil, body, nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+	}
+
+	var usage *models.Usage
+	if result.Usage != nil {
+		usage = &models.Usage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+			TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
+		}
+	}
+	return result.Content, body, usage, nil
 }
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/webchatcomllm/config"
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/utils"
+)
+
+// ErrInputTooLarge indica uma entrada recusada localmente por exceder a janela de contexto.
+var ErrInputTooLarge = errors.New("entrada excede a janela de contexto do modelo")
+
+// inputSizeTolerance é a margem sobre o limite antes da recusa: a contagem de tokens é só uma
+// estimativa, e uma entrada perto do limite pode caber de fato.
+const inputSizeTolerance = 1.2
+
+// inputSizeCheck liga a verificação local de tamanho (INPUT_SIZE_CHECK).
+var inputSizeCheck = true
+
+// ConfigureInputSizeCheck carrega INPUT_SIZE_CHECK; false desliga a verificação e deixa o
+// provedor decidir sobre entradas grandes.
+func ConfigureInputSizeCheck() {
+	inputSizeCheck = config.GetEnvBool("INPUT_SIZE_CHECK", true)
+}
+
+// CheckInputSize estima os tokens do histórico e do prompt e, antes de qualquer chamada HTTP,
+// recusa a entrada que excede em mais de 20% o limite permitido: a janela de contexto do modelo
+// no catálogo menos os maxTokens reservados para a resposta. Modelos sem janela conhecida não
+// são verificados. O erro é da categoria client, para não passar pelo retry.
+func CheckInputSize(provider, model, prompt string, history []models.Message, maxTokens int) error {
+	window := catalog.GetContextWindow(provider, model)
+	if !inputSizeCheck || window <= 0 {
+		return nil
+	}
+
+	estimated := utils.EstimateTokens(prompt)
+	for _, msg := range history {
+		estimated += utils.EstimateTokens(msg.Content)
+	}
+
+	allowed := window - maxTokens
+	if allowed <= 0 {
+		allowed = window
+	}
+	if float64(estimated) <= float64(allowed)*inputSizeTolerance {
+		return nil
+	}
+	return &utils.CategorizedError{
+		Category: utils.ErrorCategoryClient,
+		Err: fmt.Errorf("%w: ~%d tokens estimados, limite de %d (%d do modelo %s menos %d reservados para a resposta)",
+			ErrInputTooLarge, estimated, allowed, window, model, maxTokens),
+	}
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"errors"
 
 	"github.com/webchatcomllm/models"
 )
@@ -11,3 +12,70 @@ type LLMClient interface {
 	SendPrompt(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, error)
 	GetModelName() string
 }
+
+// AttachmentClient é implementado pelos clientes que aceitam arquivos nativamente,
+// deixando a extração/OCR a cargo do provedor.
+type AttachmentClient interface {
+	SupportsAttachment(mediaType string) bool
+	SendPromptWithAttachments(ctx context.Context, prompt string, history []models.Message, maxTokens int, attachments []models.Attachment) (string, error)
+}
+
+// CitationClient é implementado pelos clientes cujos provedores retornam as fontes
+// usadas na resposta.
+type CitationClient interface {
+	SendPromptWithCitations(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, []models.Citation, error)
+}
+
+// StreamingClient é implementado pelos clientes capazes de entregar a resposta em trechos
+// à medida que o provedor a gera. onChunk recebe cada trecho; um erro retornado por ele
+// interrompe o streaming. O retorno é a resposta completa.
+type StreamingClient interface {
+	SendPromptStream(ctx context.Context, prompt string, history []models.Message, maxTokens int, onChunk func(chunk string) error) (string, error)
+}
+
+// UsageClient é implementado pelos clientes que registram o consumo de tokens da última
+// chamada. Os clientes são criados por requisição, então o valor pertence a ela; nil indica
+// que o provedor não informou o consumo.
+type UsageClient interface {
+	LastUsage() *models.Usage
+}
+
+// ImageClient é implementado pelos clientes que enviam imagens como partes multimodais da
+// mensagem do usuário, em vez de base64 no texto do prompt. As imagens definidas acompanham
+// as chamadas seguintes do cliente, com ou sem streaming.
+type ImageClient interface {
+	SetImages(images []models.Attachment)
+}
+
+// RawResponseClient é implementado pelos clientes que guardam o corpo bruto da última
+// resposta sem streaming, exposto para depurar o parsing das respostas do provedor.
+type RawResponseClient interface {
+	LastRawResponse() []byte
+}
+
+// ParamsClient é implementado pelos clientes que aceitam parâmetros específicos do provedor
+// (temperature, top_p...) repassados pela requisição. Apenas as chaves permitidas no catálogo
+// chegam ao corpo enviado à API.
+type ParamsClient interface {
+	SetProviderParams(params map[string]interface{})
+}
+
+// MaxToolRounds limita as rodadas de chamadas de ferramentas de uma resposta; na última, o
+// modelo é obrigado a responder em texto.
+const MaxToolRounds = 5
+
+// ErrToolRoundsExceeded indica que o modelo pediu ferramentas na última rodada, mesmo obrigado a
+// responder em texto, e não devolveu texto; as chamadas não são executadas.
+var ErrToolRoundsExceeded = errors.New("o modelo continuou chamando ferramentas após o limite de rodadas")
+
+// ToolExecutor executa uma chamada de ferramenta e retorna o resultado enviado ao modelo.
+// Erros de execução devem ser descritos no próprio resultado, para que o modelo possa corrigir
+// os argumentos.
+type ToolExecutor func(ctx context.Context, call models.ToolCall) string
+
+// ToolClient é implementado pelos clientes cujos provedores aceitam ferramentas (function
+// calling). As chamadas pedidas pelo modelo são executadas por execute e os resultados
+// devolvidos a ele, até que responda sem chamar ferramentas ou atinja MaxToolRounds.
+type ToolClient interface {
+	SendPromptWithTools(ctx context.Context, prompt string, history []models.Message, maxTokens int, tools []models.Tool, execute ToolExecutor) (string, error)
+}
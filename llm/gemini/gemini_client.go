@@ -0,0 +1,233 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/webchatcomllm/config"
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// maxLoggedBody limita o corpo registrado em log quando a resposta não pode ser interpretada.
+const maxLoggedBody = 4000
+
+type Client struct {
+	apiKey      string
+	model       string
+	logger      *zap.Logger
+	httpClient  *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	headers     http.Header
+	usage       *models.Usage
+	params      map[string]interface{}
+	rawResponse []byte
+	images      []models.Attachment
+}
+
+func NewClient(apiKey, model string, logger *zap.Logger, maxAttempts int, backoff time.Duration) *Client {
+	return &Client{
+		apiKey:      apiKey,
+		model:       model,
+		logger:      logger,
+		httpClient:  utils.NewHTTPClient(logger, 90*time.Second),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+func (c *Client) GetModelName() string {
+	return c.model
+}
+
+// SetProviderParams define os parâmetros da requisição mesclados a generationConfig.
+func (c *Client) SetProviderParams(params map[string]interface{}) {
+	c.params = params
+}
+
+// SetImages define as imagens enviadas como partes "inlineData" da mensagem do usuário.
+func (c *Client) SetImages(images []models.Attachment) {
+	c.images = images
+}
+
+// WithExtraHeaders define cabeçalhos adicionais enviados em todas as requisições ao provedor.
+func (c *Client) WithExtraHeaders(headers http.Header) *Client {
+	c.headers = headers
+	return c
+}
+
+// LastUsage retorna o consumo de tokens da última chamada, quando informado pela API.
+func (c *Client) LastUsage() *models.Usage {
+	return c.usage
+}
+
+// LastRawResponse retorna o corpo bruto da última resposta.
+func (c *Client) LastRawResponse() []byte {
+	return c.rawResponse
+}
+
+// SendPrompt envia o prompt ao endpoint generateContent do modelo.
+func (c *Client) SendPrompt(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, error) {
+	if maxTokens <= 0 {
+		maxTokens = catalog.GetMaxTokens(catalog.ProviderGemini, c.model)
+	}
+	if err := client.CheckInputSize(catalog.ProviderGemini, c.model, prompt, history, maxTokens); err != nil {
+		return "", err
+	}
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderGemini)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	system, contents := buildContents(prompt, history, c.images)
+	generationConfig := map[string]interface{}{
+		"maxOutputTokens": maxTokens,
+	}
+	catalog.MergeProviderParams(catalog.ProviderGemini, generationConfig, c.params)
+
+	reqBody := map[string]interface{}{
+		"contents":         contents,
+		"generationConfig": generationConfig,
+	}
+	if system != "" {
+		reqBody["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]string{{"text": system}},
+		}
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("erro ao serializar request: %w", err)
+	}
+
+	responseText, err := utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (string, error) {
+		resp, err := c.do(ctx, jsonData)
+		if err != nil {
+			return "", err
+		}
+		text, body, usage, err := parseGeminiResponse(resp, c.logger)
+		c.rawResponse = body
+		c.usage = usage
+		return text, err
+	})
+
+	return responseText, utils.CategorizeError(err)
+}
+
+// do envia o payload serializado ao endpoint generateContent do modelo.
+func (c *Client) do(ctx context.Context, payload []byte) (*http.Response, error) {
+	url := fmt.Sprintf("%s/%s:generateContent", config.GeminiAPIBaseURL, c.model)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, utils.NewJSONReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", c.apiKey)
+	utils.ApplyHeaders(req, c.headers)
+	return c.httpClient.Do(req)
+}
+
+// buildContents converte o histórico para contents, com os papéis "user" e "model". Mensagens
+// com papel "system" ou "developer" são retornadas separadamente para systemInstruction.
+func buildContents(prompt string, history []models.Message, images []models.Attachment) (string, []map[string]interface{}) {
+	var systemParts []string
+	var contents []map[string]interface{}
+	for _, msg := range history {
+		if msg.IsInstruction() {
+			systemParts = append(systemParts, msg.Content)
+			continue
+		}
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, map[string]interface{}{
+			"role":  role,
+			"parts": []map[string]interface{}{{"text": msg.Content}},
+		})
+	}
+
+	var parts []map[string]interface{}
+	for _, img := range images {
+		parts = append(parts, map[string]interface{}{
+			"inlineData": map[string]string{"mimeType": img.MediaType, "data": img.Data},
+		})
+	}
+	parts = append(parts, map[string]interface{}{"text": prompt})
+	contents = append(contents, map[string]interface{}{"role": "user", "parts": parts})
+	return strings.Join(systemParts, "\n\n"), contents
+}
+
+// parseGeminiResponse extrai o texto do primeiro candidato e o consumo de tokens.
+func parseGeminiResponse(resp *http.Response, logger *zap.Logger) (string, []byte, *models.Usage, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("erro ao ler resposta: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", body, nil, utils.NewAPIError(catalog.ProviderGemini, resp.StatusCode, body).WithRetryAfter(resp.Header)
+	}
+
+	var result struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+		UsageMetadata *struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			TotalTokenCount      int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		logger.Debug("Resposta do Gemini não pôde ser decodificada", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return "", body, nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+	}
+
+	var usage *models.Usage
+	if result.UsageMetadata != nil {
+		usage = &models.Usage{
+			PromptTokens:     result.UsageMetadata.PromptTokenCount,
+			CompletionTokens: result.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      result.UsageMetadata.TotalTokenCount,
+		}
+	}
+
+	if result.PromptFeedback.BlockReason != "" {
+		return "", body, usage, fmt.Errorf("prompt bloqueado pelo Gemini: %s", result.PromptFeedback.BlockReason)
+	}
+	if len(result.Candidates) == 0 {
+		logger.Debug("Resposta do Gemini sem candidatos", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return "", body, usage, fmt.Errorf("nenhuma resposta recebida do Gemini")
+	}
+
+	var responseText strings.Builder
+	for _, part := range result.Candidates[0].Content.Parts {
+		responseText.WriteString(part.Text)
+	}
+	if responseText.Len() == 0 {
+		return "", body, usage, fmt.Errorf("resposta vazia do Gemini (finishReason: %s)", result.Candidates[0].FinishReason)
+	}
+
+	return responseText.String(), body, usage, nil
+}
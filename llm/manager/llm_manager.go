@@ -1,8 +1,11 @@
 package manager
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -10,25 +13,65 @@ import (
 	"github.com/webchatcomllm/llm/catalog"
 	"github.com/webchatcomllm/llm/claude"
 	"github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/llm/gemini"
+	"github.com/webchatcomllm/llm/ollama"
 	"github.com/webchatcomllm/llm/openai"
 	"github.com/webchatcomllm/llm/stackspot"
 	"github.com/webchatcomllm/llm/token"
+	"github.com/webchatcomllm/utils"
 	"go.uber.org/zap"
 )
 
 type LLMManager interface {
 	GetClient(provider string, model string) (client.LLMClient, error)
+	// ConfiguredProviders retorna, em ordem alfabética, os provedores com credenciais configuradas
+	ConfiguredProviders() []string
+}
+
+// HealthReporter é implementado pelo gerenciador que acompanha o circuit breaker de cada provedor.
+type HealthReporter interface {
+	ProviderHealth() map[string]utils.CircuitState
 }
 
 type llmManagerImpl struct {
 	factories map[string]func(string) (client.LLMClient, error)
-	logger    *zap.Logger
+	// breakers são os circuit breakers dos provedores configurados (ver utils.ProviderBreaker)
+	breakers     map[string]*utils.CircuitBreaker
+	extraHeaders map[string]http.Header
+	// warmers aquecem cada provedor configurado (ver Warmup)
+	warmers map[string]func(context.Context) error
+	logger  *zap.Logger
+}
+
+// extraHeadersEnv mapeia cada provedor à variável com seus cabeçalhos adicionais.
+var extraHeadersEnv = map[string]string{
+	catalog.ProviderStackSpot: "STACKSPOT_EXTRA_HEADERS",
+	catalog.ProviderOpenAI:    "OPENAI_EXTRA_HEADERS",
+	catalog.ProviderClaude:    "CLAUDE_EXTRA_HEADERS",
+	catalog.ProviderGemini:    "GEMINI_EXTRA_HEADERS",
+	catalog.ProviderOllama:    "OLLAMA_EXTRA_HEADERS",
+}
+
+// attributionHeadersEnv mapeia os cabeçalhos de atribuição de custos de cada provedor
+// às variáveis de ambiente que os definem.
+var attributionHeadersEnv = map[string]map[string]string{
+	catalog.ProviderOpenAI: {
+		"OpenAI-Organization": "OPENAI_ORG_ID",
+		"OpenAI-Project":      "OPENAI_PROJECT_ID",
+	},
 }
 
 func NewLLMManager(logger *zap.Logger) (LLMManager, error) {
 	manager := &llmManagerImpl{
-		factories: make(map[string]func(string) (client.LLMClient, error)),
-		logger:    logger,
+		factories:    make(map[string]func(string) (client.LLMClient, error)),
+		breakers:     make(map[string]*utils.CircuitBreaker),
+		extraHeaders: make(map[string]http.Header),
+		warmers:      make(map[string]func(context.Context) error),
+		logger:       logger,
+	}
+
+	if err := manager.loadExtraHeaders(); err != nil {
+		return nil, err
 	}
 
 	maxRetries := config.DefaultMaxRetries
@@ -37,19 +80,24 @@ func NewLLMManager(logger *zap.Logger) (LLMManager, error) {
 	manager.configureStackSpot(maxRetries, backoff)
 	manager.configureOpenAI(maxRetries, backoff)
 	manager.configureClaude(maxRetries, backoff)
+	manager.configureGemini(maxRetries, backoff)
+	manager.configureOllama(maxRetries, backoff)
 
 	if len(manager.factories) == 0 {
 		return nil, fmt.Errorf("nenhum provedor de LLM foi configurado. Verifique seu arquivo .env")
 	}
+	for provider := range manager.factories {
+		manager.breakers[provider] = utils.ProviderBreaker(provider)
+	}
 
 	return manager, nil
 }
 
 func (m *llmManagerImpl) GetClient(provider, model string) (client.LLMClient, error) {
-	p := strings.ToUpper(provider)
-	if p == "GPT-5" {
-		p = catalog.ProviderStackSpot
-	}
+	// Aliases como "GPT-5" valem apenas para o campo provider; aliases de modelo
+	// ("claude-latest") são resolvidos dentro do provedor já normalizado
+	p := catalog.ResolveProvider(provider)
+	model = catalog.ResolveModel(p, model)
 
 	// CORREÇÃO: Log detalhado
 	m.logger.Debug("GetClient chamado",
@@ -60,11 +108,7 @@ func (m *llmManagerImpl) GetClient(provider, model string) (client.LLMClient, er
 
 	factory, ok := m.factories[p]
 	if !ok {
-		// Lista provedores disponíveis
-		available := make([]string, 0, len(m.factories))
-		for key := range m.factories {
-			available = append(available, key)
-		}
+		available := m.ConfiguredProviders()
 
 		m.logger.Error("Provedor não encontrado",
 			zap.String("provider_solicitado", provider),
@@ -74,9 +118,75 @@ func (m *llmManagerImpl) GetClient(provider, model string) (client.LLMClient, er
 
 		return nil, fmt.Errorf("provedor LLM '%s' não é suportado ou não está configurado. Provedores disponíveis: %v", provider, available)
 	}
+	if !m.breakers[p].Allow() {
+		m.logger.Warn("Circuito do provedor aberto, requisição recusada", zap.String("provider", p))
+		return nil, &utils.CategorizedError{Category: utils.ErrorCategoryServer, Err: circuitOpenError{provider: p}}
+	}
 	return factory(model)
 }
 
+func (m *llmManagerImpl) ConfiguredProviders() []string {
+	providers := make([]string, 0, len(m.factories))
+	for key := range m.factories {
+		providers = append(providers, key)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// circuitOpenError é retornado por GetClient enquanto o circuito do provedor está aberto.
+type circuitOpenError struct {
+	provider string
+}
+
+func (e circuitOpenError) Error() string {
+	return fmt.Sprintf("O provedor %s está instável e foi desativado temporariamente após falhas seguidas. "+
+		"Tente novamente em instantes ou escolha outro provedor.", e.provider)
+}
+
+func (e circuitOpenError) Unwrap() error {
+	return utils.ErrCircuitOpen
+}
+
+// ProviderHealth retorna o estado do circuit breaker de cada provedor configurado.
+func (m *llmManagerImpl) ProviderHealth() map[string]utils.CircuitState {
+	health := make(map[string]utils.CircuitState, len(m.breakers))
+	for provider, cb := range m.breakers {
+		health[provider] = cb.GetState()
+	}
+	return health
+}
+
+// loadExtraHeaders lê e valida os cabeçalhos adicionais de cada provedor.
+// Apenas os nomes são logados, pois os valores podem ser sensíveis.
+func (m *llmManagerImpl) loadExtraHeaders() error {
+	for provider, envVar := range extraHeadersEnv {
+		headers, err := utils.ParseHeaderList(os.Getenv(envVar))
+		if err != nil {
+			return fmt.Errorf("configuração inválida em %s: %w", envVar, err)
+		}
+		for name, attrEnv := range attributionHeadersEnv[provider] {
+			value := strings.TrimSpace(os.Getenv(attrEnv))
+			if value == "" {
+				continue
+			}
+			if strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("configuração inválida em %s: valor contém quebra de linha", attrEnv)
+			}
+			headers.Set(name, value)
+		}
+		if len(headers) == 0 {
+			continue
+		}
+		m.extraHeaders[provider] = headers
+		m.logger.Info("Cabeçalhos adicionais configurados",
+			zap.String("provider", provider),
+			zap.Strings("headers", utils.HeaderNames(headers)),
+		)
+	}
+	return nil
+}
+
 func (m *llmManagerImpl) configureStackSpot(maxRetries int, backoff time.Duration) {
 	clientID := os.Getenv("CLIENT_ID")
 	clientKey := os.Getenv("CLIENT_KEY")
@@ -86,7 +196,15 @@ func (m *llmManagerImpl) configureStackSpot(maxRetries int, backoff time.Duratio
 	if clientID != "" && clientKey != "" && realm != "" && agentID != "" {
 		tokenManager := token.NewTokenManager(clientID, clientKey, realm, m.logger)
 		m.factories[catalog.ProviderStackSpot] = func(model string) (client.LLMClient, error) {
-			return stackspot.NewClient(tokenManager, agentID, m.logger, maxRetries, backoff), nil
+			return stackspot.NewClient(tokenManager, agentID, m.logger, maxRetries, backoff).
+				WithExtraHeaders(m.extraHeaders[catalog.ProviderStackSpot]), nil
+		}
+		warmAPI := m.warmURLs(config.StackSpotBaseURL)
+		m.warmers[catalog.ProviderStackSpot] = func(ctx context.Context) error {
+			if _, err := tokenManager.GetAccessToken(ctx); err != nil {
+				return fmt.Errorf("erro ao obter token: %w", err)
+			}
+			return warmAPI(ctx)
 		}
 		m.logger.Info("Provedor StackSpot (GPT-5) configurado.")
 	} else {
@@ -98,8 +216,17 @@ func (m *llmManagerImpl) configureOpenAI(maxRetries int, backoff time.Duration)
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey != "" {
 		m.factories[catalog.ProviderOpenAI] = func(model string) (client.LLMClient, error) {
-			return openai.NewClient(apiKey, config.OpenAIDefaultModel, m.logger, maxRetries, backoff), nil
+			// Aceita os modelos do catálogo, inclusive os declarados em MODELS_CONFIG_PATH
+			if _, ok := catalog.Resolve(catalog.ProviderOpenAI, model); !ok {
+				if model != "" {
+					m.logger.Warn("Modelo OpenAI não suportado, usando o modelo padrão", zap.String("solicitado", model))
+				}
+				model = config.OpenAIDefaultModel
+			}
+			return openai.NewClient(apiKey, model, m.logger, maxRetries, backoff).
+				WithExtraHeaders(m.extraHeaders[catalog.ProviderOpenAI]), nil
 		}
+		m.warmers[catalog.ProviderOpenAI] = m.warmURLs(config.OpenAIAPIURL)
 		m.logger.Info("Provedor OpenAI configurado.")
 	} else {
 		m.logger.Warn("Provedor OpenAI não configurado. OPENAI_API_KEY não definida.")
@@ -110,14 +237,66 @@ func (m *llmManagerImpl) configureClaude(maxRetries int, backoff time.Duration)
 	apiKey := os.Getenv("CLAUDEAI_API_KEY")
 	if apiKey != "" {
 		m.factories[catalog.ProviderClaude] = func(model string) (client.LLMClient, error) {
-			if model != config.ClaudeSonnet4 && model != config.ClaudeSonnet45 {
-				m.logger.Warn("Modelo Claude não suportado, usando Sonnet 4.5 como padrão", zap.String("solicitado", model))
+			// Aceita os modelos do catálogo, inclusive os declarados em MODELS_CONFIG_PATH
+			if _, ok := catalog.Resolve(catalog.ProviderClaude, model); !ok {
+				if model != "" {
+					m.logger.Warn("Modelo Claude não suportado, usando Sonnet 4.5 como padrão", zap.String("solicitado", model))
+				}
 				model = config.ClaudeSonnet45
 			}
-			return claude.NewClient(apiKey, model, m.logger, maxRetries, backoff), nil
+			return claude.NewClient(apiKey, model, m.logger, maxRetries, backoff).
+				WithExtraHeaders(m.extraHeaders[catalog.ProviderClaude]), nil
 		}
+		m.warmers[catalog.ProviderClaude] = m.warmURLs(config.ClaudeAPIURL)
 		m.logger.Info("Provedor Claude configurado.")
 	} else {
 		m.logger.Warn("Provedor Claude não configurado. CLAUDEAI_API_KEY não definida.")
 	}
 }
+
+func (m *llmManagerImpl) configureGemini(maxRetries int, backoff time.Duration) {
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey != "" {
+		m.factories[catalog.ProviderGemini] = func(model string) (client.LLMClient, error) {
+			if _, ok := catalog.Resolve(catalog.ProviderGemini, model); !ok {
+				if model != "" {
+					m.logger.Warn("Modelo Gemini não suportado, usando o modelo padrão", zap.String("solicitado", model))
+				}
+				model = config.GeminiDefaultModel
+			}
+			return gemini.NewClient(apiKey, model, m.logger, maxRetries, backoff).
+				WithExtraHeaders(m.extraHeaders[catalog.ProviderGemini]), nil
+		}
+		m.warmers[catalog.ProviderGemini] = m.warmURLs(config.GeminiAPIBaseURL)
+		m.logger.Info("Provedor Gemini configurado.")
+	} else {
+		m.logger.Warn("Provedor Gemini não configurado. GEMINI_API_KEY não definida.")
+	}
+}
+
+// configureOllama registra o servidor Ollama local apenas quando OLLAMA_BASE_URL está
+// definida. O modelo da requisição é repassado como está, já que o servidor aceita qualquer
+// modelo instalado; sem modelo, usa OLLAMA_MODEL.
+func (m *llmManagerImpl) configureOllama(maxRetries int, backoff time.Duration) {
+	baseURL := os.Getenv("OLLAMA_BASE_URL")
+	if baseURL == "" {
+		m.logger.Info("Provedor Ollama não configurado. OLLAMA_BASE_URL não definida.")
+		return
+	}
+	defaultModel := config.GetEnvString("OLLAMA_MODEL", config.OllamaDefaultModel)
+	timeout := config.GetEnvDuration("OLLAMA_TIMEOUT", config.OllamaDefaultTimeout)
+
+	m.factories[catalog.ProviderOllama] = func(model string) (client.LLMClient, error) {
+		if model == "" {
+			model = defaultModel
+		}
+		return ollama.NewClient(baseURL, model, timeout, m.logger, maxRetries, backoff).
+			WithExtraHeaders(m.extraHeaders[catalog.ProviderOllama]), nil
+	}
+	m.warmers[catalog.ProviderOllama] = m.warmURLs(baseURL)
+	m.logger.Info("Provedor Ollama configurado.",
+		zap.String("base_url", baseURL),
+		zap.String("model", defaultModel),
+		zap.Duration("timeout", timeout),
+	)
+}
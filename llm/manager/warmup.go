@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// Warmer é implementado pelo gerenciador que sabe aquecer os provedores configurados.
+type Warmer interface {
+	Warmup(ctx context.Context)
+}
+
+// Warmup executa, em paralelo, o aquecimento de cada provedor configurado (WARMUP_ON_START):
+// obtém tokens antecipadamente e abre as conexões TLS que ficam no pool de keep-alive do
+// transporte compartilhado. Falhas são apenas registradas em log.
+func (m *llmManagerImpl) Warmup(ctx context.Context) {
+	providers := make([]string, 0, len(m.warmers))
+	for provider := range m.warmers {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	var wg sync.WaitGroup
+	for _, provider := range providers {
+		provider, warm := provider, m.warmers[provider]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			if err := warm(ctx); err != nil {
+				m.logger.Warn("Aquecimento do provedor falhou",
+					zap.String("provider", provider),
+					zap.Duration("duration", time.Since(start)),
+					zap.Error(err))
+				return
+			}
+			m.logger.Info("Provedor aquecido",
+				zap.String("provider", provider),
+				zap.Duration("duration", time.Since(start)))
+		}()
+	}
+	wg.Wait()
+}
+
+// warmConnection faz uma requisição HEAD ao endereço para abrir a conexão TLS. Qualquer
+// resposta HTTP conta como sucesso; o corpo é descartado para a conexão voltar ao pool.
+func warmConnection(ctx context.Context, httpClient *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao criar requisição: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// warmURLs devolve um aquecedor que abre a conexão com cada endereço.
+func (m *llmManagerImpl) warmURLs(urls ...string) func(context.Context) error {
+	httpClient := utils.NewHTTPClient(m.logger, 10*time.Second)
+	return func(ctx context.Context) error {
+		for _, url := range urls {
+			if err := warmConnection(ctx, httpClient, url); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,197 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/llm/client"
+	"github.com/webchatcomllm/models"
+	"github.com/webchatcomllm/utils"
+	"go.uber.org/zap"
+)
+
+// maxLoggedBody limita o corpo registrado em log quando a resposta não pode ser interpretada.
+const maxLoggedBody = 4000
+
+// Client conversa com um servidor Ollama local pelo endpoint /api/chat.
+type Client struct {
+	baseURL     string
+	model       string
+	logger      *zap.Logger
+	httpClient  *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	headers     http.Header
+	usage       *models.Usage
+	params      map[string]interface{}
+	images      []models.Attachment
+	rawResponse []byte
+}
+
+// NewClient cria o cliente. timeout é separado do padrão dos provedores em nuvem, já que
+// modelos locais podem levar bem mais tempo para responder.
+func NewClient(baseURL, model string, timeout time.Duration, logger *zap.Logger, maxAttempts int, backoff time.Duration) *Client {
+	return &Client{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		model:       model,
+		logger:      logger,
+		httpClient:  utils.NewHTTPClient(logger, timeout),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+func (c *Client) GetModelName() string {
+	return c.model
+}
+
+// SetProviderParams define os parâmetros da requisição mesclados a "options".
+func (c *Client) SetProviderParams(params map[string]interface{}) {
+	c.params = params
+}
+
+// SetImages define as imagens enviadas no campo "images" da mensagem do usuário, lido pelos
+// modelos com visão (ex.: llava). Modelos sem visão no catálogo não recebem imagens.
+func (c *Client) SetImages(images []models.Attachment) {
+	c.images = images
+}
+
+// WithExtraHeaders define cabeçalhos adicionais enviados em todas as requisições ao servidor.
+func (c *Client) WithExtraHeaders(headers http.Header) *Client {
+	c.headers = headers
+	return c
+}
+
+// LastUsage retorna o consumo de tokens da última chamada, quando informado pelo servidor.
+func (c *Client) LastUsage() *models.Usage {
+	return c.usage
+}
+
+// LastRawResponse retorna o corpo bruto da última resposta.
+func (c *Client) LastRawResponse() []byte {
+	return c.rawResponse
+}
+
+// SendPrompt envia o histórico convertido e o prompt ao endpoint /api/chat, sem streaming.
+func (c *Client) SendPrompt(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, error) {
+	if maxTokens <= 0 {
+		maxTokens = catalog.GetMaxTokens(catalog.ProviderOllama, c.model)
+	}
+	if err := client.CheckInputSize(catalog.ProviderOllama, c.model, prompt, history, maxTokens); err != nil {
+		return "", err
+	}
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderOllama)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	options := map[string]interface{}{
+		"num_predict": maxTokens,
+	}
+	catalog.MergeProviderParams(catalog.ProviderOllama, options, c.params)
+
+	reqBody := map[string]interface{}{
+		"model":    c.model,
+		"messages": buildMessages(prompt, history, c.images),
+		"stream":   false,
+		"options":  options,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("erro ao serializar request: %w", err)
+	}
+
+	responseText, err := utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", utils.NewJSONReader(jsonData))
+		if err != nil {
+			return "", fmt.Errorf("erro ao criar requisição: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		utils.ApplyHeaders(req, c.headers)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		text, body, usage, err := parseOllamaResponse(resp, c.logger)
+		c.rawResponse = body
+		c.usage = usage
+		return text, err
+	})
+
+	return responseText, utils.CategorizeError(err)
+}
+
+// buildMessages converte o histórico para o formato de mensagens do Ollama, que aceita os
+// papéis "system", "user" e "assistant" diretamente; "developer" é enviado como "system". As
+// imagens seguem em base64, sem o prefixo data URI, no campo "images" da mensagem do usuário.
+func buildMessages(prompt string, history []models.Message, images []models.Attachment) []map[string]interface{} {
+	messages := make([]map[string]interface{}, 0, len(history)+1)
+	for _, msg := range history {
+		role := msg.Role
+		if role == models.RoleDeveloper {
+			role = models.RoleSystem
+		}
+		messages = append(messages, map[string]interface{}{"role": role, "content": msg.Content})
+	}
+	user := map[string]interface{}{"role": "user", "content": prompt}
+	if len(images) > 0 {
+		data := make([]string, len(images))
+		for i, img := range images {
+			data[i] = img.Data
+		}
+		user["images"] = data
+	}
+	return append(messages, user)
+}
+
+// parseOllamaResponse extrai o texto da mensagem e o consumo de tokens.
+func parseOllamaResponse(resp *http.Response, logger *zap.Logger) (string, []byte, *models.Usage, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("erro ao ler resposta: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", body, nil, utils.NewAPIError(catalog.ProviderOllama, resp.StatusCode, body).WithRetryAfter(resp.Header)
+	}
+
+	var result struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		DoneReason      string `json:"done_reason"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		logger.Debug("Resposta do Ollama não pôde ser decodificada", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return "", body, nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+	}
+
+	var usage *models.Usage
+	if result.PromptEvalCount > 0 || result.EvalCount > 0 {
+		usage = &models.Usage{
+			PromptTokens:     result.PromptEvalCount,
+			CompletionTokens: result.EvalCount,
+			TotalTokens:      result.PromptEvalCount + result.EvalCount,
+		}
+	}
+
+	if result.Message.Content == "" {
+		logger.Debug("Resposta do Ollama sem conteúdo", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return "", body, usage, fmt.Errorf("resposta vazia do Ollama (done_reason: %s)", result.DoneReason)
+	}
+
+	return result.Message.Content, body, usage, nil
+}
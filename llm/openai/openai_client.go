@@ -6,32 +6,45 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/webchatcomllm/config"
 	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/llm/client"
 	"github.com/webchatcomllm/models"
 	"github.com/webchatcomllm/utils"
 	"go.uber.org/zap"
 )
 
+// maxLoggedBody limita o corpo registrado em log quando a resposta não pode ser interpretada.
+const maxLoggedBody = 4000
+
 type Client struct {
-	apiKey      string
-	model       string
-	logger      *zap.Logger
-	httpClient  *http.Client
-	maxAttempts int
-	backoff     time.Duration
+	apiKey     string
+	model      string
+	logger     *zap.Logger
+	httpClient *http.Client
+	// streamClient não tem timeout total: a duração do streaming é limitada pelo contexto
+	streamClient *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	headers      http.Header
+	usage        *models.Usage
+	params       map[string]interface{}
+	rawResponse  []byte
+	images       []models.Attachment
 }
 
 func NewClient(apiKey, model string, logger *zap.Logger, maxAttempts int, backoff time.Duration) *Client {
 	return &Client{
-		apiKey:      apiKey,
-		model:       model,
-		logger:      logger,
-		httpClient:  utils.NewHTTPClient(logger, 90*time.Second),
-		maxAttempts: maxAttempts,
-		backoff:     backoff,
+		apiKey:       apiKey,
+		model:        model,
+		logger:       logger,
+		httpClient:   utils.NewHTTPClient(logger, 90*time.Second),
+		streamClient: utils.NewHTTPClient(logger, 0),
+		maxAttempts:  maxAttempts,
+		backoff:      backoff,
 	}
 }
 
@@ -39,21 +52,50 @@ func (c *Client) GetModelName() string {
 	return c.model
 }
 
+// SetProviderParams define os parâmetros da requisição mesclados ao corpo enviado à API.
+func (c *Client) SetProviderParams(params map[string]interface{}) {
+	c.params = params
+}
+
+// SetImages define as imagens enviadas como partes "image_url" da mensagem do usuário.
+func (c *Client) SetImages(images []models.Attachment) {
+	c.images = images
+}
+
+// WithExtraHeaders define cabeçalhos adicionais enviados em todas as requisições ao provedor.
+func (c *Client) WithExtraHeaders(headers http.Header) *Client {
+	c.headers = headers
+	return c
+}
+
 func (c *Client) SendPrompt(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, error) {
+	return c.SendPromptWithAttachments(ctx, prompt, history, maxTokens, nil)
+}
+
+// SupportsAttachment indica os tipos aceitos como entrada de arquivo nativa.
+func (c *Client) SupportsAttachment(mediaType string) bool {
+	return mediaType == "application/pdf"
+}
+
+// SendPromptWithAttachments envia o prompt com arquivos anexados como partes "file" da mensagem.
+func (c *Client) SendPromptWithAttachments(ctx context.Context, prompt string, history []models.Message, maxTokens int, attachments []models.Attachment) (string, error) {
 	if maxTokens <= 0 {
 		maxTokens = catalog.GetMaxTokens(catalog.ProviderOpenAI, c.model)
 	}
-
-	var messages []map[string]string
-	for _, msg := range history {
-		messages = append(messages, map[string]string{"role": msg.Role, "content": msg.Content})
+	if err := client.CheckInputSize(catalog.ProviderOpenAI, c.model, prompt, history, maxTokens); err != nil {
+		return "", err
 	}
-	messages = append(messages, map[string]string{"role": "user", "content": prompt})
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderOpenAI)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 
 	payload := map[string]interface{}{
 		"model":    c.model,
-		"messages": messages,
+		"messages": buildMessages(c.model, prompt, history, append(append([]models.Attachment(nil), c.images...), attachments...)),
 	}
+	catalog.MergeProviderParams(catalog.ProviderOpenAI, payload, c.params)
 
 	jsonValue, err := json.Marshal(payload)
 	if err != nil {
@@ -61,32 +103,189 @@ func (c *Client) SendPrompt(ctx context.Context, prompt string, history []models
 	}
 
 	responseText, err := utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (string, error) {
-		req, err := http.NewRequestWithContext(ctx, "POST", config.OpenAIAPIURL, utils.NewJSONReader(jsonValue))
+		resp, err := c.do(ctx, c.httpClient, jsonValue)
 		if err != nil {
-			return "", fmt.Errorf("erro ao criar requisição: %w", err)
+			return "", err
 		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		text, body, usage, err := parseOpenAIResponse(resp, c.logger)
+		c.rawResponse = body
+		c.usage = usage
+		return text, err
+	})
 
-		resp, err := c.httpClient.Do(req)
+	return responseText, utils.CategorizeError(err)
+}
+
+// LastRawResponse retorna o corpo bruto da última resposta sem streaming.
+func (c *Client) LastRawResponse() []byte {
+	return c.rawResponse
+}
+
+// LastUsage retorna o consumo de tokens da última chamada, quando informado pela API.
+func (c *Client) LastUsage() *models.Usage {
+	return c.usage
+}
+
+// SendPromptStream envia o prompt com "stream": true e repassa cada trecho gerado a onChunk.
+// Com stream_options.include_usage, o último evento traz o consumo de tokens da resposta.
+// Apenas a abertura da conexão passa pelo retry: depois do primeiro trecho, repetir a
+// chamada duplicaria o texto já entregue.
+func (c *Client) SendPromptStream(ctx context.Context, prompt string, history []models.Message, maxTokens int, onChunk func(chunk string) error) (string, error) {
+	if maxTokens <= 0 {
+		maxTokens = catalog.GetMaxTokens(catalog.ProviderOpenAI, c.model)
+	}
+	if err := client.CheckInputSize(catalog.ProviderOpenAI, c.model, prompt, history, maxTokens); err != nil {
+		return "", err
+	}
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderOpenAI)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	payload := map[string]interface{}{
+		"model":          c.model,
+		"messages":       buildMessages(c.model, prompt, history, c.images),
+		"stream":         true,
+		"stream_options": map[string]bool{"include_usage": true},
+	}
+	catalog.MergeProviderParams(catalog.ProviderOpenAI, payload, c.params)
+
+	jsonValue, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("erro ao serializar payload: %w", err)
+	}
+
+	resp, err := utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (*http.Response, error) {
+		resp, err := c.do(ctx, c.streamClient, jsonValue)
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			return nil, utils.NewAPIError(catalog.ProviderOpenAI, resp.StatusCode, body).WithRetryAfter(resp.Header)
 		}
-		return parseOpenAIResponse(resp)
+		return resp, nil
 	})
+	if err != nil {
+		return "", utils.CategorizeError(err)
+	}
+	defer resp.Body.Close()
 
-	return responseText, err
+	var full strings.Builder
+	err = utils.ReadSSE(resp.Body, func(_, data string) error {
+		if data == "[DONE]" {
+			return io.EOF
+		}
+
+		var event struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+				TotalTokens      int `json:"total_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("erro ao decodificar evento do stream: %w", err)
+		}
+
+		if event.Usage != nil {
+			c.usage = &models.Usage{
+				PromptTokens:     event.Usage.PromptTokens,
+				CompletionTokens: event.Usage.CompletionTokens,
+				TotalTokens:      event.Usage.TotalTokens,
+			}
+		}
+		if len(event.Choices) == 0 || event.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		chunk := event.Choices[0].Delta.Content
+		full.WriteString(chunk)
+		return onChunk(chunk)
+	})
+	if err != nil {
+		return full.String(), utils.CategorizeError(err)
+	}
+
+	if c.usage != nil {
+		c.logger.Debug("Consumo de tokens do stream",
+			zap.Int("prompt_tokens", c.usage.PromptTokens),
+			zap.Int("completion_tokens", c.usage.CompletionTokens),
+		)
+	}
+	return full.String(), nil
+}
+
+// do envia o payload serializado ao endpoint de chat completions.
+func (c *Client) do(ctx context.Context, httpClient *http.Client, payload []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", config.OpenAIAPIURL, utils.NewJSONReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	utils.ApplyHeaders(req, c.headers)
+	return httpClient.Do(req)
+}
+
+// buildMessages converte o histórico e o prompt para o formato de mensagens da API. Mensagens
+// "developer" são enviadas como "system" aos modelos que não aceitam esse papel.
+func buildMessages(model, prompt string, history []models.Message, attachments []models.Attachment) []map[string]interface{} {
+	var messages []map[string]interface{}
+	developer := catalog.SupportsDeveloperRole(catalog.ProviderOpenAI, model)
+	for _, msg := range history {
+		role := msg.Role
+		if role == models.RoleDeveloper && !developer {
+			role = models.RoleSystem
+		}
+		messages = append(messages, map[string]interface{}{"role": role, "content": msg.Content})
+	}
+	return append(messages, map[string]interface{}{"role": "user", "content": buildUserContent(prompt, attachments)})
 }
 
-func parseOpenAIResponse(resp *http.Response) (string, error) {
+// buildUserContent retorna o texto puro ou, havendo anexos, a lista de partes multimodais:
+// imagens como "image_url" e demais arquivos como "file".
+func buildUserContent(prompt string, attachments []models.Attachment) interface{} {
+	if len(attachments) == 0 {
+		return prompt
+	}
+
+	parts := []map[string]interface{}{
+		{"type": "text", "text": prompt},
+	}
+	for _, att := range attachments {
+		if strings.HasPrefix(att.MediaType, "image/") {
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]string{"url": fmt.Sprintf("data:%s;base64,%s", att.MediaType, att.Data)},
+			})
+			continue
+		}
+		parts = append(parts, map[string]interface{}{
+			"type": "file",
+			"file": map[string]string{
+				"filename":  att.Name,
+				"file_data": fmt.Sprintf("data:%s;base64,%s", att.MediaType, att.Data),
+			},
+		})
+	}
+	return parts
+}
+
+func parseOpenAIResponse(resp *http.Response, logger *zap.Logger) (string, []byte, *models.Usage, error) {
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("erro ao ler resposta: %w", err)
+		return "", nil, nil, fmt.Errorf("erro ao ler resposta: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", &utils.APIError{StatusCode: resp.StatusCode, Message: string(body)}
+		return "", body, nil, utils.NewAPIError(catalog.ProviderOpenAI, resp.StatusCode, body).WithRetryAfter(resp.Header)
 	}
 
 	var result struct {
@@ -95,15 +294,209 @@ func parseOpenAIResponse(resp *http.Response) (string, error) {
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		logger.Debug("Resposta da OpenAI não pôde ser decodificada", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return "", body, nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+	}
+
+	var usage *models.Usage
+	if result.Usage != nil {
+		usage = &models.Usage{
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+			TotalTokens:      result.Usage.TotalTokens,
+		}
+	}
+
+	if len(result.Choices) == 0 {
+		logger.Debug("Resposta da OpenAI sem choices", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return "", body, usage, fmt.Errorf("nenhuma resposta recebida da OpenAI")
+	}
+
+	return result.Choices[0].Message.Content, body, usage, nil
+}
+
+// openAIToolCall é uma chamada de função pedida pelo modelo.
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAIToolReply é a mensagem do assistente em uma rodada com ferramentas.
+type openAIToolReply struct {
+	Content   string
+	ToolCalls []openAIToolCall
+	// raw é a mensagem original, devolvida à API junto com os resultados das ferramentas
+	raw json.RawMessage
+}
+
+// SendPromptWithTools envia o prompt com as ferramentas como "tools" do tipo function e
+// executa as tool_calls da resposta, devolvendo cada resultado em uma mensagem "tool". Na
+// última rodada, tool_choice "none" obriga a resposta em texto.
+func (c *Client) SendPromptWithTools(ctx context.Context, prompt string, history []models.Message, maxTokens int, tools []models.Tool, execute client.ToolExecutor) (string, error) {
+	if maxTokens <= 0 {
+		maxTokens = catalog.GetMaxTokens(catalog.ProviderOpenAI, c.model)
+	}
+	if err := client.CheckInputSize(catalog.ProviderOpenAI, c.model, prompt, history, maxTokens); err != nil {
+		return "", err
+	}
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderOpenAI)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	toolDefs := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		toolDefs = append(toolDefs, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			},
+		})
+	}
+
+	var messages []interface{}
+	for _, msg := range buildMessages(c.model, prompt, history, c.images) {
+		messages = append(messages, msg)
+	}
+	total := &models.Usage{}
+
+	for round := 1; ; round++ {
+		payload := map[string]interface{}{
+			"model":    c.model,
+			"messages": messages,
+			"tools":    toolDefs,
+		}
+		if round >= client.MaxToolRounds {
+			payload["tool_choice"] = "none"
+		}
+		catalog.MergeProviderParams(catalog.ProviderOpenAI, payload, c.params)
+
+		jsonValue, err := json.Marshal(payload)
+		if err != nil {
+			return "", fmt.Errorf("erro ao serializar payload: %w", err)
+		}
+
+		reply, err := utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (openAIToolReply, error) {
+			resp, err := c.do(ctx, c.httpClient, jsonValue)
+			if err != nil {
+				return openAIToolReply{}, err
+			}
+			reply, body, usage, err := parseOpenAIToolReply(resp, c.logger)
+			c.rawResponse = body
+			addUsage(total, usage)
+			return reply, err
+		})
+		if err != nil {
+			return "", utils.CategorizeError(err)
+		}
+		c.usage = total
+
+		if len(reply.ToolCalls) == 0 {
+			if reply.Content == "" {
+				return "", utils.CategorizeError(fmt.Errorf("resposta vazia da OpenAI"))
+			}
+			return reply.Content, nil
+		}
+		// Na última rodada as chamadas não são executadas, mesmo que o modelo ignore tool_choice
+		if round >= client.MaxToolRounds {
+			if reply.Content != "" {
+				return reply.Content, nil
+			}
+			return "", utils.CategorizeError(client.ErrToolRoundsExceeded)
+		}
+
+		messages = append(messages, reply.raw)
+		for _, call := range reply.ToolCalls {
+			c.logger.Debug("Ferramenta chamada pelo modelo", zap.String("tool", call.Function.Name), zap.Int("round", round))
+			result := execute(ctx, models.ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: json.RawMessage(call.Function.Arguments),
+			})
+			messages = append(messages, map[string]interface{}{
+				"role":         "tool",
+				"tool_call_id": call.ID,
+				"content":      result,
+			})
+		}
+	}
+}
+
+// parseOpenAIToolReply extrai a mensagem do assistente, com as tool_calls, e o consumo de tokens.
+func parseOpenAIToolReply(resp *http.Response, logger *zap.Logger) (openAIToolReply, []byte, *models.Usage, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return openAIToolReply{}, nil, nil, fmt.Errorf("erro ao ler resposta: %w", err)
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return openAIToolReply{}, body, nil, utils.NewAPIError(catalog.ProviderOpenAI, resp.StatusCode, body).WithRetryAfter(resp.Header)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message json.RawMessage `json:"message"`
+		} `json:"choices"`
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("erro ao decodificar resposta: %w", err)
+		logger.Debug("Resposta da OpenAI não pôde ser decodificada", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return openAIToolReply{}, body, nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
 	}
 
+	var usage *models.Usage
+	if result.Usage != nil {
+		usage = &models.Usage{
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+			TotalTokens:      result.Usage.TotalTokens,
+		}
+	}
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("nenhuma resposta recebida da OpenAI")
+		logger.Debug("Resposta da OpenAI sem choices", zap.String("body", utils.RedactBody(body, maxLoggedBody)))
+		return openAIToolReply{}, body, usage, fmt.Errorf("nenhuma resposta recebida da OpenAI")
+	}
+
+	var message struct {
+		Content   *string          `json:"content"`
+		ToolCalls []openAIToolCall `json:"tool_calls"`
+	}
+	if err := json.Unmarshal(result.Choices[0].Message, &message); err != nil {
+		return openAIToolReply{}, body, usage, fmt.Errorf("erro ao decodificar mensagem: %w", err)
 	}
 
-	return result.Choices[0].Message.Content, nil
+	reply := openAIToolReply{ToolCalls: message.ToolCalls, raw: result.Choices[0].Message}
+	if message.Content != nil {
+		reply.Content = *message.Content
+	}
+	return reply, body, usage, nil
+}
+
+// addUsage soma o consumo de uma rodada ao total da resposta.
+func addUsage(total, usage *models.Usage) {
+	if usage == nil {
+		return
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
 }
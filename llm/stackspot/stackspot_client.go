@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/webchatcomllm/config"
+	"github.com/webchatcomllm/llm/catalog"
+	"github.com/webchatcomllm/llm/client"
 	"github.com/webchatcomllm/llm/token"
 	"github.com/webchatcomllm/models"
 	"github.com/webchatcomllm/utils"
@@ -24,6 +26,8 @@ type Client struct {
 	httpClient   *http.Client
 	maxAttempts  int
 	backoff      time.Duration
+	headers      http.Header
+	usage        *models.Usage
 }
 
 func NewClient(tm token.Manager, agentID string, logger *zap.Logger, maxAttempts int, backoff time.Duration) *Client {
@@ -41,30 +45,91 @@ func (c *Client) GetModelName() string {
 	return "GPT-5" // Nome de exibição para o frontend
 }
 
+// LastUsage retorna o consumo de tokens da última chamada. O agente não informa o consumo,
+// então a contagem é estimada a partir do prompt enviado e da resposta.
+func (c *Client) LastUsage() *models.Usage {
+	return c.usage
+}
+
+// WithExtraHeaders define cabeçalhos adicionais enviados em todas as requisições ao provedor.
+func (c *Client) WithExtraHeaders(headers http.Header) *Client {
+	c.headers = headers
+	return c
+}
+
 func (c *Client) SendPrompt(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, error) {
+	message, _, err := c.SendPromptWithCitations(ctx, prompt, history, maxTokens)
+	return message, err
+}
+
+// SendPromptWithCitations envia o prompt e retorna também as fontes dos knowledge sources usadas na resposta.
+func (c *Client) SendPromptWithCitations(ctx context.Context, prompt string, history []models.Message, maxTokens int) (string, []models.Citation, error) {
+	if maxTokens <= 0 {
+		maxTokens = catalog.GetMaxTokens(catalog.ProviderStackSpot, config.StackSpotDefaultModel)
+	}
+	if err := client.CheckInputSize(catalog.ProviderStackSpot, config.StackSpotDefaultModel, prompt, history, maxTokens); err != nil {
+		return "", nil, err
+	}
+	release, err := utils.AcquireProviderSlot(ctx, catalog.ProviderStackSpot)
+	if err != nil {
+		return "", nil, err
+	}
+	defer release()
 	var conversationBuilder strings.Builder
 	for _, msg := range history {
 		role := "Usuário"
-		if msg.Role == "assistant" {
+		switch msg.Role {
+		case "assistant":
 			role = "Assistente"
+		case models.RoleSystem, models.RoleDeveloper:
+			role = "Instruções do sistema"
 		}
 		conversationBuilder.WriteString(fmt.Sprintf("%s: %s\n", role, msg.Content))
 	}
 	fullPrompt := conversationBuilder.String() + "Usuário: " + prompt
 
-	llmResponse, err := utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (string, error) {
-		return c.executeWithTokenRetry(ctx, func(token string) (string, error) {
+	result, err := utils.Retry(ctx, c.logger, c.maxAttempts, c.backoff, func(ctx context.Context) (chatResult, error) {
+		return c.executeWithTokenRetry(ctx, func(token string) (chatResult, error) {
 			return c.sendChatRequest(ctx, fullPrompt, token)
 		})
 	})
 
-	return llmResponse, err
+	if err == nil {
+		prompt, completion := utils.EstimateTokens(fullPrompt), utils.EstimateTokens(result.Message)
+		c.usage = &models.Usage{
+			PromptTokens:     prompt,
+			CompletionTokens: completion,
+			TotalTokens:      prompt + completion,
+			Estimated:        true,
+		}
+	}
+
+	return result.Message, result.Citations, utils.CategorizeError(err)
+}
+
+// chatResult é o conteúdo útil de uma resposta do agente.
+type chatResult struct {
+	Message   string
+	Citations []models.Citation
 }
 
-func (c *Client) executeWithTokenRetry(ctx context.Context, requestFunc func(string) (string, error)) (string, error) {
+// knowledgeSource descreve uma fonte retornada pelo agente quando stackspot_knowledge está ativo.
+type knowledgeSource struct {
+	Type         string `json:"type"`
+	Name         string `json:"name"`
+	Title        string `json:"title"`
+	Slug         string `json:"slug"`
+	URL          string `json:"url"`
+	DocumentType string `json:"document_type"`
+	DocumentID   string `json:"document_id"`
+	Content      string `json:"content"`
+	Snippet      string `json:"snippet"`
+}
+
+func (c *Client) executeWithTokenRetry(ctx context.Context, requestFunc func(string) (chatResult, error)) (chatResult, error) {
 	token, err := c.tokenManager.GetAccessToken(ctx)
 	if err != nil {
-		return "", fmt.Errorf("erro ao obter o token: %w", err)
+		return chatResult{}, fmt.Errorf("erro ao obter o token: %w", err)
 	}
 
 	response, err := requestFunc(token)
@@ -74,16 +139,16 @@ func (c *Client) executeWithTokenRetry(ctx context.Context, requestFunc func(str
 			c.logger.Info("Token inválido ou expirado, renovando...")
 			newToken, tokenErr := c.tokenManager.RefreshToken(ctx)
 			if tokenErr != nil {
-				return "", fmt.Errorf("erro ao renovar o token: %w", tokenErr)
+				return chatResult{}, fmt.Errorf("erro ao renovar o token: %w", tokenErr)
 			}
 			return requestFunc(newToken)
 		}
-		return "", err
+		return chatResult{}, err
 	}
 	return response, nil
 }
 
-func (c *Client) sendChatRequest(ctx context.Context, prompt, accessToken string) (string, error) {
+func (c *Client) sendChatRequest(ctx context.Context, prompt, accessToken string) (chatResult, error) {
 	url := fmt.Sprintf("%s/agent/%s/chat", config.StackSpotBaseURL, c.agentID)
 
 	// CORREÇÃO: Adicionados os campos "streaming" e "stackspot_knowledge"
@@ -96,32 +161,74 @@ func (c *Client) sendChatRequest(ctx context.Context, prompt, accessToken string
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, utils.NewJSONReader(jsonValue))
 	if err != nil {
-		return "", fmt.Errorf("erro ao criar requisição: %w", err)
+		return chatResult{}, fmt.Errorf("erro ao criar requisição: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+accessToken)
+	utils.ApplyHeaders(req, c.headers)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return chatResult{}, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("erro ao ler resposta: %w", err)
+		return chatResult{}, fmt.Errorf("erro ao ler resposta: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", &utils.APIError{StatusCode: resp.StatusCode, Message: string(body)}
+		return chatResult{}, utils.NewAPIError(catalog.ProviderStackSpot, resp.StatusCode, body).WithRetryAfter(resp.Header)
 	}
 
 	var response struct {
-		Message string `json:"message"`
+		Message string            `json:"message"`
+		Source  []knowledgeSource `json:"source"`
+		Sources []knowledgeSource `json:"sources"`
 	}
 	if err := json.Unmarshal(body, &response); err != nil {
-		return "", fmt.Errorf("erro ao decodificar resposta: %w", err)
+		return chatResult{}, fmt.Errorf("erro ao decodificar resposta: %w", err)
 	}
 
-	return response.Message, nil
+	return chatResult{
+		Message:   response.Message,
+		Citations: toCitations(append(response.Source, response.Sources...)),
+	}, nil
+}
+
+// toCitations converte as fontes do agente em citações, descartando entradas sem identificação
+// e duplicatas (o mesmo documento pode aparecer em vários trechos).
+func toCitations(sources []knowledgeSource) []models.Citation {
+	var citations []models.Citation
+	seen := make(map[string]bool)
+	for _, src := range sources {
+		title := src.Title
+		for _, candidate := range []string{src.Name, src.Slug, src.DocumentID} {
+			if title == "" {
+				title = candidate
+			}
+		}
+		if title == "" && src.URL == "" {
+			continue
+		}
+
+		snippet := src.Snippet
+		if snippet == "" {
+			snippet = src.Content
+		}
+
+		key := title + "|" + src.URL + "|" + snippet
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		citations = append(citations, models.Citation{
+			Title:   title,
+			URL:     src.URL,
+			Snippet: snippet,
+		})
+	}
+	return citations
 }
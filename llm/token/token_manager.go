@@ -61,6 +61,9 @@ func (tm *tokenManagerImpl) RefreshToken(ctx context.Context) (string, error) {
 	}
 
 	tm.logger.Info("Renovando access token", zap.String("realm", tm.realm))
+	defer func(start time.Time) {
+		utils.RecordPhase(ctx, utils.PhaseTokenRefresh, time.Since(start))
+	}(time.Now())
 
 	tokenURL := fmt.Sprintf("https://idm.stackspot.com/%s/oidc/oauth/token", tm.realm)
 	data := strings.NewReader(fmt.Sprintf("grant_type=client_credentials&client_id=%s&client_secret=%s", tm.clientID, tm.clientSecret))
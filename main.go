@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"text/template"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/webchatcomllm/config"
 	"github.com/webchatcomllm/handlers"
+	"github.com/webchatcomllm/llm/catalog"
+	llmclient "github.com/webchatcomllm/llm/client"
 	"github.com/webchatcomllm/llm/manager"
+	"github.com/webchatcomllm/metrics"
 	"github.com/webchatcomllm/middlewares"
+	"github.com/webchatcomllm/store"
+	"github.com/webchatcomllm/utils"
 	"go.uber.org/zap"
 )
 
@@ -23,16 +34,60 @@ func main() {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
+	if err := catalog.ConfigureProviderAliases(config.GetEnvList("PROVIDER_ALIASES", nil)); err != nil {
+		logger.Fatal("Configuração de aliases de provedor inválida", zap.Error(err))
+	}
+	// Sem o arquivo, o catálogo embutido é usado sem alterações
+	if path := config.GetEnvString("MODELS_CONFIG_PATH", ""); path != "" {
+		if err := catalog.LoadFromFile(path); errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("Arquivo de modelos não encontrado, usando o catálogo padrão", zap.String("path", path))
+		} else if err != nil {
+			logger.Fatal("Arquivo de modelos inválido", zap.Error(err))
+		}
+	}
+	// Depois do arquivo, para que MODEL_ALIASES prevaleça sobre os aliases declarados nele
+	if err := catalog.ConfigureModelAliases(config.GetEnvList("MODEL_ALIASES", nil)); err != nil {
+		logger.Fatal("Configuração de aliases de modelo inválida", zap.Error(err))
+	}
+	if err := utils.ConfigureRetryableErrorCodes(catalog.Providers()); err != nil {
+		logger.Fatal("Configuração de códigos de erro inválida", zap.Error(err))
+	}
+	if err := utils.ConfigureRetryableStatusCodes(); err != nil {
+		logger.Fatal("Configuração de status com retry inválida", zap.Error(err))
+	}
+	llmclient.ConfigureInputSizeCheck()
+
+	if err := handlers.LoadHandlerConfig().Validate(); err != nil {
+		logger.Fatal("Configuração dos handlers inválida", zap.Error(err))
+	}
+
 	llmManager, err := manager.NewLLMManager(logger)
 	if err != nil {
 		logger.Fatal("Erro ao inicializar LLMManager", zap.Error(err))
 	}
 
+	// O aquecimento roda em segundo plano para não atrasar a inicialização
+	if warmer, ok := llmManager.(manager.Warmer); ok && config.GetEnvBool("WARMUP_ON_START", false) {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), config.GetEnvDuration("WARMUP_TIMEOUT", 30*time.Second))
+			defer cancel()
+			warmer.Warmup(ctx)
+		}()
+	}
+
+	conversations, err := store.New(store.LoadConfig(), logger)
+	if err != nil {
+		logger.Fatal("Erro ao inicializar armazenamento de conversas", zap.Error(err))
+	}
+	defer conversations.Close()
+
 	mux := http.NewServeMux()
 
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	// Cada rota aceita apenas os métodos que implementa; os demais recebem 405 com Allow
+	staticFiles := http.StripPrefix("/static/", http.FileServer(http.Dir("static")))
+	mux.HandleFunc("/static/", handlers.AllowMethods(staticFiles.ServeHTTP, http.MethodGet))
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", handlers.AllowMethods(func(w http.ResponseWriter, r *http.Request) {
 		tmpl, err := template.ParseFiles(filepath.Join("templates", "index.html"))
 		if err != nil {
 			http.Error(w, "Erro interno no servidor", http.StatusInternalServerError)
@@ -42,11 +97,41 @@ func main() {
 		if err := tmpl.Execute(w, nil); err != nil {
 			logger.Error("Erro ao executar template", zap.Error(err))
 		}
-	})
+	}, http.MethodGet))
+
+	// WS_API_KEY protege o WebSocket e as rotas de chat e sessões; a página, os arquivos
+	// estáticos e /providers continuam públicos
+	apiKey := config.GetEnvString("WS_API_KEY", "")
+	wsHandler := handlers.AllowMethods(handlers.WebSocketHandler(llmManager, conversations, logger), http.MethodGet)
+	mux.Handle("/ws", middlewares.RequireAPIKey(wsHandler, apiKey, logger))
+	mux.Handle("/api/chat", middlewares.RequireAPIKey(handlers.AllowMethods(handlers.ChatAPIHandler(llmManager, logger), http.MethodPost), apiKey, logger))
+	mux.Handle(handlers.ChatStreamPath, middlewares.RequireAPIKey(handlers.AllowMethods(handlers.ChatStreamHandler(llmManager, logger), http.MethodPost), apiKey, logger))
+	mux.HandleFunc("/providers", handlers.AllowMethods(handlers.ProvidersHandler(llmManager, logger), http.MethodGet))
+	mux.Handle("/api/sessions", middlewares.RequireAPIKey(handlers.AllowMethods(handlers.NewSessionHandler(logger), http.MethodPost), apiKey, logger))
+	mux.Handle("/api/sessions/{id}", middlewares.RequireAPIKey(handlers.AllowMethods(handlers.SessionsAPIHandler(conversations, logger), http.MethodGet), apiKey, logger))
 
-	mux.HandleFunc("/ws", handlers.WebSocketHandler(llmManager, logger))
+	// Métricas: o mesmo registro exportado para Prometheus e em JSON, atrás do ADMIN_TOKEN
+	adminToken := config.GetEnvString("ADMIN_TOKEN", "")
+	mux.Handle("/metrics", middlewares.RequireAdminToken(handlers.AllowMethods(metrics.Default.PrometheusHandler(), http.MethodGet), adminToken, logger))
+	mux.Handle("/api/metrics.json", middlewares.RequireAdminToken(handlers.AllowMethods(metrics.Default.JSONHandler(), http.MethodGet), adminToken, logger))
+	mux.Handle("/api/admin/connections", middlewares.RequireAdminToken(handlers.AllowMethods(handlers.ConnectionsHandler(conversations, logger), http.MethodGet), adminToken, logger))
+
+	// Rotas REST têm duração máxima; WebSocket e streaming SSE ficam de fora por serem longos
+	requestTimeout := config.GetEnvDuration("HTTP_REQUEST_TIMEOUT", 0)
+	timed := middlewares.RequestTimeoutMiddleware(mux, requestTimeout, handlers.IsLongLivedRequest, logger)
+	trustedProxies, err := middlewares.ParseTrustedProxies(config.GetEnvList("TRUSTED_PROXIES", nil))
+	if err != nil {
+		logger.Fatal("Configuração de proxies confiáveis inválida", zap.Error(err))
+	}
+	limited := middlewares.RateLimitMiddleware(timed, config.GetEnvInt("RATE_LIMIT_RPS", 0), config.GetEnvInt("RATE_LIMIT_BURST", 0), trustedProxies, logger)
+	secured := middlewares.ForceHTTPSMiddleware(limited, logger)
 
-	finalHandler := middlewares.ForceHTTPSMiddleware(mux, logger)
+	// As sondas do Kubernetes chamam o pod diretamente por HTTP, então ficam fora do
+	// redirecionamento para HTTPS e do rate limit
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", handlers.AllowMethods(handlers.HealthzHandler(), http.MethodGet))
+	root.HandleFunc("/readyz", handlers.AllowMethods(handlers.ReadyzHandler(llmManager), http.MethodGet))
+	root.Handle("/", secured)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -55,14 +140,41 @@ func main() {
 
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      finalHandler,
+		Handler:      root,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
 
-	logger.Info("Servidor iniciado na porta", zap.String("port", port))
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logger.Fatal("Erro ao iniciar servidor", zap.Error(err))
+	go func() {
+		logger.Info("Servidor iniciado na porta", zap.String("port", port))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Erro ao iniciar servidor", zap.Error(err))
+		}
+	}()
+
+	// No desligamento, /readyz passa a responder 503 e, após READINESS_DRAIN_DELAY (tempo para o
+	// balanceador deixar de enviar tráfego), o listener é fechado, para que nenhuma conexão nova
+	// chegue durante o encerramento; em seguida as conexões WebSocket recebem a dica de reconexão.
+	// Shutdown não acompanha conexões WebSocket (sequestradas do servidor HTTP), por isso as
+	// duas etapas correm juntas dentro do mesmo prazo.
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	<-stop.Done()
+
+	handlers.MarkShuttingDown()
+	if delay := config.GetEnvDuration("READINESS_DRAIN_DELAY", 0); delay > 0 {
+		logger.Info("Aguardando o balanceador remover o pod", zap.Duration("delay", delay))
+		time.Sleep(delay)
+	}
+
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), config.GetEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second))
+	defer cancelShutdown()
+	logger.Info("Encerrando servidor")
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- server.Shutdown(ctx) }()
+	handlers.DrainWebSocketClients(ctx, logger)
+	if err := <-shutdownErr; err != nil {
+		logger.Warn("Erro ao encerrar servidor", zap.Error(err))
 	}
 }
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Family é uma métrica com todas as suas séries, no formato comum aos exportadores.
+type Family struct {
+	Name    string   `json:"name"`
+	Help    string   `json:"help"`
+	Type    string   `json:"type"` // counter ou gauge
+	Samples []Sample `json:"samples"`
+}
+
+// Snapshot retorna os valores atuais de todas as métricas, ordenadas pelo nome.
+func (r *Registry) Snapshot() []Family {
+	var families []Family
+	for _, c := range r.Counters() {
+		families = append(families, Family{Name: c.Name, Help: c.Help, Type: "counter", Samples: c.Samples()})
+	}
+	for _, g := range r.Gauges() {
+		families = append(families, Family{Name: g.Name, Help: g.Help, Type: "gauge", Samples: g.Samples()})
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
+
+// WritePrometheus escreve o snapshot no formato de texto do Prometheus.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	for _, f := range r.Snapshot() {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.Name, escapeHelp(f.Help), f.Name, f.Type); err != nil {
+			return err
+		}
+		for _, s := range f.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", f.Name, formatLabels(s.Labels), strconv.FormatFloat(s.Value, 'g', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrometheusHandler exporta o registro para scrape do Prometheus.
+func (r *Registry) PrometheusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	}
+}
+
+// JSONHandler exporta o mesmo snapshot em JSON, para painéis e health checks sem Prometheus.
+func (r *Registry) JSONHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Timestamp time.Time `json:"timestamp"`
+			Metrics   []Family  `json:"metrics"`
+		}{time.Now().UTC(), r.Snapshot()})
+	}
+}
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + labelEscaper.Replace(labels[name]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// Gauge é um valor que sobe e desce, com séries por labels. Com fn definida, o valor é
+// calculado no momento da leitura (ex.: conexões ativas).
+type Gauge struct {
+	Name string
+	Help string
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string]Labels
+	fn     func() float64
+}
+
+// Set define o valor da série correspondente às labels.
+func (g *Gauge) Set(v float64, labels Labels) {
+	g.update(labels, func(float64) float64 { return v })
+}
+
+// Add soma v (positivo ou negativo) à série correspondente às labels.
+func (g *Gauge) Add(v float64, labels Labels) {
+	g.update(labels, func(current float64) float64 { return current + v })
+}
+
+func (g *Gauge) update(labels Labels, apply func(float64) float64) {
+	key := labels.key()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.labels[key]; !ok {
+		copied := make(Labels, len(labels))
+		for k, val := range labels {
+			copied[k] = val
+		}
+		g.labels[key] = copied
+	}
+	g.values[key] = apply(g.values[key])
+}
+
+// Samples retorna uma cópia dos valores atuais, ordenada pelas labels.
+func (g *Gauge) Samples() []Sample {
+	g.mu.Lock()
+	fn := g.fn
+	g.mu.Unlock()
+	if fn != nil {
+		return []Sample{{Value: fn()}}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	keys := make([]string, 0, len(g.values))
+	for key := range g.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]Sample, len(keys))
+	for i, key := range keys {
+		samples[i] = Sample{Labels: g.labels[key], Value: g.values[key]}
+	}
+	return samples
+}
+
+// Gauge retorna o gauge com o nome informado, criando-o na primeira chamada.
+func (r *Registry) Gauge(name, help string) *Gauge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g, ok := r.gauges[name]; ok {
+		return g
+	}
+	g := &Gauge{Name: name, Help: help, values: make(map[string]float64), labels: make(map[string]Labels)}
+	r.gauges[name] = g
+	return g
+}
+
+// GaugeFunc registra um gauge sem labels cujo valor é lido de fn a cada exportação. Uma nova
+// chamada com o mesmo nome substitui a função.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) *Gauge {
+	g := r.Gauge(name, help)
+	g.mu.Lock()
+	g.fn = fn
+	g.mu.Unlock()
+	return g
+}
+
+// Gauges retorna os gauges registrados, ordenados pelo nome.
+func (r *Registry) Gauges() []*Gauge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	gauges := make([]*Gauge, 0, len(r.gauges))
+	for _, g := range r.gauges {
+		gauges = append(gauges, g)
+	}
+	sort.Slice(gauges, func(i, j int) bool { return gauges[i].Name < gauges[j].Name })
+	return gauges
+}
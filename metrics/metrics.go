@@ -0,0 +1,120 @@
+// Package metrics mantém contadores e gauges em memória compartilhados pelos exportadores da
+// aplicação (Prometheus em /metrics e JSON em /api/metrics.json).
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Labels identifica uma série de um contador (ex.: {"provider": "OPENAI"}).
+type Labels map[string]string
+
+// key gera uma chave estável para o conjunto de labels.
+func (l Labels) key() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + l[name]
+	}
+	return strings.Join(parts, ",")
+}
+
+// Counter é um contador monotônico com séries por labels.
+type Counter struct {
+	Name string
+	Help string
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string]Labels
+}
+
+// Inc incrementa a série correspondente às labels.
+func (c *Counter) Inc(labels Labels) {
+	c.Add(1, labels)
+}
+
+// Add soma v à série correspondente às labels.
+func (c *Counter) Add(v float64, labels Labels) {
+	key := labels.key()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.labels[key]; !ok {
+		copied := make(Labels, len(labels))
+		for k, val := range labels {
+			copied[k] = val
+		}
+		c.labels[key] = copied
+	}
+	c.values[key] += v
+}
+
+// Sample é o valor de uma série em um instante.
+type Sample struct {
+	Labels Labels  `json:"labels,omitempty"`
+	Value  float64 `json:"value"`
+}
+
+// Samples retorna uma cópia dos valores atuais, ordenada pelas labels.
+func (c *Counter) Samples() []Sample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]Sample, len(keys))
+	for i, key := range keys {
+		samples[i] = Sample{Labels: c.labels[key], Value: c.values[key]}
+	}
+	return samples
+}
+
+// Registry agrupa os contadores e gauges pelo nome.
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]*Counter
+	gauges   map[string]*Gauge
+}
+
+// NewRegistry cria um registro vazio.
+func NewRegistry() *Registry {
+	return &Registry{counters: make(map[string]*Counter), gauges: make(map[string]*Gauge)}
+}
+
+// Default é o registro usado pela aplicação.
+var Default = NewRegistry()
+
+// Counter retorna o contador com o nome informado, criando-o na primeira chamada.
+func (r *Registry) Counter(name, help string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	c := &Counter{Name: name, Help: help, values: make(map[string]float64), labels: make(map[string]Labels)}
+	r.counters[name] = c
+	return c
+}
+
+// Counters retorna os contadores registrados, ordenados pelo nome.
+func (r *Registry) Counters() []*Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counters := make([]*Counter, 0, len(r.counters))
+	for _, c := range r.counters {
+		counters = append(counters, c)
+	}
+	sort.Slice(counters, func(i, j int) bool { return counters[i].Name < counters[j].Name })
+	return counters
+}
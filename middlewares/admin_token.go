@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// RequireAdminToken protege rotas administrativas com o token de ADMIN_TOKEN, enviado em
+// "Authorization: Bearer <token>" ou no cabeçalho X-Admin-Token. Sem token configurado, as
+// rotas ficam abertas.
+func RequireAdminToken(next http.Handler, token string, logger *zap.Logger) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := r.Header.Get("X-Admin-Token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			provided = strings.TrimSpace(bearer)
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			logger.Warn("Acesso administrativo recusado",
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr),
+			)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "Token administrativo inválido ou ausente.", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
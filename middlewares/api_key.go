@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// RequireAPIKey exige a chave de WS_API_KEY em "Authorization: Bearer <chave>". Apenas no
+// upgrade do WebSocket, em que navegadores não permitem cabeçalhos customizados, a chave também
+// é aceita no parâmetro ?token=, que é removido da URL antes de chegar ao handler para não
+// aparecer em logs. Sem chave configurada, a rota fica aberta.
+func RequireAPIKey(next http.Handler, key string, logger *zap.Logger) http.Handler {
+	if key == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var provided string
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			provided = strings.TrimSpace(bearer)
+		} else if websocket.IsWebSocketUpgrade(r) {
+			provided = r.URL.Query().Get("token")
+		}
+		if query := r.URL.Query(); query.Has("token") {
+			query.Del("token")
+			r.URL.RawQuery = query.Encode()
+		}
+
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+			logger.Warn("Requisição recusada: chave de API inválida ou ausente",
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr),
+			)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			http.Error(w, "Chave de API inválida ou ausente.", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,200 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/webchatcomllm/metrics"
+	"go.uber.org/zap"
+)
+
+var rateLimitedRequests = metrics.Default.Counter("http_rate_limited_requests_total", "Requisições HTTP recusadas por RATE_LIMIT_RPS")
+
+// staleBucketAfter é o tempo sem requisições após o qual o balde de um IP é descartado.
+const staleBucketAfter = 10 * time.Minute
+
+// ipBucket é o token bucket de um IP.
+type ipBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ipRateLimiter limita as requisições por IP: cada IP acumula até burst requisições e repõe
+// rps por segundo.
+type ipRateLimiter struct {
+	mu      sync.Mutex
+	rps     float64
+	burst   float64
+	buckets map[string]*ipBucket
+}
+
+// allow consome uma requisição do balde do IP. Quando recusada, retorna também a espera até
+// a próxima requisição aceita.
+func (l *ipRateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &ipBucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rps
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rps * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// cleanup descarta os baldes sem requisições há mais de staleBucketAfter, evitando que o mapa
+// cresça indefinidamente com IPs que não voltam.
+func (l *ipRateLimiter) cleanup(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	removed := 0
+	for ip, b := range l.buckets {
+		if now.Sub(b.last) > staleBucketAfter {
+			delete(l.buckets, ip)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RateLimitMiddleware limita as requisições por IP do cliente com um token bucket de rps
+// requisições por segundo e rajada burst (RATE_LIMIT_RPS e RATE_LIMIT_BURST). O IP é o endereço
+// da conexão; X-Forwarded-For só é considerado quando a conexão vem de um dos trustedProxies
+// (TRUSTED_PROXIES). Requisições acima do limite recebem 429 com Retry-After e um corpo JSON.
+// rps <= 0 desativa; burst <= 0 usa rps.
+func RateLimitMiddleware(next http.Handler, rps, burst int, trustedProxies []netip.Prefix, logger *zap.Logger) http.Handler {
+	if rps <= 0 {
+		return next
+	}
+	if burst <= 0 {
+		burst = rps
+	}
+	limiter := &ipRateLimiter{
+		rps:     float64(rps),
+		burst:   float64(burst),
+		buckets: make(map[string]*ipBucket),
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if removed := limiter.cleanup(now); removed > 0 {
+				logger.Debug("Baldes de rate limit inativos descartados", zap.Int("removed", removed))
+			}
+		}
+	}()
+
+	logger.Info("Rate limit por IP ativado", zap.Int("rps", rps), zap.Int("burst", burst))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r, trustedProxies)
+		ok, wait := limiter.allow(ip, time.Now())
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rateLimitedRequests.Inc(nil)
+		logger.Warn("Requisição recusada por rate limit",
+			zap.String("ip", ip),
+			zap.String("path", r.URL.Path),
+		)
+		retryAfter := int(wait.Seconds())
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":        "error",
+			"response":      "Muitas requisições. Aguarde alguns instantes e tente novamente.",
+			"errorCategory": "rate_limit",
+		})
+	})
+}
+
+// ParseTrustedProxies interpreta TRUSTED_PROXIES: IPs ou faixas CIDR dos proxies reversos
+// cujo X-Forwarded-For é confiável (ex.: "10.0.0.0/8,127.0.0.1").
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: faixa inválida %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: IP inválido %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// trustedProxy indica se o IP pertence a um dos proxies confiáveis.
+func trustedProxy(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP retorna o IP do cliente. Sem proxy confiável na conexão, é o endereço da conexão,
+// pois X-Forwarded-For pode ser forjado pelo próprio cliente. Atrás de um proxy confiável, é o
+// endereço mais à direita de X-Forwarded-For que não é de outro proxy confiável: os endereços à
+// esquerda dele foram escritos por quem não é confiável.
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !trustedProxy(ip, trustedProxies) {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		ip = hop
+		if !trustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+	return ip
+}
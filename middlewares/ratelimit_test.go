@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRateLimitMiddlewareExhaustsBucket(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := RateLimitMiddleware(ok, 1, 3, nil, zap.NewNop())
+
+	request := func(remoteAddr, forwarded string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		r.RemoteAddr = remoteAddr
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := request("203.0.113.7:5000", ""); w.Code != http.StatusOK {
+			t.Fatalf("requisição %d: status %d, want 200", i+1, w.Code)
+		}
+	}
+	// Sem proxy confiável, um X-Forwarded-For forjado não troca de balde
+	w := request("203.0.113.7:5001", "198.51.100.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("429 sem Retry-After")
+	}
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body["errorCategory"] != "rate_limit" {
+		t.Fatalf("corpo = %v, err = %v", body, err)
+	}
+
+	if w := request("192.0.2.1:5000", ""); w.Code != http.StatusOK {
+		t.Fatalf("outro IP: status %d, want 200", w.Code)
+	}
+}
+
+func TestClientIPTrustedProxies(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"sem proxy ignora o cabeçalho", "203.0.113.7:1", "198.51.100.1", "203.0.113.7"},
+		{"proxy confiável", "10.0.0.2:1", "198.51.100.1", "198.51.100.1"},
+		{"endereço forjado à esquerda", "10.0.0.2:1", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"cadeia de proxies", "127.0.0.1:1", "198.51.100.1, 10.1.1.1", "198.51.100.1"},
+		{"só proxies", "10.0.0.2:1", "10.0.0.3", "10.0.0.3"},
+		{"entrada inválida", "10.0.0.2:1", "lixo, 10.0.0.3", "10.0.0.3"},
+		{"sem cabeçalho", "10.0.0.2:1", "", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(r, trusted); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("faixa inválida aceita")
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy"}); err == nil {
+		t.Error("IP inválido aceito")
+	}
+}
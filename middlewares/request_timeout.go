@@ -0,0 +1,111 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// requestTimeoutBody é o corpo enviado quando a requisição excede HTTP_REQUEST_TIMEOUT.
+const requestTimeoutBody = "Tempo máximo da requisição excedido. Tente novamente em instantes."
+
+// RequestTimeoutMiddleware limita a duração total das requisições a timeout (HTTP_REQUEST_TIMEOUT).
+// O prazo é aplicado ao contexto da requisição, cancelando as chamadas em andamento; se o
+// handler ainda não tiver respondido quando o prazo expirar, o cliente recebe 503. Ao
+// contrário de http.TimeoutHandler, a resposta não é bufferizada. Requisições para as quais
+// exempt retorna true (WebSocket, streaming) não são afetadas. timeout <= 0 desativa.
+func RequestTimeoutMiddleware(next http.Handler, timeout time.Duration, exempt func(*http.Request) bool, logger *zap.Logger) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exempt != nil && exempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{ResponseWriter: w, ctx: ctx}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+
+		if tw.finish() {
+			logger.Warn("Requisição excedeu o tempo máximo",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Duration("timeout", timeout),
+			)
+		}
+	})
+}
+
+// timeoutWriter troca a resposta do handler por 503 quando o prazo expira antes do cabeçalho
+// ser escrito; o que o handler escrever depois disso é descartado.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	if errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.timedOut = true
+		writeTimeoutResponse(tw.ResponseWriter)
+		return
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(http.StatusOK)
+	if tw.timedOut {
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap permite que http.ResponseController alcance o writer original.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// finish responde 503 se o handler retornou sem escrever nada após o prazo. Retorna true
+// quando a requisição terminou por timeout.
+func (tw *timeoutWriter) finish() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader && errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.wroteHeader = true
+		tw.timedOut = true
+		writeTimeoutResponse(tw.ResponseWriter)
+	}
+	return tw.timedOut
+}
+
+func writeTimeoutResponse(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(requestTimeoutBody))
+}
@@ -1,6 +1,66 @@
 package models
 
+import "encoding/json"
+
+// Papéis aceitos nas mensagens do histórico. RoleDeveloper traz instruções do integrador que
+// têm precedência sobre as do usuário (hierarquia de instruções da OpenAI); os provedores sem
+// esse papel o tratam como RoleSystem.
+const (
+	RoleSystem    = "system"
+	RoleDeveloper = "developer"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ID identifica a mensagem gravada em uma sessão; ParentID aponta a mensagem à qual ela
+	// responde. Vazio em ParentID continua a partir da mensagem gravada anterior.
+	ID       string `json:"id,omitempty"`
+	ParentID string `json:"parentId,omitempty"`
+}
+
+// IsInstruction indica se a mensagem é uma instrução de sistema ou do desenvolvedor, e não
+// parte da conversa.
+func (m Message) IsInstruction() bool {
+	return m.Role == RoleSystem || m.Role == RoleDeveloper
+}
+
+// Attachment representa um arquivo enviado de forma nativa ao provedor (ex.: PDF em base64).
+type Attachment struct {
+	Name      string `json:"name"`
+	MediaType string `json:"mediaType"`
+	Data      string `json:"data"` // Conteúdo codificado em base64
+}
+
+// Citation representa uma fonte citada pelo provedor na resposta (ex.: documentos de knowledge sources).
+type Citation struct {
+	Title   string `json:"title"`
+	URL     string `json:"url,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Usage reúne a contagem de tokens informada pelo provedor para uma resposta.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+	// Estimated indica contagem estimada localmente, para provedores que não a informam
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// Tool descreve uma ferramenta que o modelo pode chamar durante a resposta. Parameters é o
+// JSON Schema dos argumentos.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// ToolCall é uma chamada de ferramenta pedida pelo modelo, com os argumentos em JSON.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage
 }
@@ -19,6 +19,7 @@ class ConnectionManager {
         this.state = 'disconnected';
         this.reconnectAttempts = 0;
         this.reconnectTimer = null;
+        this.reconnectHint = null;
         this.pingTimer = null;
         this.lastPong = Date.now();
         this.messageQueue = [];
@@ -27,7 +28,10 @@ class ConnectionManager {
 
     getWebSocketURL() {
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        return `${protocol}//${window.location.host}/ws`;
+        // Repassa ao WebSocket a chave informada na página (?token=), exigida com WS_API_KEY
+        const token = new URLSearchParams(window.location.search).get('token');
+        const query = token ? `?token=${encodeURIComponent(token)}` : '';
+        return `${protocol}//${window.location.host}/ws${query}`;
     }
 
     connect() {
@@ -79,6 +83,13 @@ class ConnectionManager {
                 return;
             }
 
+            // O servidor vai encerrar a conexão; a próxima tentativa usa a espera sugerida
+            if (data.type === 'reconnect') {
+                console.log('🔄 Servidor pediu reconexão', { reason: data.reason, retryAfterMs: data.retryAfterMs });
+                this.reconnectHint = data.retryAfterMs;
+                return;
+            }
+
             this.emit('message', data);
         } catch (error) {
             console.error('❌ Erro ao processar mensagem:', error);
@@ -119,10 +130,14 @@ class ConnectionManager {
         this.setState('reconnecting');
         this.reconnectAttempts++;
 
-        const backoff = Math.min(
+        let backoff = Math.min(
             this.config.initialBackoff * Math.pow(2, this.reconnectAttempts - 1),
             this.config.maxBackoff
         );
+        if (typeof this.reconnectHint === 'number') {
+            backoff = this.reconnectHint;
+            this.reconnectHint = null;
+        }
 
         console.log(`🔄 Reconectando em ${backoff}ms (tentativa ${this.reconnectAttempts})...`);
 
@@ -80,7 +80,7 @@ document.addEventListener('DOMContentLoaded', () => {
             color: '#4CAF50'
         },
         code: {
-            extensions: ['.js', '.ts', '.py', '.go', '.java', '.c', '.cpp', '.h', '.cs', '.rb', '.php', '.html', '.css', '.scss', '.sass'],
+            extensions: ['.js', '.ts', '.py', '.go', '.java', '.c', '.cpp', '.h', '.cs', '.rb', '.php', '.html', '.css', '.scss', '.sass', '.diff', '.patch'],
             maxSize: 5 * 1024 * 1024,
             icon: '💻',
             color: '#9C27B0'
@@ -102,9 +102,26 @@ document.addEventListener('DOMContentLoaded', () => {
             maxSize: 5 * 1024 * 1024,
             icon: '📄',
             color: '#9E9E9E'
+        },
+        gzip: {
+            extensions: ['.gz', '.gzip'],
+            mimeTypes: ['application/gzip', 'application/x-gzip'],
+            maxSize: 5 * 1024 * 1024,
+            icon: '🗜️',
+            color: '#795548'
         }
     };
 
+    const ERROR_CATEGORY_ICONS = {
+        network: '📡',
+        timeout: '⏱️',
+        rate_limit: '🚦',
+        auth: '🔒',
+        server: '🛠️',
+        client: '⚠️'
+    };
+    const RETRYABLE_ERROR_CATEGORIES = ['network', 'timeout', 'rate_limit', 'server'];
+
     const MAX_TOTAL_SIZE = 50 * 1024 * 1024;
     const MAX_FILES = 50;
 
@@ -127,6 +144,7 @@ document.addEventListener('DOMContentLoaded', () => {
             this.state = 'disconnected';
             this.reconnectAttempts = 0;
             this.reconnectTimer = null;
+            this.reconnectHint = null;
             this.pingTimer = null;
             this.lastPong = Date.now();
             this.messageQueue = [];
@@ -135,7 +153,10 @@ document.addEventListener('DOMContentLoaded', () => {
 
         getWebSocketURL() {
             const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-            return `${protocol}//${window.location.host}/ws`;
+            // Repassa ao WebSocket a chave informada na página (?token=), exigida com WS_API_KEY
+            const token = new URLSearchParams(window.location.search).get('token');
+            const query = token ? `?token=${encodeURIComponent(token)}` : '';
+            return `${protocol}//${window.location.host}/ws${query}`;
         }
 
         connect() {
@@ -187,6 +208,13 @@ document.addEventListener('DOMContentLoaded', () => {
                     return;
                 }
 
+                // O servidor vai encerrar a conexão; a próxima tentativa usa a espera sugerida
+                if (data.type === 'reconnect') {
+                    console.log('🔄 Servidor pediu reconexão', { reason: data.reason, retryAfterMs: data.retryAfterMs });
+                    this.reconnectHint = data.retryAfterMs;
+                    return;
+                }
+
                 this.emit('message', data);
             } catch (error) {
                 console.error('❌ Erro ao processar mensagem:', error);
@@ -227,10 +255,14 @@ document.addEventListener('DOMContentLoaded', () => {
             this.setState('reconnecting');
             this.reconnectAttempts++;
 
-            const backoff = Math.min(
+            let backoff = Math.min(
                 this.config.initialBackoff * Math.pow(2, this.reconnectAttempts - 1),
                 this.config.maxBackoff
             );
+            if (typeof this.reconnectHint === 'number') {
+                backoff = this.reconnectHint;
+                this.reconnectHint = null;
+            }
 
             console.log(`🔄 Reconectando em ${backoff}ms (tentativa ${this.reconnectAttempts})...`);
 
@@ -469,8 +501,15 @@ document.addEventListener('DOMContentLoaded', () => {
             console.log('✅ Conexão estabelecida');
         });
 
-        connectionManager.on('disconnected', () => {
+        connectionManager.on('disconnected', (event) => {
             updateConnectionStatus(false);
+
+            // 1009: mensagem acima do limite do servidor, normalmente por histórico longo
+            if (event && event.code === 1009) {
+                removeLoadingIndicator();
+                removeProgressMessage();
+                addMessage('Erro', '❌ Mensagem muito grande para o servidor. O histórico desta conversa ficou grande demais: inicie uma nova conversa ou envie menos arquivos.', 'error-message', false, false);
+            }
         });
 
         connectionManager.on('reconnecting', (data) => {
@@ -593,6 +632,12 @@ document.addEventListener('DOMContentLoaded', () => {
 // MANIPULAÇÃO DE MENSAGENS DO SERVIDOR
 // ============================================
     function handleServerMessage(data) {
+        if (data.type === 'status') {
+            const level = data.status === 'error' ? 'error' : 'info';
+            showNotification(data.response, level, data.status === 'waiting' ? 8000 : 3000);
+            return;
+        }
+
         removeLastMessageIfTyping();
         removeLoadingIndicator();
 
@@ -601,6 +646,15 @@ document.addEventListener('DOMContentLoaded', () => {
             return;
         }
 
+        if (data.status === 'streaming') {
+            removeProgressMessage();
+            appendStreamingChunk(data.response);
+            return;
+        }
+
+        // A resposta completa substitui o texto parcial recebido em streaming
+        const streamed = removeStreamingMessage();
+
         if (data.status === 'completed') {
             const isMarkdown = data.isMarkdown !== undefined ? data.isMarkdown : true;
 
@@ -613,12 +667,32 @@ document.addEventListener('DOMContentLoaded', () => {
 
             removeProgressMessage();
 
-            // SEMPRE usar o efeito de digitação avançado
-            addMessageWithTypingEffect(assistantName, data.response, 'assistant-message', isMarkdown, true);
+            if (data.metadata && data.metadata.contextWarning) {
+                showNotification(data.metadata.contextWarning, 'info', 8000);
+            }
+            if (data.metadata && data.metadata.fallbackAttempts && data.metadata.providerNotice) {
+                showNotification(data.metadata.providerNotice, 'info', 8000);
+            }
+            if (data.metadata && data.metadata.securityWarning) {
+                showNotification(data.metadata.securityWarning, 'info', 8000);
+            }
+
+            if (streamed) {
+                addMessage(assistantName, data.response, 'assistant-message', isMarkdown, true, false, data.citations);
+            } else {
+                // SEMPRE usar o efeito de digitação avançado
+                addMessageWithTypingEffect(assistantName, data.response, 'assistant-message', isMarkdown, true, data.citations);
+            }
+            renderTokenUsage(messagesDiv.lastElementChild, data);
 
         } else if (data.status === 'error') {
             removeProgressMessage();
-            addMessage('Erro', data.response, 'error-message', false, false);
+            const icon = ERROR_CATEGORY_ICONS[data.errorCategory] || '❌';
+            let text = `${icon} ${data.response}`;
+            if (RETRYABLE_ERROR_CATEGORIES.includes(data.errorCategory)) {
+                text += '\n\nEste erro é temporário. Tente enviar a mensagem novamente em instantes.';
+            }
+            addMessage('Erro', text, 'error-message', false, false);
         }
     }
 
@@ -875,7 +949,8 @@ document.addEventListener('DOMContentLoaded', () => {
             model: provider.model || "",
             prompt: message,
             history: history,
-            files: attachedFiles.slice() // Clona para evitar mutação
+            files: attachedFiles.slice(), // Clona para evitar mutação
+            stream: true
         };
 
         // LOG DETALHADO
@@ -1042,6 +1117,7 @@ document.addEventListener('DOMContentLoaded', () => {
         const isImage = fileInfo.type === 'image';
         const isPDF = fileInfo.type === 'pdf';
         const isOffice = ['docx', 'xlsx'].includes(fileInfo.type);
+        const isBinary = isImage || isPDF || isOffice || fileInfo.type === 'gzip';
 
         const relativePath = file.webkitRelativePath || file.name;
 
@@ -1050,7 +1126,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
             reader.onload = (e) => {
                 let content = e.target.result;
-                let isBase64 = isImage || isPDF || isOffice;
+                let isBase64 = isBinary;
 
                 if (isBase64 && typeof content === 'string' && content.includes('base64,')) {
                     content = content.split('base64,')[1];
@@ -1073,7 +1149,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
             reader.onerror = () => reject(new Error(`Erro ao ler ${file.name}`));
 
-            if (isImage || isPDF || isOffice) {
+            if (isBinary) {
                 reader.readAsDataURL(file);
             } else {
                 reader.readAsText(file);
@@ -1335,7 +1411,7 @@ document.addEventListener('DOMContentLoaded', () => {
         if (loading) loading.remove();
     }
 
-    function addMessage(sender, text, messageClass, isMarkdown = false, save = false, isTyping = false) {
+    function addMessage(sender, text, messageClass, isMarkdown = false, save = false, isTyping = false, citations = null) {
         const messageElement = document.createElement('div');
         messageElement.classList.add('message', messageClass);
         const contentElement = document.createElement('div');
@@ -1353,13 +1429,14 @@ document.addEventListener('DOMContentLoaded', () => {
                 cleanHtml = DOMPurify.sanitize(text.replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/\n/g, "<br>"));
             }
             contentElement.innerHTML = `<strong>${sender}:</strong> ${cleanHtml}`;
+            renderCitations(contentElement, citations);
         }
 
         messageElement.appendChild(contentElement);
         messagesDiv.appendChild(messageElement);
         scrollToBottom();
 
-        if (save) saveMessage(sender, text, isMarkdown);
+        if (save) saveMessage(sender, text, isMarkdown, citations);
         if (isMarkdown && !isTyping) {
             messageElement.querySelectorAll('pre code').forEach(block => {
                 hljs.highlightElement(block);
@@ -1407,7 +1484,7 @@ document.addEventListener('DOMContentLoaded', () => {
      * 2. Anima a digitação do texto Markdown/puro nesse contêiner.
      * 3. Ao concluir, substitui o contêiner temporário pelo HTML final renderizado.
      */
-    function addMessageWithTypingEffect(sender, text, messageClass, isMarkdown, save) {
+    function addMessageWithTypingEffect(sender, text, messageClass, isMarkdown, save, citations = null) {
         const messageElement = document.createElement('div');
         messageElement.classList.add('message', messageClass);
         const contentElement = document.createElement('div');
@@ -1468,7 +1545,9 @@ document.addEventListener('DOMContentLoaded', () => {
                 highlightCodeBlocks(contentElement);
             }
 
-            if (save) saveMessage(sender, text, isMarkdown);
+            renderCitations(contentElement, citations);
+
+            if (save) saveMessage(sender, text, isMarkdown, citations);
         };
 
         // Passo 2: Inicia a digitação do texto bruto no contêiner temporário
@@ -1504,15 +1583,121 @@ document.addEventListener('DOMContentLoaded', () => {
         animationFrameId = requestAnimationFrame(type);
     }
 
+    /**
+     * Acrescenta um trecho da resposta em streaming à mensagem parcial do assistente.
+     */
+    function appendStreamingChunk(chunk) {
+        let code = messagesDiv.querySelector('.message.streaming-message code');
+        if (!code) {
+            const messageElement = document.createElement('div');
+            messageElement.classList.add('message', 'assistant-message', 'streaming-message');
+            const contentElement = document.createElement('div');
+            contentElement.classList.add('message-content');
+            contentElement.innerHTML = `<strong>${assistantName}:</strong> `;
+
+            const wrapper = document.createElement('pre');
+            wrapper.style.display = 'inline';
+            wrapper.style.margin = '0';
+            wrapper.style.padding = '0';
+            wrapper.style.background = 'none';
+            wrapper.style.whiteSpace = 'pre-wrap';
+            wrapper.style.wordBreak = 'break-word';
+            code = document.createElement('code');
+            code.style.fontFamily = 'inherit';
+            code.style.background = 'none';
+            code.style.padding = '0';
+            code.classList.add('typing-content');
+
+            wrapper.appendChild(code);
+            contentElement.appendChild(wrapper);
+            messageElement.appendChild(contentElement);
+            messagesDiv.appendChild(messageElement);
+        }
+        code.textContent += chunk;
+        scrollToBottom('auto');
+    }
+
+    /**
+     * Remove a mensagem parcial do streaming, indicando se ela existia.
+     */
+    function removeStreamingMessage() {
+        const streaming = messagesDiv.querySelector('.message.streaming-message');
+        if (streaming) streaming.remove();
+        return !!streaming;
+    }
+
     function removeLastMessageIfTyping() {
         const typingMessage = messagesDiv.querySelector('.message.typing');
         if (typingMessage) messagesDiv.removeChild(typingMessage);
     }
 
-    function saveMessage(sender, text, isMarkdown) {
+    /**
+     * Exibe as fontes citadas pelo provedor abaixo da resposta.
+     */
+    function renderCitations(contentElement, citations) {
+        if (!Array.isArray(citations) || citations.length === 0) return;
+
+        const container = document.createElement('div');
+        container.classList.add('citations');
+
+        const title = document.createElement('div');
+        title.classList.add('citations-title');
+        title.textContent = `📚 Fontes (${citations.length})`;
+        container.appendChild(title);
+
+        const list = document.createElement('ol');
+        citations.forEach(citation => {
+            const item = document.createElement('li');
+            const label = citation.title || citation.url;
+
+            // Apenas links http(s) são clicáveis, evitando esquemas como javascript:
+            if (citation.url && /^https?:\/\//i.test(citation.url)) {
+                const link = document.createElement('a');
+                link.href = citation.url;
+                link.target = '_blank';
+                link.rel = 'noopener noreferrer';
+                link.textContent = label;
+                item.appendChild(link);
+            } else {
+                const span = document.createElement('span');
+                span.textContent = label;
+                item.appendChild(span);
+            }
+
+            if (citation.snippet) {
+                const snippet = document.createElement('blockquote');
+                snippet.classList.add('citation-snippet');
+                snippet.textContent = citation.snippet;
+                item.appendChild(snippet);
+            }
+            list.appendChild(item);
+        });
+        container.appendChild(list);
+        contentElement.appendChild(container);
+    }
+
+    /**
+     * Exibe o consumo de tokens da resposta abaixo da mensagem; contagens estimadas são marcadas com "~".
+     */
+    function renderTokenUsage(messageElement, data) {
+        if (!messageElement || !data.totalTokens) return;
+
+        const usage = document.createElement('div');
+        usage.classList.add('token-usage');
+        const prefix = data.tokensEstimated ? '~' : '';
+        usage.textContent = `${prefix}${data.totalTokens} tokens (entrada: ${prefix}${data.promptTokens || 0}, saída: ${prefix}${data.completionTokens || 0})`;
+        if (data.tokensEstimated) {
+            usage.title = 'Contagem estimada: o provedor não informa o consumo de tokens';
+        }
+        messageElement.appendChild(usage);
+    }
+
+    function saveMessage(sender, text, isMarkdown, citations = null) {
         if (!currentChatID) return;
         const history = JSON.parse(localStorage.getItem(currentChatID)) || [];
-        history.push({ sender, text, isMarkdown });
+        const entry = { sender, text, isMarkdown };
+        if (Array.isArray(citations) && citations.length > 0) entry.citations = citations;
+        history.push(entry);
         localStorage.setItem(currentChatID, JSON.stringify(history));
     }
 
@@ -1522,7 +1707,7 @@ document.addEventListener('DOMContentLoaded', () => {
         history.forEach(msg => {
             const messageClass = msg.sender === 'Você' ? 'user-message' :
                 (msg.sender === 'Sistema' ? 'system-message' : 'assistant-message');
-            addMessage(msg.sender, msg.text, messageClass, msg.isMarkdown, false);
+            addMessage(msg.sender, msg.text, messageClass, msg.isMarkdown, false, false, msg.citations);
         });
         localStorage.setItem('currentChatID', currentChatID);
         loadChatList();
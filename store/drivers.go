@@ -0,0 +1,17 @@
+package store
+
+// O backend sql usa database/sql, mas este módulo não depende de nenhum driver: o binário
+// padrão registra só os drivers importados em outro lugar, e CONVERSATION_STORE=sql falha na
+// inicialização listando os registrados. Para habilitar um banco, adicione a dependência e um
+// arquivo neste pacote com o import em branco, de preferência atrás de uma build tag, por
+// exemplo store/driver_postgres.go:
+//
+//	//go:build postgres
+//
+//	package store
+//
+//	import _ "github.com/jackc/pgx/v5/stdlib" // registra o driver "pgx"
+//
+// e compile com "go get github.com/jackc/pgx/v5 && go build -tags postgres". O mesmo vale para
+// SQLite ("modernc.org/sqlite", driver "sqlite") e MySQL ("github.com/go-sql-driver/mysql",
+// driver "mysql").
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/webchatcomllm/models"
+)
+
+// FileStore grava cada sessão em um arquivo JSON no diretório configurado, mantendo o histórico
+// entre reinícios sem depender de um banco. Não é compartilhado entre instâncias. Ao exceder
+// maxSessions, os arquivos modificados há mais tempo são removidos.
+type FileStore struct {
+	mu          sync.Mutex
+	dir         string
+	maxSessions int
+	limits      SessionLimits
+}
+
+// NewFileStore cria o diretório, se necessário, e o armazenamento em arquivos; maxSessions <= 0
+// não limita as sessões.
+func NewFileStore(dir string, maxSessions int, limits SessionLimits) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("CONVERSATION_STORE_DIR é obrigatório para o backend file")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório de conversas: %w", err)
+	}
+	return &FileStore{dir: dir, maxSessions: maxSessions, limits: limits}, nil
+}
+
+func (s *FileStore) Append(_ context.Context, sessionID string, msgs ...models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.read(sessionID)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	if err := s.write(sessionID, s.limits.trim(append(current, msgs...))); err != nil {
+		return err
+	}
+	if errors.Is(err, ErrSessionNotFound) {
+		s.evict(sessionID)
+	}
+	return nil
+}
+
+func (s *FileStore) Save(_ context.Context, sessionID string, msgs []models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(sessionID)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(path)
+	if err := s.write(sessionID, s.limits.trim(msgs)); err != nil {
+		return err
+	}
+	if errors.Is(statErr, fs.ErrNotExist) {
+		s.evict(sessionID)
+	}
+	return nil
+}
+
+func (s *FileStore) Load(_ context.Context, sessionID string) ([]models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(sessionID)
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// evict remove os arquivos de sessão modificados há mais tempo até respeitar maxSessions,
+// preservando a sessão recém-criada. Deve ser chamado com mu travado.
+func (s *FileStore) evict(keep string) {
+	if s.maxSessions <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	type sessionFile struct {
+		name     string
+		modified time.Time
+	}
+	var files []sessionFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || name == keep+".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, sessionFile{name: name, modified: info.ModTime()})
+	}
+	excess := len(files) + 1 - s.maxSessions
+	if excess <= 0 {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modified.Before(files[j].modified) })
+	for _, f := range files[:excess] {
+		os.Remove(filepath.Join(s.dir, f.name))
+	}
+}
+
+// path retorna o arquivo da sessão. Os IDs aceitos por ValidSessionID são nomes de arquivo seguros.
+func (s *FileStore) path(sessionID string) (string, error) {
+	if !ValidSessionID(sessionID) {
+		return "", fmt.Errorf("ID de sessão inválido: %q", sessionID)
+	}
+	return filepath.Join(s.dir, sessionID+".json"), nil
+}
+
+func (s *FileStore) read(sessionID string) ([]models.Message, error) {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler sessão: %w", err)
+	}
+	var msgs []models.Message
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar sessão: %w", err)
+	}
+	return msgs, nil
+}
+
+// write grava em um arquivo temporário e o renomeia, para que uma falha no meio da escrita não
+// corrompa a sessão gravada.
+func (s *FileStore) write(sessionID string, msgs []models.Message) error {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return err
+	}
+	if msgs == nil {
+		msgs = []models.Message{}
+	}
+	data, err := json.Marshal(msgs)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar sessão: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, sessionID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("erro ao gravar sessão: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro ao gravar sessão: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("erro ao gravar sessão: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("erro ao gravar sessão: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import "github.com/webchatcomllm/models"
+
+// SessionLimits limita o tamanho de cada sessão gravada. Ao exceder um dos limites, os turnos
+// mais antigos são descartados. Valores <= 0 não limitam.
+type SessionLimits struct {
+	// MaxMessages é o número máximo de mensagens por sessão
+	MaxMessages int
+	// MaxBytes é o tamanho máximo do conteúdo somado das mensagens da sessão
+	MaxBytes int
+}
+
+// sessionEntry resume uma mensagem gravada para o cálculo do descarte.
+type sessionEntry struct {
+	role string
+	size int
+}
+
+// Size retorna o número de mensagens e o tamanho do conteúdo somado de uma sessão.
+func Size(msgs []models.Message) (messages, bytes int) {
+	for _, msg := range msgs {
+		bytes += len(msg.Content)
+	}
+	return len(msgs), bytes
+}
+
+// trim descarta os turnos mais antigos de msgs até respeitar os limites.
+func (l SessionLimits) trim(msgs []models.Message) []models.Message {
+	entries := make([]sessionEntry, len(msgs))
+	for i, msg := range msgs {
+		entries[i] = sessionEntry{role: msg.Role, size: len(msg.Content)}
+	}
+	if n := l.dropCount(entries); n > 0 {
+		return append([]models.Message(nil), msgs[n:]...)
+	}
+	return msgs
+}
+
+// dropCount retorna quantas mensagens do início devem ser descartadas para respeitar os
+// limites. O descarte avança até a próxima mensagem do usuário, para que a sessão continue
+// começando em um turno completo, e nunca alcança o último turno.
+func (l SessionLimits) dropCount(entries []sessionEntry) int {
+	if l.MaxMessages <= 0 && l.MaxBytes <= 0 {
+		return 0
+	}
+
+	lastTurn := len(entries) - 1
+	for lastTurn > 0 && entries[lastTurn].role != "user" {
+		lastTurn--
+	}
+
+	count, bytes := len(entries), 0
+	for _, e := range entries {
+		bytes += e.size
+	}
+	n := 0
+	for n < lastTurn && ((l.MaxMessages > 0 && count > l.MaxMessages) || (l.MaxBytes > 0 && bytes > l.MaxBytes)) {
+		count--
+		bytes -= entries[n].size
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	for n < lastTurn && entries[n].role != "user" {
+		n++
+	}
+	return n
+}
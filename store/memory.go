@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/webchatcomllm/models"
+)
+
+// MemoryStore mantém as sessões em memória. Ao exceder maxSessions, a sessão atualizada há
+// mais tempo é descartada. O conteúdo é perdido ao reiniciar o servidor.
+type MemoryStore struct {
+	mu          sync.Mutex
+	sessions    map[string]*memorySession
+	maxSessions int
+	limits      SessionLimits
+}
+
+type memorySession struct {
+	messages []models.Message
+	updated  time.Time
+}
+
+// NewMemoryStore cria o armazenamento em memória; maxSessions <= 0 não limita as sessões.
+func NewMemoryStore(maxSessions int, limits SessionLimits) *MemoryStore {
+	return &MemoryStore{
+		sessions:    make(map[string]*memorySession),
+		maxSessions: maxSessions,
+		limits:      limits,
+	}
+}
+
+func (s *MemoryStore) Append(_ context.Context, sessionID string, msgs ...models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		session = &memorySession{}
+		s.sessions[sessionID] = session
+		s.evict()
+	}
+	session.messages = s.limits.trim(append(session.messages, msgs...))
+	session.updated = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, sessionID string, msgs []models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.sessions[sessionID]
+	s.sessions[sessionID] = &memorySession{
+		messages: s.limits.trim(append([]models.Message(nil), msgs...)),
+		updated:  time.Now(),
+	}
+	if !exists {
+		s.evict()
+	}
+	return nil
+}
+
+func (s *MemoryStore) Load(_ context.Context, sessionID string) ([]models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return append([]models.Message(nil), session.messages...), nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// evict descarta as sessões menos recentes até respeitar maxSessions. Deve ser chamado com mu travado.
+func (s *MemoryStore) evict() {
+	for s.maxSessions > 0 && len(s.sessions) > s.maxSessions {
+		var oldestID string
+		var oldest time.Time
+		for id, session := range s.sessions {
+			// Sessões recém-criadas ainda não têm updated e nunca são as escolhidas
+			if session.updated.IsZero() {
+				continue
+			}
+			if oldestID == "" || session.updated.Before(oldest) {
+				oldestID, oldest = id, session.updated
+			}
+		}
+		if oldestID == "" {
+			return
+		}
+		delete(s.sessions, oldestID)
+	}
+}
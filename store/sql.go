@@ -0,0 +1,315 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/webchatcomllm/models"
+	"go.uber.org/zap"
+)
+
+// migrations são aplicadas em ordem; o índice + 1 é a versão registrada em schema_migrations.
+// Novas versões devem ser acrescentadas ao fim, nunca alteradas.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS conversation_messages (
+		session_id VARCHAR(128) NOT NULL,
+		seq INTEGER NOT NULL,
+		role VARCHAR(32) NOT NULL,
+		content TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (session_id, seq)
+	)`,
+	`ALTER TABLE conversation_messages ADD COLUMN message_id VARCHAR(128) NOT NULL DEFAULT ''`,
+	`ALTER TABLE conversation_messages ADD COLUMN parent_id VARCHAR(128) NOT NULL DEFAULT ''`,
+}
+
+// SQLStore grava as sessões em um banco SQL via database/sql, permitindo histórico durável
+// e compartilhado entre instâncias. As consultas usam apenas SQL comum a SQLite, PostgreSQL e MySQL.
+type SQLStore struct {
+	db          *sql.DB
+	driver      string
+	maxSessions int
+	limits      SessionLimits
+	logger      *zap.Logger
+}
+
+// OpenSQLStore abre o banco, configura o pool de conexões e aplica as migrações pendentes.
+func OpenSQLStore(cfg Config, logger *zap.Logger) (*SQLStore, error) {
+	if cfg.Driver == "" || cfg.DSN == "" {
+		return nil, errors.New("CONVERSATION_STORE_DRIVER e CONVERSATION_STORE_DSN são obrigatórios para o backend sql")
+	}
+	// O binário não importa nenhum driver; veja a documentação do pacote em drivers.go
+	if !slices.Contains(sql.Drivers(), cfg.Driver) {
+		return nil, fmt.Errorf("driver SQL %q não registrado no binário (registrados: %v); adicione o import do driver conforme store/drivers.go", cfg.Driver, sql.Drivers())
+	}
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir banco de conversas: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("erro ao conectar ao banco de conversas: %w", err)
+	}
+
+	s := &SQLStore{db: db, driver: cfg.Driver, maxSessions: cfg.MaxSessions, limits: cfg.SessionLimits(), logger: logger}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	logger.Info("Armazenamento de conversas em banco SQL",
+		zap.String("driver", cfg.Driver),
+		zap.Int("max_open_conns", cfg.MaxOpenConns),
+	)
+	return s, nil
+}
+
+// migrate cria a tabela de controle e aplica as migrações ainda não registradas.
+func (s *SQLStore) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("erro ao criar schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("erro ao ler versão do schema: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, s.query(`INSERT INTO schema_migrations (version) VALUES (?)`), version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("erro ao aplicar migração %d: %w", version, err)
+		}
+		s.logger.Info("Migração do banco de conversas aplicada", zap.Int("version", version))
+	}
+	return nil
+}
+
+// appendAttempts limita as tentativas de Append quando outra gravação concorrente na mesma
+// sessão ocupa a sequência lida.
+const appendAttempts = 5
+
+// Append lê a última sequência da sessão e grava as mensagens a partir dela. Duas gravações
+// concorrentes podem ler a mesma sequência; a segunda viola a chave primária e é repetida.
+func (s *SQLStore) Append(ctx context.Context, sessionID string, msgs ...models.Message) error {
+	var err error
+	for attempt := 1; attempt <= appendAttempts; attempt++ {
+		err = s.inTx(ctx, func(tx *sql.Tx) error {
+			var last int
+			if err := tx.QueryRowContext(ctx, s.query(`SELECT COALESCE(MAX(seq), 0) FROM conversation_messages WHERE session_id = ?`), sessionID).Scan(&last); err != nil {
+				return err
+			}
+			if err := s.insert(ctx, tx, sessionID, last, msgs); err != nil {
+				return err
+			}
+			if last == 0 {
+				if err := s.evict(ctx, tx, sessionID); err != nil {
+					return err
+				}
+			}
+			return s.trim(ctx, tx, sessionID)
+		})
+		if err == nil || !isConflict(err) || ctx.Err() != nil {
+			return err
+		}
+		s.logger.Debug("Conflito ao gravar na sessão, repetindo",
+			zap.String("session_id", sessionID),
+			zap.Int("attempt", attempt),
+		)
+		time.Sleep(time.Duration(attempt) * 10 * time.Millisecond)
+	}
+	return err
+}
+
+// isConflict reconhece, pela mensagem, as violações de chave única e as falhas de serialização
+// dos drivers mais comuns, que database/sql não padroniza.
+func isConflict(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, marker := range []string{"unique", "duplicate", "constraint", "serializ", "deadlock", "database is locked"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SQLStore) Save(ctx context.Context, sessionID string, msgs []models.Message) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, s.query(`DELETE FROM conversation_messages WHERE session_id = ?`), sessionID)
+		if err != nil {
+			return err
+		}
+		if err := s.insert(ctx, tx, sessionID, 0, msgs); err != nil {
+			return err
+		}
+		if deleted, err := res.RowsAffected(); err == nil && deleted == 0 {
+			if err := s.evict(ctx, tx, sessionID); err != nil {
+				return err
+			}
+		}
+		return s.trim(ctx, tx, sessionID)
+	})
+}
+
+func (s *SQLStore) Load(ctx context.Context, sessionID string) ([]models.Message, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(`SELECT role, content, message_id, parent_id FROM conversation_messages WHERE session_id = ? ORDER BY seq`), sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar sessão: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []models.Message
+	for rows.Next() {
+		var msg models.Message
+		if err := rows.Scan(&msg.Role, &msg.Content, &msg.ID, &msg.ParentID); err != nil {
+			return nil, fmt.Errorf("erro ao ler mensagem da sessão: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao carregar sessão: %w", err)
+	}
+	if len(msgs) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return msgs, nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// insert grava as mensagens a partir da sequência after + 1.
+func (s *SQLStore) insert(ctx context.Context, tx *sql.Tx, sessionID string, after int, msgs []models.Message) error {
+	stmt := s.query(`INSERT INTO conversation_messages (session_id, seq, role, content, message_id, parent_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	now := time.Now().UTC()
+	for i, msg := range msgs {
+		if _, err := tx.ExecContext(ctx, stmt, sessionID, after+i+1, msg.Role, msg.Content, msg.ID, msg.ParentID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evict remove as sessões com a mensagem mais recente mais antiga até respeitar maxSessions,
+// preservando a sessão recém-criada.
+func (s *SQLStore) evict(ctx context.Context, tx *sql.Tx, keep string) error {
+	if s.maxSessions <= 0 {
+		return nil
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(DISTINCT session_id) FROM conversation_messages`).Scan(&count); err != nil {
+		return err
+	}
+	excess := count - s.maxSessions
+	if excess <= 0 {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, s.query(`SELECT session_id FROM conversation_messages WHERE session_id <> ? GROUP BY session_id ORDER BY MAX(created_at), session_id LIMIT ?`), keep, excess)
+	if err != nil {
+		return err
+	}
+	var oldest []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		oldest = append(oldest, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range oldest {
+		if _, err := tx.ExecContext(ctx, s.query(`DELETE FROM conversation_messages WHERE session_id = ?`), id); err != nil {
+			return err
+		}
+	}
+	s.logger.Debug("Sessões antigas descartadas", zap.Int("sessions", len(oldest)))
+	return nil
+}
+
+// trim remove as mensagens mais antigas da sessão que excedem os limites por sessão.
+func (s *SQLStore) trim(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	if s.limits.MaxMessages <= 0 && s.limits.MaxBytes <= 0 {
+		return nil
+	}
+	rows, err := tx.QueryContext(ctx, s.query(`SELECT seq, role, LENGTH(content) FROM conversation_messages WHERE session_id = ? ORDER BY seq`), sessionID)
+	if err != nil {
+		return err
+	}
+	var seqs []int
+	var entries []sessionEntry
+	for rows.Next() {
+		var seq int
+		var e sessionEntry
+		if err := rows.Scan(&seq, &e.role, &e.size); err != nil {
+			rows.Close()
+			return err
+		}
+		seqs = append(seqs, seq)
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	n := s.limits.dropCount(entries)
+	if n == 0 {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, s.query(`DELETE FROM conversation_messages WHERE session_id = ? AND seq <= ?`), sessionID, seqs[n-1])
+	return err
+}
+
+// inTx executa fn em uma transação, desfazendo-a em caso de erro.
+func (s *SQLStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// query adapta os marcadores "?" para "$n" nos drivers do PostgreSQL.
+func (s *SQLStore) query(q string) string {
+	if s.driver != "postgres" && s.driver != "pgx" {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
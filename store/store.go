@@ -0,0 +1,251 @@
+// Package store persiste o histórico das conversas fora do navegador.
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/webchatcomllm/config"
+	"github.com/webchatcomllm/models"
+	"go.uber.org/zap"
+)
+
+// Backends disponíveis em CONVERSATION_STORE
+const (
+	BackendMemory = "memory"
+	BackendFile   = "file"
+	BackendSQL    = "sql"
+)
+
+// ErrSessionNotFound indica que não há mensagens gravadas para a sessão.
+var ErrSessionNotFound = errors.New("sessão não encontrada")
+
+// ConversationStore grava e recupera as mensagens de uma sessão de conversa.
+type ConversationStore interface {
+	// Append acrescenta mensagens ao fim da sessão, criando-a se necessário.
+	Append(ctx context.Context, sessionID string, msgs ...models.Message) error
+	// Save substitui todas as mensagens da sessão.
+	Save(ctx context.Context, sessionID string, msgs []models.Message) error
+	// Load retorna as mensagens da sessão, em ordem, ou ErrSessionNotFound.
+	Load(ctx context.Context, sessionID string) ([]models.Message, error)
+	Close() error
+}
+
+// Config define o backend e os limites do armazenamento de conversas.
+type Config struct {
+	Backend string
+	// MaxSessions limita as sessões gravadas, em todos os backends; as menos recentes são
+	// descartadas
+	MaxSessions int
+	// MaxSessionMessages e MaxSessionBytes limitam cada sessão em todos os backends; os turnos
+	// mais antigos são descartados ao gravar
+	MaxSessionMessages int
+	MaxSessionBytes    int
+
+	// Dir é o diretório dos arquivos de sessão do backend file
+	Dir string
+
+	// Driver e DSN do banco usado pelo backend SQL. O driver precisa estar registrado no
+	// binário (import em branco do pacote do driver).
+	Driver string
+	DSN    string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// SessionSecret assina os IDs de sessão emitidos. Nos backends file e sql, deve ser fixo
+	// para que as sessões continuem acessíveis após reinícios
+	SessionSecret string
+}
+
+// DefaultConfig retorna a configuração padrão: sessões em memória.
+func DefaultConfig() Config {
+	return Config{
+		Backend:            BackendMemory,
+		MaxSessions:        1000,
+		MaxSessionMessages: 500,
+		MaxSessionBytes:    1 << 20,
+		Dir:                "data/conversations",
+		MaxOpenConns:       10,
+		MaxIdleConns:       5,
+		ConnMaxLifetime:    30 * time.Minute,
+	}
+}
+
+// LoadConfig carrega a configuração a partir das variáveis de ambiente.
+func LoadConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Backend = strings.ToLower(config.GetEnvString("CONVERSATION_STORE", cfg.Backend))
+	cfg.MaxSessions = config.GetEnvInt("CONVERSATION_STORE_MAX_SESSIONS", cfg.MaxSessions)
+	cfg.MaxSessionMessages = config.GetEnvInt("CONVERSATION_STORE_MAX_SESSION_MESSAGES", cfg.MaxSessionMessages)
+	cfg.MaxSessionBytes = config.GetEnvInt("CONVERSATION_STORE_MAX_SESSION_BYTES", cfg.MaxSessionBytes)
+	cfg.Dir = config.GetEnvString("CONVERSATION_STORE_DIR", cfg.Dir)
+	cfg.Driver = config.GetEnvString("CONVERSATION_STORE_DRIVER", cfg.Driver)
+	cfg.DSN = config.GetEnvString("CONVERSATION_STORE_DSN", cfg.DSN)
+	cfg.MaxOpenConns = config.GetEnvInt("CONVERSATION_STORE_MAX_OPEN_CONNS", cfg.MaxOpenConns)
+	cfg.MaxIdleConns = config.GetEnvInt("CONVERSATION_STORE_MAX_IDLE_CONNS", cfg.MaxIdleConns)
+	cfg.ConnMaxLifetime = config.GetEnvDuration("CONVERSATION_STORE_CONN_MAX_LIFETIME", cfg.ConnMaxLifetime)
+	cfg.SessionSecret = config.GetEnvString("CONVERSATION_STORE_SESSION_SECRET", cfg.SessionSecret)
+	return cfg
+}
+
+// SessionLimits retorna os limites por sessão da configuração.
+func (cfg Config) SessionLimits() SessionLimits {
+	return SessionLimits{MaxMessages: cfg.MaxSessionMessages, MaxBytes: cfg.MaxSessionBytes}
+}
+
+// New cria o armazenamento conforme cfg.Backend.
+func New(cfg Config, logger *zap.Logger) (ConversationStore, error) {
+	ConfigureSessionSecret(cfg.SessionSecret)
+	if cfg.SessionSecret == "" && cfg.Backend != "" && cfg.Backend != BackendMemory {
+		logger.Warn("CONVERSATION_STORE_SESSION_SECRET não definido: as sessões gravadas deixam de ser acessíveis após reiniciar")
+	}
+
+	switch cfg.Backend {
+	case "", BackendMemory:
+		logger.Info("Armazenamento de conversas em memória",
+			zap.Int("max_sessions", cfg.MaxSessions),
+			zap.Int("max_session_messages", cfg.MaxSessionMessages),
+			zap.Int("max_session_bytes", cfg.MaxSessionBytes),
+		)
+		return NewMemoryStore(cfg.MaxSessions, cfg.SessionLimits()), nil
+	case BackendFile:
+		logger.Info("Armazenamento de conversas em arquivos", zap.String("dir", cfg.Dir), zap.Int("max_sessions", cfg.MaxSessions))
+		return NewFileStore(cfg.Dir, cfg.MaxSessions, cfg.SessionLimits())
+	case BackendSQL:
+		return OpenSQLStore(cfg, logger)
+	default:
+		return nil, fmt.Errorf("backend de conversas desconhecido: %q (use %q, %q ou %q)", cfg.Backend, BackendMemory, BackendFile, BackendSQL)
+	}
+}
+
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// ValidSessionID indica se o ID de sessão tem formato aceito (até 128 letras, dígitos, "-" ou "_").
+func ValidSessionID(sessionID string) bool {
+	return sessionIDPattern.MatchString(sessionID)
+}
+
+// ValidMessageID indica se o ID de mensagem tem o mesmo formato aceito nos IDs de sessão.
+func ValidMessageID(messageID string) bool {
+	return sessionIDPattern.MatchString(messageID)
+}
+
+// NewMessageID gera um ID de mensagem aleatório (32 caracteres hexadecimais), único e
+// imprevisível.
+func NewMessageID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("erro ao gerar ID de mensagem: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Branch retorna o ramo da conversa que termina na mensagem leafID (vazio usa a última),
+// seguindo ParentID até o início. Mensagens sem ParentID continuam pela mensagem gravada
+// anterior, o que mantém o histórico linear das sessões sem ramificações. Retorna false se
+// leafID não for encontrado.
+func Branch(msgs []models.Message, leafID string) ([]models.Message, bool) {
+	if len(msgs) == 0 {
+		return nil, leafID == ""
+	}
+	index := make(map[string]int, len(msgs))
+	for i, msg := range msgs {
+		if msg.ID != "" {
+			index[msg.ID] = i
+		}
+	}
+
+	i := len(msgs) - 1
+	if leafID != "" {
+		var ok bool
+		if i, ok = index[leafID]; !ok {
+			return nil, false
+		}
+	}
+
+	var branch []models.Message
+	for steps := 0; i >= 0 && steps < len(msgs); steps++ {
+		branch = append(branch, msgs[i])
+		parent, ok := index[msgs[i].ParentID]
+		if msgs[i].ParentID == "" || !ok || parent >= i {
+			i--
+			continue
+		}
+		i = parent
+	}
+	for l, r := 0, len(branch)-1; l < r; l, r = l+1, r-1 {
+		branch[l], branch[r] = branch[r], branch[l]
+	}
+	return branch, true
+}
+
+// sessionSecret assina os IDs de sessão emitidos pelo servidor. Sem
+// CONVERSATION_STORE_SESSION_SECRET, é gerado na inicialização e os IDs emitidos antes de um
+// reinício deixam de ser aceitos.
+var (
+	sessionSecretMu sync.RWMutex
+	sessionSecret   = randomSecret()
+)
+
+func randomSecret() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("erro ao gerar segredo das sessões: %v", err))
+	}
+	return b
+}
+
+// ConfigureSessionSecret define o segredo que assina os IDs de sessão; vazio mantém o segredo
+// gerado na inicialização.
+func ConfigureSessionSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	sessionSecretMu.Lock()
+	sessionSecret = []byte(secret)
+	sessionSecretMu.Unlock()
+}
+
+// sessionSignature retorna a assinatura (32 caracteres hexadecimais) da parte aleatória de um ID.
+func sessionSignature(random string) string {
+	sessionSecretMu.RLock()
+	mac := hmac.New(sha256.New, sessionSecret)
+	sessionSecretMu.RUnlock()
+	mac.Write([]byte(random))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// NewSessionID gera um ID de sessão emitido pelo servidor: 32 caracteres hexadecimais
+// aleatórios seguidos da sua assinatura, 64 no total. Só IDs emitidos são aceitos nas rotas de
+// sessão, e quem conhece o ID é o dono da sessão.
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("erro ao gerar ID de sessão: %w", err)
+	}
+	random := hex.EncodeToString(b)
+	return random + sessionSignature(random), nil
+}
+
+// IssuedSessionID indica se o ID foi emitido por NewSessionID com o segredo atual.
+func IssuedSessionID(sessionID string) bool {
+	if len(sessionID) != 64 {
+		return false
+	}
+	random, signature := sessionID[:32], sessionID[32:]
+	if _, err := hex.DecodeString(random); err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(sessionSignature(random)))
+}
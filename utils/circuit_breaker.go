@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/webchatcomllm/config"
+	"github.com/webchatcomllm/metrics"
 )
 
 type CircuitState int
@@ -13,21 +17,95 @@ const (
 	CircuitHalfOpen
 )
 
+var (
+	circuitTransitionsTotal = metrics.Default.Counter("circuit_breaker_transitions_total", "Mudanças de estado dos circuit breakers, pelo estado de destino")
+	circuitState            = metrics.Default.Gauge("circuit_breaker_state", "Estado do circuit breaker de cada provedor: 0 fechado, 1 aberto, 2 half-open")
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig define a sensibilidade do circuit breaker.
+type CircuitBreakerConfig struct {
+	// FailureThreshold é o número de falhas consecutivas que abre o circuito
+	FailureThreshold int
+	// ResetTimeout é o tempo em aberto antes de permitir novas tentativas (half-open)
+	ResetTimeout time.Duration
+	// HalfOpenSuccesses é o número de sucessos em half-open necessários para fechar o circuito
+	HalfOpenSuccesses int
+}
+
+// DefaultCircuitBreakerConfig retorna a configuração padrão do circuit breaker.
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold:  5,
+		ResetTimeout:      time.Minute,
+		HalfOpenSuccesses: 3,
+	}
+}
+
+// LoadCircuitBreakerConfig lê a configuração das variáveis CIRCUIT_BREAKER_THRESHOLD,
+// CIRCUIT_BREAKER_RESET_TIMEOUT e CIRCUIT_BREAKER_HALF_OPEN_SUCCESSES. Com um provedor
+// informado, as variáveis prefixadas (ex.: OPENAI_CIRCUIT_BREAKER_THRESHOLD) têm precedência.
+func LoadCircuitBreakerConfig(provider string) CircuitBreakerConfig {
+	cfg := DefaultCircuitBreakerConfig()
+	prefixes := []string{""}
+	if provider != "" {
+		prefixes = append(prefixes, strings.ToUpper(provider)+"_")
+	}
+	for _, prefix := range prefixes {
+		cfg.FailureThreshold = config.GetEnvInt(prefix+"CIRCUIT_BREAKER_THRESHOLD", cfg.FailureThreshold)
+		cfg.ResetTimeout = config.GetEnvDuration(prefix+"CIRCUIT_BREAKER_RESET_TIMEOUT", cfg.ResetTimeout)
+		cfg.HalfOpenSuccesses = config.GetEnvInt(prefix+"CIRCUIT_BREAKER_HALF_OPEN_SUCCESSES", cfg.HalfOpenSuccesses)
+	}
+	return cfg
+}
+
 type CircuitBreaker struct {
+	// provider identifica o breaker no gauge circuit_breaker_state; vazio não é exportado
+	provider     string
 	mu           sync.RWMutex
 	state        CircuitState
 	failureCount int
 	successCount int
-	threshold    int
-	timeout      time.Duration
+	config       CircuitBreakerConfig
 	nextAttempt  time.Time
 }
 
+// NewCircuitBreaker cria um circuit breaker com o limite e o timeout informados e o
+// número padrão de sucessos em half-open.
 func NewCircuitBreaker(threshold int, timeout time.Duration) *CircuitBreaker {
+	cfg := DefaultCircuitBreakerConfig()
+	cfg.FailureThreshold = threshold
+	cfg.ResetTimeout = timeout
+	return NewCircuitBreakerWithConfig(cfg)
+}
+
+// NewCircuitBreakerWithConfig cria um circuit breaker; valores não positivos usam o padrão.
+func NewCircuitBreakerWithConfig(cfg CircuitBreakerConfig) *CircuitBreaker {
+	defaults := DefaultCircuitBreakerConfig()
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaults.FailureThreshold
+	}
+	if cfg.ResetTimeout <= 0 {
+		cfg.ResetTimeout = defaults.ResetTimeout
+	}
+	if cfg.HalfOpenSuccesses <= 0 {
+		cfg.HalfOpenSuccesses = defaults.HalfOpenSuccesses
+	}
 	return &CircuitBreaker{
-		state:     CircuitClosed,
-		threshold: threshold,
-		timeout:   timeout,
+		state:  CircuitClosed,
+		config: cfg,
 	}
 }
 
@@ -41,7 +119,7 @@ func (cb *CircuitBreaker) Allow() bool {
 
 	case CircuitOpen:
 		if time.Now().After(cb.nextAttempt) {
-			cb.state = CircuitHalfOpen
+			cb.setState(CircuitHalfOpen)
 			cb.successCount = 0
 			return true
 		}
@@ -63,8 +141,8 @@ func (cb *CircuitBreaker) RecordSuccess() {
 
 	if cb.state == CircuitHalfOpen {
 		cb.successCount++
-		if cb.successCount >= 3 {
-			cb.state = CircuitClosed
+		if cb.successCount >= cb.config.HalfOpenSuccesses {
+			cb.setState(CircuitClosed)
 		}
 	}
 }
@@ -76,14 +154,35 @@ func (cb *CircuitBreaker) RecordFailure() {
 	cb.failureCount++
 
 	if cb.state == CircuitHalfOpen {
-		cb.state = CircuitOpen
-		cb.nextAttempt = time.Now().Add(cb.timeout)
+		cb.setState(CircuitOpen)
+		cb.nextAttempt = time.Now().Add(cb.config.ResetTimeout)
 		return
 	}
 
-	if cb.failureCount >= cb.threshold {
-		cb.state = CircuitOpen
-		cb.nextAttempt = time.Now().Add(cb.timeout)
+	if cb.failureCount >= cb.config.FailureThreshold {
+		cb.setState(CircuitOpen)
+		cb.nextAttempt = time.Now().Add(cb.config.ResetTimeout)
+	}
+}
+
+// Trip abre o circuito imediatamente por d, independente da contagem de falhas (ex.: manutenção
+// anunciada pelo provedor).
+func (cb *CircuitBreaker) Trip(d time.Duration) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.setState(CircuitOpen)
+	cb.nextAttempt = time.Now().Add(d)
+}
+
+// setState troca o estado e contabiliza a transição; deve ser chamado com cb.mu travado.
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	if cb.state == state {
+		return
+	}
+	cb.state = state
+	circuitTransitionsTotal.Inc(metrics.Labels{"to": state.String()})
+	if cb.provider != "" {
+		circuitState.Set(float64(state), metrics.Labels{"provider": cb.provider})
 	}
 }
 
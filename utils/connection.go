@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -28,7 +29,9 @@ type ConnectionConfig struct {
 	PongTimeout          time.Duration
 	WriteTimeout         time.Duration
 	ReadTimeout          time.Duration
+	SendTimeout          time.Duration
 	MessageQueueSize     int
+	CircuitBreaker       CircuitBreakerConfig
 }
 
 func DefaultConnectionConfig() ConnectionConfig {
@@ -40,19 +43,26 @@ func DefaultConnectionConfig() ConnectionConfig {
 		PongTimeout:          120 * time.Second,
 		WriteTimeout:         45 * time.Second,
 		ReadTimeout:          120 * time.Second,
+		SendTimeout:          5 * time.Second,
 		MessageQueueSize:     1000,
+		CircuitBreaker:       DefaultCircuitBreakerConfig(),
 	}
 }
 
+// ManagedConnection envolve a conexão WebSocket. Toda escrita passa por WriteMessage, que as
+// serializa: *websocket.Conn não aceita escritas concorrentes.
 type ManagedConnection struct {
 	Conn           *websocket.Conn
 	state          ConnectionState
 	stateMu        sync.RWMutex
+	writeMu        sync.Mutex
+	closeOnce      sync.Once
 	config         ConnectionConfig
 	logger         *zap.Logger
 	SendQueue      chan []byte
 	reconnectCount int
-	lastPong       time.Time
+	// lastPong (UnixNano) é gravado pelo leitor da conexão e lido pelo health check
+	lastPong       atomic.Int64
 	ctx            context.Context
 	cancel         context.CancelFunc
 	onStateChange  func(ConnectionState)
@@ -62,27 +72,28 @@ type ManagedConnection struct {
 func NewManagedConnection(logger *zap.Logger, config ConnectionConfig) *ManagedConnection {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &ManagedConnection{
+	mc := &ManagedConnection{
 		config:         config,
 		logger:         logger,
 		SendQueue:      make(chan []byte, config.MessageQueueSize),
 		state:          StateDisconnected,
 		ctx:            ctx,
 		cancel:         cancel,
-		lastPong:       time.Now(),
-		circuitBreaker: NewCircuitBreaker(5, time.Minute),
+		circuitBreaker: NewCircuitBreakerWithConfig(config.CircuitBreaker),
 	}
+	mc.lastPong.Store(time.Now().UnixNano())
+	return mc
 }
 
 func (mc *ManagedConnection) SetConnection(conn *websocket.Conn) {
 	mc.Conn = conn
 	mc.setState(StateConnected)
 	mc.reconnectCount = 0
-	mc.lastPong = time.Now()
+	mc.lastPong.Store(time.Now().UnixNano())
 
 	mc.Conn.SetReadDeadline(time.Now().Add(mc.config.ReadTimeout))
 	mc.Conn.SetPongHandler(func(string) error {
-		mc.lastPong = time.Now()
+		mc.lastPong.Store(time.Now().UnixNano())
 		mc.Conn.SetReadDeadline(time.Now().Add(mc.config.ReadTimeout))
 		return nil
 	})
@@ -122,7 +133,7 @@ func (mc *ManagedConnection) Send(data []byte) error {
 	select {
 	case mc.SendQueue <- data:
 		return nil
-	case <-time.After(5 * time.Second):
+	case <-time.After(mc.config.SendTimeout):
 		return ErrSendTimeout
 	case <-mc.ctx.Done():
 		return ErrConnectionClosed
@@ -140,7 +151,7 @@ func (mc *ManagedConnection) StartHealthCheck() {
 				continue
 			}
 
-			if time.Since(mc.lastPong) > mc.config.PongTimeout {
+			if time.Since(time.Unix(0, mc.lastPong.Load())) > mc.config.PongTimeout {
 				mc.logger.Warn("Pong timeout detected, connection may be dead")
 				mc.circuitBreaker.RecordFailure()
 				continue
@@ -160,23 +171,39 @@ func (mc *ManagedConnection) StartHealthCheck() {
 }
 
 func (mc *ManagedConnection) sendPing() error {
+	return mc.WriteMessage(websocket.PingMessage, nil)
+}
+
+// WriteMessage escreve na conexão com o prazo de WriteTimeout, uma escrita por vez.
+func (mc *ManagedConnection) WriteMessage(messageType int, data []byte) error {
 	if mc.Conn == nil {
 		return ErrNotConnected
 	}
 
+	mc.writeMu.Lock()
+	defer mc.writeMu.Unlock()
 	mc.Conn.SetWriteDeadline(time.Now().Add(mc.config.WriteTimeout))
-	return mc.Conn.WriteMessage(websocket.PingMessage, nil)
+	return mc.Conn.WriteMessage(messageType, data)
+}
+
+// Done é fechado quando a conexão é encerrada.
+func (mc *ManagedConnection) Done() <-chan struct{} {
+	return mc.ctx.Done()
 }
 
+// Close encerra a conexão; chamadas repetidas não têm efeito. SendQueue não é fechada, para
+// que um Send concorrente não escreva em canal fechado: o escritor deve observar Done.
 func (mc *ManagedConnection) Close() error {
-	mc.setState(StateClosed)
-	mc.cancel()
-	close(mc.SendQueue)
+	var err error
+	mc.closeOnce.Do(func() {
+		mc.setState(StateClosed)
+		mc.cancel()
 
-	if mc.Conn != nil {
-		return mc.Conn.Close()
-	}
-	return nil
+		if mc.Conn != nil {
+			err = mc.Conn.Close()
+		}
+	})
+	return err
 }
 
 func stateString(state ConnectionState) string {
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// echoServer aceita a conexão e descarta as mensagens recebidas; os pings são respondidos
+// com pongs pelo handler padrão da biblioteca.
+func echoServer(t *testing.T) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// Deve ser executado com go test -race: escritas, pings, envios e o fechamento concorrem
+// na mesma conexão.
+func TestManagedConnectionConcurrentWrites(t *testing.T) {
+	conn, _, err := websocket.DefaultDialer.Dial(echoServer(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConnectionConfig()
+	cfg.PingInterval = time.Millisecond
+	cfg.SendTimeout = 10 * time.Millisecond
+	mc := NewManagedConnection(zap.NewNop(), cfg)
+	mc.SetConnection(conn)
+
+	// O leitor dispara o handler de pong enquanto o health check lê lastPong
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	go mc.StartHealthCheck()
+
+	// Escritor único da fila, como o writePump
+	go func() {
+		for {
+			select {
+			case msg := <-mc.SendQueue:
+				mc.WriteMessage(websocket.TextMessage, msg)
+			case <-mc.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				msg := []byte(fmt.Sprintf("w%d-%d", i, j))
+				if j%2 == 0 {
+					mc.WriteMessage(websocket.TextMessage, msg)
+				} else {
+					mc.Send(msg)
+				}
+				mc.sendPing()
+			}
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mc.Close()
+		}()
+	}
+	wg.Wait()
+
+	if mc.GetState() != StateClosed {
+		t.Fatalf("estado = %s, want CLOSED", stateString(mc.GetState()))
+	}
+	if err := mc.Send([]byte("depois")); err != ErrNotConnected {
+		t.Fatalf("Send após Close = %v, want ErrNotConnected", err)
+	}
+}
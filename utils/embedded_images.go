@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// maxEmbeddedImageSize ignora imagens embutidas maiores que isso; a leitura do zip é limitada
+// a um byte além para detectar o excesso sem descomprimir tudo.
+const maxEmbeddedImageSize = MaxImageSize
+
+// extractDocxImages lê as imagens de word/media/* em formatos aceitos pelos provedores.
+// Formatos vetoriais (EMF/WMF) e imagens inválidas ou grandes demais são ignorados.
+func (fp *FileProcessor) extractDocxImages(pf *ProcessedFile, zipReader *zip.Reader) {
+	for _, file := range zipReader.File {
+		if !strings.HasPrefix(file.Name, "word/media/") || fp.embeddedLimitReached(pf) {
+			continue
+		}
+		if !providerImageFormats[strings.TrimPrefix(normalizeImageExt(path.Ext(file.Name)), ".")] {
+			continue
+		}
+		if file.UncompressedSize64 > maxEmbeddedImageSize {
+			fp.logger.Debug("Imagem embutida ignorada por exceder o limite", zap.String("name", file.Name))
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxEmbeddedImageSize+1))
+		rc.Close()
+		if err != nil || len(data) > maxEmbeddedImageSize {
+			continue
+		}
+		fp.addEmbeddedImage(pf, path.Base(file.Name), data)
+	}
+}
+
+// extractPDFImages procura imagens JPEG (filtro DCTDecode) nos streams do PDF. A biblioteca de
+// PDF não decodifica esse filtro, então os bytes são localizados diretamente no arquivo, o que
+// só é possível em PDFs sem criptografia.
+func (fp *FileProcessor) extractPDFImages(pf *ProcessedFile, content []byte) {
+	if encrypted, _ := pf.Metadata["encrypted"].(bool); encrypted {
+		return
+	}
+
+	data := content
+	for !fp.embeddedLimitReached(pf) {
+		start := bytes.Index(data, []byte("stream"))
+		if start < 0 {
+			return
+		}
+		data = data[start+len("stream"):]
+		// O conteúdo do stream começa após CRLF ou LF
+		data = bytes.TrimPrefix(data, []byte("\r"))
+		data = bytes.TrimPrefix(data, []byte("\n"))
+		if !bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}) {
+			continue
+		}
+
+		end := bytes.Index(data, []byte("endstream"))
+		if end < 0 {
+			return
+		}
+		jpeg := data[:end]
+		data = data[end:]
+		// Remove a quebra de linha entre os dados e "endstream"
+		if eoi := bytes.LastIndex(jpeg, []byte{0xFF, 0xD9}); eoi > 0 {
+			jpeg = jpeg[:eoi+2]
+		}
+		if len(jpeg) > maxEmbeddedImageSize {
+			continue
+		}
+		name := fmt.Sprintf("%s-imagem-%d.jpg", strings.TrimSuffix(pf.Name, path.Ext(pf.Name)), len(pf.EmbeddedImages)+1)
+		fp.addEmbeddedImage(pf, name, jpeg)
+	}
+}
+
+// addEmbeddedImage valida a imagem e a anexa ao documento de origem.
+func (fp *FileProcessor) addEmbeddedImage(pf *ProcessedFile, name string, data []byte) {
+	img := &ProcessedFile{
+		Name:        pf.Name + " › " + name,
+		ContentType: mime.TypeByExtension(normalizeImageExt(path.Ext(name))),
+		Size:        int64(len(data)),
+		Metadata:    map[string]interface{}{"embeddedIn": pf.Name},
+		ctx:         pf.ctx,
+	}
+	processed, err := fp.processImage(img, data)
+	if err != nil {
+		fp.logger.Debug("Imagem embutida ignorada", zap.String("document", pf.Name), zap.String("image", name), zap.Error(err))
+		return
+	}
+	processed.ctx = nil
+	pf.EmbeddedImages = append(pf.EmbeddedImages, *processed)
+	pf.Metadata["embeddedImages"] = len(pf.EmbeddedImages)
+}
+
+// extractsEmbeddedImages indica se as imagens de DOCX e PDF são extraídas: com
+// ExtractEmbeddedImages, para anexá-las, ou com o OCR ativo, para ler o texto delas.
+func (fp *FileProcessor) extractsEmbeddedImages() bool {
+	return fp.config.ExtractEmbeddedImages || (fp.config.EnableOCR && fp.ocr != nil)
+}
+
+// EmbeddedOCRText junta o texto do OCR das imagens informadas, identificando cada uma pelo nome.
+func EmbeddedOCRText(images []ProcessedFile) string {
+	var b strings.Builder
+	for _, img := range images {
+		text, _ := img.Metadata[MetadataOCRText].(string)
+		if text == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[%s]\n%s", img.Name, text)
+	}
+	return b.String()
+}
+
+// embeddedLimitReached indica se o documento já atingiu MaxEmbeddedImages.
+func (fp *FileProcessor) embeddedLimitReached(pf *ProcessedFile) bool {
+	return fp.config.MaxEmbeddedImages > 0 && len(pf.EmbeddedImages) >= fp.config.MaxEmbeddedImages
+}
+
+// normalizeImageExt converte extensões equivalentes para a usada em providerImageFormats.
+func normalizeImageExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if ext == ".jpeg" {
+		return ".jpg"
+	}
+	return ext
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/webchatcomllm/config"
+)
+
+// NewAPIError cria o erro de uma resposta HTTP de falha do provedor, extraindo do corpo o
+// código de erro específico do provedor quando presente.
+func NewAPIError(provider string, statusCode int, body []byte) *APIError {
+	return &APIError{
+		StatusCode: statusCode,
+		Message:    string(body),
+		Provider:   strings.ToUpper(provider),
+		Code:       extractErrorCode(body),
+
+		Maintenance: isMaintenanceResponse(statusCode, body),
+	}
+}
+
+// extractErrorCode lê o código dos formatos de erro conhecidos: {"error": {"code": ...}}
+// (OpenAI), {"error": {"type": ...}} (Anthropic) e {"code": ...} no primeiro nível.
+func extractErrorCode(body []byte) string {
+	var payload struct {
+		Code  interface{} `json:"code"`
+		Error struct {
+			Code interface{} `json:"code"`
+			Type string      `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	for _, candidate := range []interface{}{payload.Error.Code, payload.Error.Type, payload.Code} {
+		if code, ok := candidate.(string); ok && code != "" {
+			return code
+		}
+	}
+	return ""
+}
+
+// defaultErrorCodeRetry são as classificações por código que diferem da regra por status
+// (429 e 5xx com retry, demais sem).
+var defaultErrorCodeRetry = map[string]bool{
+	// A OpenAI responde 429 quando a cota da conta acaba; repetir não adianta.
+	"OPENAI:insufficient_quota": false,
+}
+
+var (
+	errorCodeRetryMu sync.RWMutex
+	errorCodeRetry   = defaultErrorCodeRetry
+)
+
+// ConfigureRetryableErrorCodes carrega RETRYABLE_ERROR_CODES e NON_RETRYABLE_ERROR_CODES,
+// listas de "PROVEDOR:código" (ou apenas "código", para qualquer provedor) que sobrescrevem a
+// decisão de retry baseada no status HTTP. Deve ser chamada na inicialização; retorna erro para
+// entradas malformadas, provedores desconhecidos ou códigos presentes nas duas listas.
+func ConfigureRetryableErrorCodes(providers []string) error {
+	known := make(map[string]bool, len(providers))
+	for _, p := range providers {
+		known[strings.ToUpper(p)] = true
+	}
+
+	policy := make(map[string]bool, len(defaultErrorCodeRetry))
+	for key, retry := range defaultErrorCodeRetry {
+		policy[key] = retry
+	}
+
+	configured := make(map[string]string)
+	for _, list := range []struct {
+		env   string
+		retry bool
+	}{
+		{"RETRYABLE_ERROR_CODES", true},
+		{"NON_RETRYABLE_ERROR_CODES", false},
+	} {
+		for _, entry := range config.GetEnvList(list.env, nil) {
+			key, err := errorCodeKey(entry, known)
+			if err != nil {
+				return fmt.Errorf("%s: %w", list.env, err)
+			}
+			if other, ok := configured[key]; ok && other != list.env {
+				return fmt.Errorf("código %q presente em %s e %s", entry, other, list.env)
+			}
+			configured[key] = list.env
+			policy[key] = list.retry
+		}
+	}
+
+	errorCodeRetryMu.Lock()
+	errorCodeRetry = policy
+	errorCodeRetryMu.Unlock()
+	return nil
+}
+
+// errorCodeKey normaliza uma entrada para "PROVEDOR:código" ou "*:código".
+func errorCodeKey(entry string, known map[string]bool) (string, error) {
+	provider, code := "*", entry
+	if i := strings.Index(entry, ":"); i >= 0 {
+		provider, code = strings.ToUpper(strings.TrimSpace(entry[:i])), strings.TrimSpace(entry[i+1:])
+		if !known[provider] {
+			return "", fmt.Errorf("provedor desconhecido em %q", entry)
+		}
+	}
+	if code == "" || strings.ContainsAny(code, " :") {
+		return "", fmt.Errorf("código de erro inválido: %q", entry)
+	}
+	return provider + ":" + code, nil
+}
+
+// errorCodeRetryable retorna a decisão configurada para o código do erro, se houver. A
+// entrada específica do provedor tem precedência sobre a genérica.
+func errorCodeRetryable(apiErr *APIError) (retry bool, ok bool) {
+	if apiErr.Code == "" {
+		return false, false
+	}
+	errorCodeRetryMu.RLock()
+	defer errorCodeRetryMu.RUnlock()
+
+	if apiErr.Provider != "" {
+		if retry, ok := errorCodeRetry[apiErr.Provider+":"+apiErr.Code]; ok {
+			return retry, true
+		}
+	}
+	retry, ok = errorCodeRetry["*:"+apiErr.Code]
+	return retry, ok
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ErrorCategory classifica a origem de um erro para que o frontend escolha
+// o ícone e a ação de retry adequados.
+type ErrorCategory string
+
+const (
+	ErrorCategoryNetwork   ErrorCategory = "network"
+	ErrorCategoryTimeout   ErrorCategory = "timeout"
+	ErrorCategoryRateLimit ErrorCategory = "rate_limit"
+	ErrorCategoryAuth      ErrorCategory = "auth"
+	ErrorCategoryServer    ErrorCategory = "server"
+	ErrorCategoryClient    ErrorCategory = "client"
+	// ErrorCategoryMaintenance indica provedor em manutenção anunciada
+	ErrorCategoryMaintenance ErrorCategory = "maintenance"
+)
+
+// CategorizedError associa uma categoria a um erro sem alterar sua mensagem.
+type CategorizedError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *CategorizedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CategorizedError) Unwrap() error {
+	return e.Err
+}
+
+// CategorizeError envolve o erro com sua categoria. Erros já categorizados são mantidos.
+func CategorizeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var ce *CategorizedError
+	if errors.As(err, &ce) {
+		return err
+	}
+	return &CategorizedError{Category: classifyError(err), Err: err}
+}
+
+// ErrorCategoryOf retorna a categoria de um erro, classificando-o se necessário.
+func ErrorCategoryOf(err error) ErrorCategory {
+	var ce *CategorizedError
+	if errors.As(err, &ce) {
+		return ce.Category
+	}
+	return classifyError(err)
+}
+
+func classifyError(err error) ErrorCategory {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorCategoryTimeout
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Maintenance:
+			return ErrorCategoryMaintenance
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorCategoryRateLimit
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return ErrorCategoryAuth
+		case apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode == http.StatusGatewayTimeout:
+			return ErrorCategoryTimeout
+		case apiErr.StatusCode >= 500:
+			return ErrorCategoryServer
+		default:
+			return ErrorCategoryClient
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorCategoryTimeout
+		}
+		return ErrorCategoryNetwork
+	}
+
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return ErrorCategoryNetwork
+	}
+
+	return ErrorCategoryServer
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// FileHandler processa um formato de arquivo. Matches recebe o MIME detectado e a extensão
+// (minúscula, com ponto); Process preenche pf (FileType, Content, Metadata...) a partir do
+// conteúdo bruto. Handlers registrados com RegisterHandler são consultados antes dos
+// embutidos, na ordem de registro, e o primeiro que aceitar o arquivo o processa.
+type FileHandler interface {
+	Matches(mime, ext string) bool
+	Process(pf *ProcessedFile, content []byte) error
+}
+
+// builtinHandler adapta os métodos de processamento do FileProcessor à interface FileHandler.
+type builtinHandler struct {
+	match   func(mime, ext string) bool
+	process func(pf *ProcessedFile, content []byte) (*ProcessedFile, error)
+}
+
+func (h builtinHandler) Matches(mime, ext string) bool {
+	return h.match(mime, ext)
+}
+
+func (h builtinHandler) Process(pf *ProcessedFile, content []byte) error {
+	_, err := h.process(pf, content)
+	return err
+}
+
+// builtinHandlers retorna os handlers embutidos na ordem de prioridade. O último aceita
+// qualquer arquivo e o trata como binário.
+func (fp *FileProcessor) builtinHandlers() []FileHandler {
+	return []FileHandler{
+		builtinHandler{match: fp.isImage, process: fp.processImage},
+		builtinHandler{match: fp.isPDF, process: func(pf *ProcessedFile, content []byte) (*ProcessedFile, error) {
+			return fp.processPDF(pf, content, pf.password)
+		}},
+		builtinHandler{match: fp.isDocx, process: fp.processDocx},
+		builtinHandler{match: fp.isXlsx, process: func(pf *ProcessedFile, content []byte) (*ProcessedFile, error) {
+			return fp.processXlsx(pf, content, pf.password)
+		}},
+		builtinHandler{match: func(_, ext string) bool { return fp.isDescriptorSet(ext) }, process: fp.processDescriptorSet},
+		builtinHandler{match: fp.isText, process: func(pf *ProcessedFile, content []byte) (*ProcessedFile, error) {
+			return fp.processText(pf, content, strings.ToLower(filepath.Ext(pf.Name)))
+		}},
+		builtinHandler{match: func(string, string) bool { return true }, process: fp.processBinary},
+	}
+}
+
+// RegisterHandler acrescenta um handler para novos formatos (ou para substituir o tratamento
+// embutido de um formato), consultado antes dos handlers embutidos.
+func (fp *FileProcessor) RegisterHandler(h FileHandler) *FileProcessor {
+	fp.custom = append(fp.custom, h)
+	return fp
+}
+
+// handlerFor retorna o primeiro handler que aceita o arquivo.
+func (fp *FileProcessor) handlerFor(mime, ext string) FileHandler {
+	for _, h := range fp.custom {
+		if h.Matches(mime, ext) {
+			return h
+		}
+	}
+	for _, h := range fp.builtin {
+		if h.Matches(mime, ext) {
+			return h
+		}
+	}
+	return nil
+}
+
+// Password retorna a senha informada para o arquivo. Disponível apenas durante Process, para
+// handlers de formatos protegidos; nunca deve ser registrada em logs ou metadados.
+func (pf *ProcessedFile) Password() string {
+	return pf.password
+}
@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+)
+
+func newTestProcessor() *FileProcessor {
+	return NewFileProcessor(zap.NewNop())
+}
+
+func processTestFile(t *testing.T, fp *FileProcessor, name string, content []byte) *ProcessedFile {
+	t.Helper()
+	pf, err := fp.ProcessFile(name, content)
+	if err != nil {
+		t.Fatalf("ProcessFile(%q): %v", name, err)
+	}
+	return pf
+}
+
+func TestImageHandler(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	pf := processTestFile(t, newTestProcessor(), "foto.png", buf.Bytes())
+	if pf.FileType != FileTypeImage || !pf.IsBase64 {
+		t.Fatalf("FileType = %q, IsBase64 = %v", pf.FileType, pf.IsBase64)
+	}
+	if pf.Content == "" {
+		t.Fatal("imagem sem conteúdo")
+	}
+}
+
+// minimalPDF monta um PDF de uma página com o texto informado, com a tabela xref calculada.
+func minimalPDF(text string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 712 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+func TestPDFHandler(t *testing.T) {
+	pf := processTestFile(t, newTestProcessor(), "relatorio.pdf", minimalPDF("Ola PDF"))
+	if pf.FileType != FileTypePDF {
+		t.Fatalf("FileType = %q", pf.FileType)
+	}
+	if !strings.Contains(pf.Content, "Ola PDF") {
+		t.Fatalf("texto não extraído: %q", pf.Content)
+	}
+	if pf.Metadata["pages"] != 1 {
+		t.Fatalf("pages = %v", pf.Metadata["pages"])
+	}
+}
+
+func TestDocxHandler(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body><w:p><w:r><w:t>Olá documento</w:t></w:r></w:p></w:body></w:document>`))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pf := processTestFile(t, newTestProcessor(), "contrato.docx", buf.Bytes())
+	if pf.FileType != FileTypeDocx {
+		t.Fatalf("FileType = %q", pf.FileType)
+	}
+	if !strings.Contains(pf.Content, "Olá documento") {
+		t.Fatalf("texto não extraído: %q", pf.Content)
+	}
+}
+
+func TestDocxHandlerRejectsUnencryptedOLE(t *testing.T) {
+	content := append(append([]byte(nil), oleSignature...), make([]byte, 1024)...)
+	_, err := newTestProcessor().ProcessFile("antigo.docx", content)
+	if err == nil || !strings.Contains(err.Error(), "formato inválido") {
+		t.Fatalf("erro = %v, want formato inválido", err)
+	}
+}
+
+func TestXlsxHandler(t *testing.T) {
+	f := excelize.NewFile()
+	f.SetCellValue("Sheet1", "A1", "produto")
+	f.SetCellValue("Sheet1", "A2", "café")
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pf := processTestFile(t, newTestProcessor(), "vendas.xlsx", buf.Bytes())
+	if pf.FileType != FileTypeXlsx {
+		t.Fatalf("FileType = %q", pf.FileType)
+	}
+	if !strings.Contains(pf.Content, "café") {
+		t.Fatalf("célula não extraída: %q", pf.Content)
+	}
+}
+
+// Codificação mínima de protobuf para montar um FileDescriptorSet de teste.
+func pbField(field int, data []byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(field<<3|2))
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func pbVarint(field int, v uint64) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(nil, uint64(field<<3)), v)
+}
+
+func TestDescriptorSetHandler(t *testing.T) {
+	var field []byte
+	field = append(field, pbField(1, []byte("id"))...)
+	field = append(field, pbVarint(3, 1)...) // number
+	field = append(field, pbVarint(4, 1)...) // LABEL_OPTIONAL
+	field = append(field, pbVarint(5, 9)...) // TYPE_STRING
+	message := append(pbField(1, []byte("Pedido")), pbField(2, field)...)
+	file := append(pbField(1, []byte("pedido.proto")), pbField(2, []byte("loja"))...)
+	file = append(file, pbField(4, message)...)
+	set := pbField(1, file)
+
+	pf := processTestFile(t, newTestProcessor(), "api.desc", set)
+	if pf.FileType != FileTypeCode || pf.Metadata["descriptorSet"] != true {
+		t.Fatalf("FileType = %q, metadata = %v", pf.FileType, pf.Metadata)
+	}
+	if !strings.Contains(pf.Content, "Pedido") || !strings.Contains(pf.Content, "id") {
+		t.Fatalf("resumo sem a mensagem: %q", pf.Content)
+	}
+}
+
+func TestDescriptorSetHandlerFallsBackToBinary(t *testing.T) {
+	pf := processTestFile(t, newTestProcessor(), "dados.pb", []byte{0xff, 0xff, 0xff, 0xff, 0x00, 0x01})
+	if pf.FileType != FileTypeBinary {
+		t.Fatalf("FileType = %q, want binary", pf.FileType)
+	}
+}
+
+func TestTextHandler(t *testing.T) {
+	pf := processTestFile(t, newTestProcessor(), "main.go", []byte("package main\n\nfunc main() {}\n"))
+	if pf.FileType == FileTypeBinary || pf.IsBase64 {
+		t.Fatalf("FileType = %q, IsBase64 = %v", pf.FileType, pf.IsBase64)
+	}
+	if !strings.Contains(pf.Content, "func main()") {
+		t.Fatalf("conteúdo = %q", pf.Content)
+	}
+}
+
+func TestBinaryHandler(t *testing.T) {
+	pf := processTestFile(t, newTestProcessor(), "blob.bin", []byte{0x00, 0x01, 0x02, 0x03, 0xfe})
+	if pf.FileType != FileTypeBinary {
+		t.Fatalf("FileType = %q", pf.FileType)
+	}
+	if !strings.Contains(pf.Content, "Arquivo binário") {
+		t.Fatalf("conteúdo = %q", pf.Content)
+	}
+}
+
+type upperHandler struct{}
+
+func (upperHandler) Matches(_, ext string) bool { return ext == ".txt" }
+
+func (upperHandler) Process(pf *ProcessedFile, content []byte) error {
+	pf.FileType = FileTypeText
+	pf.Content = strings.ToUpper(string(content))
+	return nil
+}
+
+func TestRegisterHandlerTakesPrecedence(t *testing.T) {
+	fp := newTestProcessor().RegisterHandler(upperHandler{})
+	pf := processTestFile(t, fp, "nota.txt", []byte("abc"))
+	if pf.Content != "ABC" {
+		t.Fatalf("handler registrado não usado: %q", pf.Content)
+	}
+	pf = processTestFile(t, fp, "nota.md", []byte("abc"))
+	if pf.Content == "ABC" {
+		t.Fatal("handler registrado usado fora dos arquivos que aceita")
+	}
+}
@@ -3,8 +3,11 @@ package utils
 import (
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
@@ -12,7 +15,9 @@ import (
 	_ "image/png"
 	"io"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/h2non/filetype"
@@ -25,6 +30,8 @@ const (
 	MaxImageSize = 10 * 1024 * 1024 // 10MB para imagens
 	MaxPDFSize   = 25 * 1024 * 1024 // 25MB para PDFs
 	MaxDocSize   = 15 * 1024 * 1024 // 15MB para documentos Office
+
+	MaxDecompressedSize = 20 * 1024 * 1024 // 20MB após descompressão de arquivos .gz
 )
 
 // FileType representa o tipo de arquivo processado
@@ -42,6 +49,8 @@ const (
 	FileTypeJSON     FileType = "json"
 	FileTypeXML      FileType = "xml"
 	FileTypeCSV      FileType = "csv"
+	FileTypeDiff     FileType = "diff"
+	FileTypeLog      FileType = "log"
 	FileTypeBinary   FileType = "binary"
 	FileTypeUnknown  FileType = "unknown"
 )
@@ -55,20 +64,117 @@ type ProcessedFile struct {
 	Size        int64                  `json:"size"`
 	IsBase64    bool                   `json:"isBase64"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	// EmbeddedImages são as imagens extraídas de documentos DOCX/PDF, quando ExtractEmbeddedImages está ativo
+	EmbeddedImages []ProcessedFile `json:"embeddedImages,omitempty"`
+	// ImagesOnly indica um documento sem texto cujo conteúdo está apenas em EmbeddedImages;
+	// Content traz só um aviso de que as imagens foram anexadas
+	ImagesOnly bool `json:"imagesOnly,omitempty"`
+
+	// password é a senha do arquivo durante o processamento (ver Password)
+	password string
+	// ctx é o contexto da requisição durante o processamento (ver ProcessFileContext)
+	ctx context.Context
+}
+
+// FileProcessorConfig reúne os limites configuráveis do processamento de arquivos
+type FileProcessorConfig struct {
+	// LogTailLines é o número de linhas finais mantidas de logs grandes
+	LogTailLines int
+	// LogMaxHighlights limita as linhas de erro/aviso destacadas em logs grandes
+	LogMaxHighlights int
+	// MaxCharsPerFile limita o conteúdo textual incluído por arquivo; 0 desativa o limite
+	MaxCharsPerFile int
+	// GeneratedFilesMode define o tratamento de lockfiles e arquivos minificados:
+	// "summary", "skip" ou "include"
+	GeneratedFilesMode string
+	// MinifiedLineLength é o tamanho de linha a partir do qual o arquivo é considerado minificado
+	MinifiedLineLength int
+	// ExtractEmbeddedImages extrai as imagens embutidas em DOCX e PDF, limitadas a
+	// MaxEmbeddedImages por documento
+	ExtractEmbeddedImages bool
+	MaxEmbeddedImages     int
+	// NormalizeText remove BOMs e converte quebras de linha para LF em arquivos de texto;
+	// com MaxBlankLines > 0, sequências maiores de linhas em branco são reduzidas
+	NormalizeText bool
+	MaxBlankLines int
+	// XlsxMaxSheets e XlsxMaxCells limitam as abas processadas e o total de células extraídas
+	// de uma planilha; 0 desativa o limite
+	XlsxMaxSheets int
+	XlsxMaxCells  int
+	// EnableOCR extrai o texto das imagens (Metadata["ocrText"]) com o OCREngine do processador;
+	// OCRLanguages são os idiomas usados pelo Tesseract
+	EnableOCR    bool
+	OCRLanguages string
+}
+
+// DefaultFileProcessorConfig retorna os limites padrão
+func DefaultFileProcessorConfig() FileProcessorConfig {
+	return FileProcessorConfig{
+		LogTailLines:     500,
+		LogMaxHighlights: 100,
+		MaxCharsPerFile:  100000,
+
+		GeneratedFilesMode: GeneratedFilesSummary,
+		MinifiedLineLength: 1000,
+
+		MaxEmbeddedImages: 5,
+
+		NormalizeText: true,
+
+		XlsxMaxSheets: 50,
+		XlsxMaxCells:  100000,
+	}
 }
 
 // FileProcessor processa diferentes tipos de arquivo
 type FileProcessor struct {
 	logger *zap.Logger
+	config FileProcessorConfig
+	// custom são os handlers registrados, consultados antes dos embutidos (builtin)
+	custom  []FileHandler
+	builtin []FileHandler
+	// ocr extrai o texto das imagens quando EnableOCR está ativo (ver WithOCR)
+	ocr OCREngine
 }
 
 // NewFileProcessor cria uma nova instância do processador
 func NewFileProcessor(logger *zap.Logger) *FileProcessor {
-	return &FileProcessor{logger: logger}
+	fp := &FileProcessor{logger: logger, config: DefaultFileProcessorConfig()}
+	fp.builtin = fp.builtinHandlers()
+	return fp
+}
+
+// WithConfig substitui os limites padrão do processador. Com EnableOCR e sem um mecanismo
+// definido por WithOCR, o OCR usa o binário tesseract.
+func (fp *FileProcessor) WithConfig(config FileProcessorConfig) *FileProcessor {
+	fp.config = config
+	if config.EnableOCR && fp.ocr == nil {
+		fp.ocr = TesseractOCR{Languages: config.OCRLanguages}
+	}
+	return fp
 }
 
 // ProcessFile processa um arquivo baseado em seu tipo
 func (fp *FileProcessor) ProcessFile(name string, content []byte) (*ProcessedFile, error) {
+	return fp.ProcessFileWithPassword(name, content, "")
+}
+
+// ProcessFileWithPassword processa o arquivo usando a senha informada para abrir PDFs e
+// planilhas protegidos. A senha nunca é registrada em logs ou metadados.
+func (fp *FileProcessor) ProcessFileWithPassword(name string, content []byte, password string) (*ProcessedFile, error) {
+	return fp.ProcessFileContext(context.Background(), name, content, password)
+}
+
+// ProcessFileContext processa o arquivo como ProcessFileWithPassword. As etapas externas, como
+// o OCR, terminam quando o contexto é cancelado ou expira; os extratores internos não podem ser
+// interrompidos e seguem até o fim.
+func (fp *FileProcessor) ProcessFileContext(ctx context.Context, name string, content []byte, password string) (*ProcessedFile, error) {
+	return fp.process(ctx, name, content, password, true)
+}
+
+// process processa o arquivo; allowGzip é falso para o conteúdo já descomprimido, de modo que
+// apenas um nível de gzip é expandido (gzip aninhado ou recursivo é recusado).
+func (fp *FileProcessor) process(ctx context.Context, name string, content []byte, password string, allowGzip bool) (*ProcessedFile, error) {
 	if len(content) == 0 {
 		return nil, fmt.Errorf("arquivo vazio: %s", name)
 	}
@@ -85,28 +191,106 @@ func (fp *FileProcessor) ProcessFile(name string, content []byte) (*ProcessedFil
 		zap.Int("size", len(content)),
 	)
 
+	if isGzip(content) {
+		if !allowGzip {
+			return nil, fmt.Errorf("arquivos gzip aninhados não são suportados")
+		}
+		return fp.processGzip(ctx, name, content, password)
+	}
+
 	processed := &ProcessedFile{
 		Name:        name,
 		ContentType: contentType,
 		Size:        int64(len(content)),
 		Metadata:    make(map[string]interface{}),
+		password:    password,
+		ctx:         ctx,
 	}
 
-	// Roteamento por tipo de arquivo
-	switch {
-	case fp.isImage(contentType, ext):
-		return fp.processImage(processed, content)
-	case fp.isPDF(contentType, ext):
-		return fp.processPDF(processed, content)
-	case fp.isDocx(contentType, ext):
-		return fp.processDocx(processed, content)
-	case fp.isXlsx(contentType, ext):
-		return fp.processXlsx(processed, content)
-	case fp.isText(contentType, ext):
-		return fp.processText(processed, content, ext)
-	default:
-		return fp.processBinary(processed, content)
+	// Roteamento por tipo de arquivo: o primeiro handler que aceitar o arquivo o processa
+	err := fp.handlerFor(contentType, ext).Process(processed, content)
+	processed.password = ""
+	processed.ctx = nil
+	if err != nil {
+		return nil, err
+	}
+	fp.ensureValidUTF8(processed)
+	return processed, nil
+}
+
+// ensureValidUTF8 substitui sequências UTF-8 inválidas do texto extraído (comuns em PDFs e
+// arquivos em outra codificação) pelo caractere de substituição, já que elas corrompem o
+// prompt e a serialização JSON. A correção é registrada em Metadata["invalidUTF8"].
+func (fp *FileProcessor) ensureValidUTF8(pf *ProcessedFile) {
+	if pf == nil || pf.IsBase64 || utf8.ValidString(pf.Content) {
+		return
 	}
+	pf.Content = strings.ToValidUTF8(pf.Content, "\uFFFD")
+	pf.Metadata["invalidUTF8"] = true
+	fp.logger.Warn("Conteúdo com UTF-8 inválido corrigido",
+		zap.String("name", pf.Name),
+		zap.String("type", string(pf.FileType)),
+	)
+}
+
+// isGzip verifica a assinatura mágica do formato gzip
+func isGzip(content []byte) bool {
+	return len(content) >= 2 && content[0] == 0x1f && content[1] == 0x8b
+}
+
+// processGzip descomprime um arquivo gzip de arquivo único e processa o conteúdo
+// interno conforme seu próprio tipo. Apenas um nível é expandido, limitado a
+// MaxDecompressedSize; arquivos tar.gz não são expandidos.
+func (fp *FileProcessor) processGzip(ctx context.Context, name string, content []byte, password string) (*ProcessedFile, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir arquivo gzip: %w", err)
+	}
+	defer gz.Close()
+
+	innerName := name
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz", ".gzip":
+		innerName = strings.TrimSuffix(name, filepath.Ext(name))
+	case ".tgz":
+		innerName = strings.TrimSuffix(name, filepath.Ext(name)) + ".tar"
+	}
+	if (innerName == "" || innerName == name) && gz.Name != "" {
+		innerName = gz.Name
+	}
+
+	// Lê um byte além do limite para detectar o excesso sem descomprimir tudo
+	decompressed, err := io.ReadAll(io.LimitReader(gz, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao descomprimir arquivo gzip: %w", err)
+	}
+	if len(decompressed) > MaxDecompressedSize {
+		return nil, fmt.Errorf("conteúdo descomprimido excede o limite de %d MB", MaxDecompressedSize/1024/1024)
+	}
+
+	if strings.EqualFold(filepath.Ext(innerName), ".tar") || mimetype.Detect(decompressed).Is("application/x-tar") {
+		return nil, fmt.Errorf("arquivos compactados com múltiplos arquivos (tar.gz) não são suportados")
+	}
+
+	processed, err := fp.process(ctx, innerName, decompressed, password, false)
+	if err != nil {
+		return nil, err
+	}
+
+	processed.Name = name
+	processed.Metadata["compression"] = "gzip"
+	processed.Metadata["innerName"] = innerName
+	processed.Metadata["compressedSize"] = len(content)
+	processed.Metadata["decompressedSize"] = len(decompressed)
+
+	fp.logger.Info("Arquivo gzip descomprimido",
+		zap.String("name", name),
+		zap.String("inner_name", innerName),
+		zap.Int("compressed_size", len(content)),
+		zap.Int("decompressed_size", len(decompressed)),
+	)
+
+	return processed, nil
 }
 
 // isImage verifica se é uma imagem
@@ -152,10 +336,20 @@ func (fp *FileProcessor) isText(mime, ext string) bool {
 		".swift": true, ".kt": true, ".groovy": true, ".lua": true,
 		".vim": true, ".el": true, ".clj": true, ".erl": true,
 		".ex": true, ".exs": true, ".dart": true, ".proto": true,
+		".diff": true, ".patch": true, ".lock": true, ".sum": true,
 	}
 	return strings.HasPrefix(mime, "text/") || textExts[ext]
 }
 
+// providerImageFormats são os formatos de imagem aceitos pelos provedores de LLM,
+// identificados pela extensão detectada por filetype.
+var providerImageFormats = map[string]bool{
+	"jpg":  true,
+	"png":  true,
+	"gif":  true,
+	"webp": true,
+}
+
 // processImage processa imagens
 func (fp *FileProcessor) processImage(pf *ProcessedFile, content []byte) (*ProcessedFile, error) {
 	if int64(len(content)) > MaxImageSize {
@@ -175,6 +369,26 @@ func (fp *FileProcessor) processImage(pf *ProcessedFile, content []byte) (*Proce
 		pf.Metadata["width"] = bounds.Dx()
 		pf.Metadata["height"] = bounds.Dy()
 		pf.Metadata["format"] = format
+	} else {
+		format = kind.Extension
+		if !providerImageFormats[format] {
+			// Formatos como TIFF e BMP seriam recusados pelo provedor com erros pouco claros
+			fp.logger.Warn("Formato de imagem não suportado",
+				zap.String("name", pf.Name),
+				zap.String("format", format),
+			)
+			return nil, fmt.Errorf("formato de imagem não suportado: %s (use JPEG, PNG, GIF ou WebP)", format)
+		}
+		// Como antes, imagens que não decodificam localmente (WebP, que não tem decodificador
+		// aqui, ou arquivos danificados) seguem sem as dimensões; o provedor decide se as aceita
+		if format != "webp" {
+			fp.logger.Warn("Não foi possível ler as dimensões da imagem",
+				zap.String("name", pf.Name),
+				zap.String("format", format),
+				zap.Error(err),
+			)
+		}
+		pf.Metadata["format"] = format
 	}
 
 	pf.FileType = FileTypeImage
@@ -187,21 +401,29 @@ func (fp *FileProcessor) processImage(pf *ProcessedFile, content []byte) (*Proce
 		zap.String("format", format),
 		zap.Any("dimensions", pf.Metadata),
 	)
+	fp.applyOCR(pf, content)
 
 	return pf, nil
 }
 
 // processPDF extrai texto de PDFs
-func (fp *FileProcessor) processPDF(pf *ProcessedFile, content []byte) (*ProcessedFile, error) {
+func (fp *FileProcessor) processPDF(pf *ProcessedFile, content []byte, password string) (*ProcessedFile, error) {
 	if int64(len(content)) > MaxPDFSize {
 		return nil, fmt.Errorf("PDF excede o limite de %d MB", MaxPDFSize/1024/1024)
 	}
 
 	reader := bytes.NewReader(content)
-	pdfReader, err := pdf.NewReader(reader, int64(len(content)))
+	pdfReader, err := pdf.NewReaderEncrypted(reader, int64(len(content)), passwordOnce(password))
 	if err != nil {
+		if errors.Is(err, pdf.ErrInvalidPassword) {
+			pf.Metadata["encrypted"] = true
+			return nil, protectedFileError(password)
+		}
 		return nil, fmt.Errorf("erro ao abrir PDF: %w", err)
 	}
+	if !pdfReader.Trailer().Key("Encrypt").IsNull() {
+		pf.Metadata["encrypted"] = true
+	}
 
 	var textContent strings.Builder
 	numPages := pdfReader.NumPage()
@@ -227,8 +449,15 @@ func (fp *FileProcessor) processPDF(pf *ProcessedFile, content []byte) (*Process
 	}
 
 	extractedText := textContent.String()
+	if fp.extractsEmbeddedImages() {
+		fp.extractPDFImages(pf, content)
+	}
 	if len(strings.TrimSpace(extractedText)) == 0 {
-		return nil, fmt.Errorf("não foi possível extrair texto do PDF")
+		if len(pf.EmbeddedImages) == 0 {
+			return nil, fmt.Errorf("não foi possível extrair texto do PDF")
+		}
+		extractedText = "[PDF sem texto extraível; as imagens foram anexadas]"
+		pf.ImagesOnly = true
 	}
 
 	pf.FileType = FileTypePDF
@@ -281,11 +510,23 @@ func (fp *FileProcessor) processDocx(pf *ProcessedFile, content []byte) (*Proces
 		return nil, fmt.Errorf("documento excede o limite de %d MB", MaxDocSize/1024/1024)
 	}
 
+	// Documentos Office criptografados são contêineres OLE com o stream EncryptionInfo, não
+	// ZIP; sem ele, o OLE é outro formato com a extensão errada
+	if isEncryptedOffice(content) {
+		return nil, ErrPasswordProtected
+	}
+	if isOLECompound(content) {
+		return nil, errInvalidOfficeFormat
+	}
+
 	// Abre o arquivo DOCX como ZIP
 	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
 	if err != nil {
 		return nil, fmt.Errorf("erro ao abrir documento Word: %w", err)
 	}
+	if zipIsEncrypted(zipReader) {
+		return nil, ErrPasswordProtected
+	}
 
 	// Procura pelo arquivo document.xml
 	var documentXML []byte
@@ -355,8 +596,15 @@ func (fp *FileProcessor) processDocx(pf *ProcessedFile, content []byte) (*Proces
 	}
 
 	extractedText := textContent.String()
+	if fp.extractsEmbeddedImages() {
+		fp.extractDocxImages(pf, zipReader)
+	}
 	if len(strings.TrimSpace(extractedText)) == 0 {
-		return nil, fmt.Errorf("documento Word está vazio")
+		if len(pf.EmbeddedImages) == 0 {
+			return nil, fmt.Errorf("documento Word está vazio")
+		}
+		extractedText = "[documento sem texto; as imagens foram anexadas]"
+		pf.ImagesOnly = true
 	}
 
 	pf.FileType = FileTypeDocx
@@ -375,26 +623,54 @@ func (fp *FileProcessor) processDocx(pf *ProcessedFile, content []byte) (*Proces
 }
 
 // processXlsx extrai dados de planilhas Excel
-func (fp *FileProcessor) processXlsx(pf *ProcessedFile, content []byte) (*ProcessedFile, error) {
+func (fp *FileProcessor) processXlsx(pf *ProcessedFile, content []byte, password string) (*ProcessedFile, error) {
 	if int64(len(content)) > MaxDocSize {
 		return nil, fmt.Errorf("planilha excede o limite de %d MB", MaxDocSize/1024/1024)
 	}
 
+	encrypted := isEncryptedOffice(content)
+	if !encrypted && isOLECompound(content) {
+		return nil, errInvalidOfficeFormat
+	}
+	if encrypted && password == "" {
+		return nil, ErrPasswordProtected
+	}
+
 	reader := bytes.NewReader(content)
-	f, err := excelize.OpenReader(reader)
+	f, err := excelize.OpenReader(reader, excelize.Options{Password: password})
 	if err != nil {
+		if encrypted {
+			return nil, protectedFileError(password)
+		}
 		return nil, fmt.Errorf("erro ao abrir planilha Excel: %w", err)
 	}
 	defer f.Close()
+	if encrypted {
+		pf.Metadata["encrypted"] = true
+	}
 
 	var textContent strings.Builder
 	sheets := f.GetSheetList()
 	pf.Metadata["sheets"] = len(sheets)
 
-	for _, sheetName := range sheets {
-		textContent.WriteString(fmt.Sprintf("\n=== Planilha: %s ===\n", sheetName))
+	processSheets := sheets
+	if max := fp.config.XlsxMaxSheets; max > 0 && len(sheets) > max {
+		processSheets = sheets[:max]
+	}
+
+	// Orçamento de células compartilhado entre as abas; ao se esgotar, o restante é omitido
+	cellBudget := fp.config.XlsxMaxCells
+	cellsExtracted, cellsOmitted, sheetsOmitted := 0, 0, len(sheets)-len(processSheets)
+
+	for _, sheetName := range processSheets {
+		// Abas após o fim do orçamento nem são lidas; contam apenas como omitidas
+		if cellBudget > 0 && cellsExtracted >= cellBudget {
+			sheetsOmitted++
+			continue
+		}
 
-		rows, err := f.GetRows(sheetName)
+		// As linhas são lidas uma a uma, sem carregar a aba inteira antes dos limites
+		rows, err := f.Rows(sheetName)
 		if err != nil {
 			fp.logger.Warn("Erro ao ler planilha",
 				zap.String("sheet", sheetName),
@@ -403,9 +679,26 @@ func (fp *FileProcessor) processXlsx(pf *ProcessedFile, content []byte) (*Proces
 			continue
 		}
 
-		for rowIndex, row := range rows {
+		textContent.WriteString(fmt.Sprintf("\n=== Planilha: %s ===\n", sheetName))
+
+		for rowIndex := 0; rows.Next(); rowIndex++ {
 			if rowIndex > 1000 { // Limite de 1000 linhas por planilha
-				textContent.WriteString(fmt.Sprintf("\n... (mais %d linhas omitidas)\n", len(rows)-1000))
+				textContent.WriteString("\n... (linhas restantes omitidas)\n")
+				break
+			}
+			row, err := rows.Columns()
+			if err != nil {
+				fp.logger.Warn("Erro ao ler linha da planilha",
+					zap.String("sheet", sheetName),
+					zap.Int("row", rowIndex+1),
+					zap.Error(err),
+				)
+				break
+			}
+			if cellBudget > 0 && cellsExtracted+len(row) > cellBudget {
+				// As linhas seguintes não são lidas, então a contagem é um limite inferior
+				cellsOmitted += len(row)
+				cellsExtracted = cellBudget
 				break
 			}
 
@@ -416,7 +709,24 @@ func (fp *FileProcessor) processXlsx(pf *ProcessedFile, content []byte) (*Proces
 				textContent.WriteString(cell)
 			}
 			textContent.WriteString("\n")
+			cellsExtracted += len(row)
 		}
+		if err := rows.Close(); err != nil {
+			fp.logger.Debug("Erro ao fechar a leitura da planilha", zap.String("sheet", sheetName), zap.Error(err))
+		}
+	}
+
+	if cellsOmitted > 0 || sheetsOmitted > 0 {
+		textContent.WriteString(fmt.Sprintf("\n... planilha truncada: pelo menos %d células e %d abas omitidas (limites: %d células, %d abas)\n",
+			cellsOmitted, sheetsOmitted, fp.config.XlsxMaxCells, fp.config.XlsxMaxSheets))
+		pf.Metadata["truncated"] = true
+		pf.Metadata["cellsOmitted"] = cellsOmitted
+		pf.Metadata["sheetsOmitted"] = sheetsOmitted
+		fp.logger.Warn("Planilha truncada pelos limites de extração",
+			zap.String("name", pf.Name),
+			zap.Int("cells_omitted", cellsOmitted),
+			zap.Int("sheets_omitted", sheetsOmitted),
+		)
 	}
 
 	extractedText := textContent.String()
@@ -438,7 +748,7 @@ func (fp *FileProcessor) processXlsx(pf *ProcessedFile, content []byte) (*Proces
 
 // processText processa arquivos de texto
 func (fp *FileProcessor) processText(pf *ProcessedFile, content []byte, ext string) (*ProcessedFile, error) {
-	text := string(content)
+	text := string(fp.normalizeText(pf, content))
 
 	// Detecta tipo específico de arquivo de texto
 	switch ext {
@@ -455,8 +765,31 @@ func (fp *FileProcessor) processText(pf *ProcessedFile, content []byte, ext stri
 	case ".go", ".js", ".ts", ".py", ".java", ".c", ".cpp", ".h", ".cs", ".rb", ".php":
 		pf.FileType = FileTypeCode
 		pf.Metadata["language"] = strings.TrimPrefix(ext, ".")
+	case ".proto":
+		pf.FileType = FileTypeCode
+		pf.Metadata["language"] = "protobuf"
+		pf.Metadata["messages"] = len(protoMessagePattern.FindAllString(text, -1))
+		pf.Metadata["services"] = len(protoServicePattern.FindAllString(text, -1))
+	case ".diff", ".patch":
+		pf.FileType = FileTypeDiff
+	case ".log":
+		return fp.processLog(pf, text)
 	default:
 		pf.FileType = FileTypeText
+		if looksLikeDiff(text) {
+			pf.FileType = FileTypeDiff
+		}
+	}
+
+	if fp.applyGeneratedFilePolicy(pf, text) {
+		return pf, nil
+	}
+
+	if pf.FileType == FileTypeDiff {
+		added, removed, files := diffStats(text)
+		pf.Metadata["linesAdded"] = added
+		pf.Metadata["linesRemoved"] = removed
+		pf.Metadata["filesChanged"] = files
 	}
 
 	pf.Content = text
@@ -472,6 +805,155 @@ func (fp *FileProcessor) processText(pf *ProcessedFile, content []byte, ext stri
 	return pf, nil
 }
 
+var (
+	protoMessagePattern = regexp.MustCompile(`(?m)^\s*message\s+\w+`)
+	protoServicePattern = regexp.MustCompile(`(?m)^\s*service\s+\w+`)
+)
+
+var (
+	logErrorPattern = regexp.MustCompile(`(?i)\b(error|err|fatal|critical|panic|exception)\b`)
+	logWarnPattern  = regexp.MustCompile(`(?i)\b(warn|warning)\b`)
+	logInfoPattern  = regexp.MustCompile(`(?i)\binfo\b`)
+)
+
+// processLog conta linhas por nível e, para logs grandes, mantém apenas o final do
+// arquivo e as linhas de erro/aviso, em vez de enviar o arquivo inteiro ao modelo.
+func (fp *FileProcessor) processLog(pf *ProcessedFile, text string) (*ProcessedFile, error) {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	errorCount, warnCount, infoCount := 0, 0, 0
+	var highlights []string
+
+	for i, line := range lines {
+		highlight := false
+		switch {
+		case logErrorPattern.MatchString(line):
+			errorCount++
+			highlight = true
+		case logWarnPattern.MatchString(line):
+			warnCount++
+			highlight = true
+		case logInfoPattern.MatchString(line):
+			infoCount++
+		}
+		if highlight && len(highlights) < fp.config.LogMaxHighlights {
+			highlights = append(highlights, fmt.Sprintf("L%d: %s", i+1, line))
+		}
+	}
+
+	pf.FileType = FileTypeLog
+	pf.IsBase64 = false
+	pf.Metadata["lines"] = len(lines)
+	pf.Metadata["errorLines"] = errorCount
+	pf.Metadata["warnLines"] = warnCount
+	pf.Metadata["infoLines"] = infoCount
+
+	tail := fp.config.LogTailLines
+	if tail <= 0 || len(lines) <= tail {
+		pf.Content = text
+		return pf, nil
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("[Log resumido: %d linhas no total; exibindo as últimas %d]\n", len(lines), tail))
+	b.WriteString(fmt.Sprintf("Contagem por nível: ERROR=%d, WARN=%d, INFO=%d\n\n", errorCount, warnCount, infoCount))
+	if len(highlights) > 0 {
+		b.WriteString(fmt.Sprintf("--- Linhas de erro/aviso (até %d) ---\n", fp.config.LogMaxHighlights))
+		b.WriteString(strings.Join(highlights, "\n"))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(fmt.Sprintf("--- Últimas %d linhas ---\n", tail))
+	b.WriteString(strings.Join(lines[len(lines)-tail:], "\n"))
+
+	pf.Content = b.String()
+	pf.Metadata["truncated"] = true
+	pf.Metadata["tailLines"] = tail
+
+	fp.logger.Info("Log grande resumido",
+		zap.String("name", pf.Name),
+		zap.Int("lines", len(lines)),
+		zap.Int("errors", errorCount),
+		zap.Int("warnings", warnCount),
+	)
+
+	return pf, nil
+}
+
+// TruncateContent limita o conteúdo textual do arquivo a MaxCharsPerFile, cortando em um
+// limite natural e anexando um aviso com as linhas incluídas. Retorna true se houve corte.
+func (fp *FileProcessor) TruncateContent(pf *ProcessedFile) bool {
+	return fp.TruncateContentTo(pf, fp.config.MaxCharsPerFile)
+}
+
+// TruncateContentTo aplica o mesmo corte de TruncateContent com um limite explícito.
+func (fp *FileProcessor) TruncateContentTo(pf *ProcessedFile, max int) bool {
+	if max <= 0 || pf.IsBase64 || len(pf.Content) <= max {
+		return false
+	}
+
+	original := pf.Content
+	included := truncateAtBoundary(original, max)
+	totalLines := strings.Count(original, "\n") + 1
+	includedLines := strings.Count(included, "\n") + 1
+
+	pf.Content = fmt.Sprintf("%s\n\n[arquivo truncado: %d de %d linhas]", included, includedLines, totalLines)
+	pf.Metadata["truncated"] = true
+	pf.Metadata["originalChars"] = len(original)
+	pf.Metadata["includedChars"] = len(included)
+
+	fp.logger.Info("Conteúdo de arquivo truncado",
+		zap.String("name", pf.Name),
+		zap.Int("original_chars", len(original)),
+		zap.Int("included_chars", len(included)),
+		zap.Int("included_lines", includedLines),
+		zap.Int("total_lines", totalLines),
+	)
+	return true
+}
+
+// truncateAtBoundary corta o texto em até max bytes, preferindo o fim de um bloco (chave de
+// fechamento na coluna zero ou linha em branco) próximo ao limite e, na falta dele, o fim de uma linha.
+func truncateAtBoundary(text string, max int) string {
+	cut := TruncateUTF8(text, max)
+	max = len(cut)
+
+	minPos := max * 4 / 5
+	for _, marker := range []string{"\n}\n", "\n\n"} {
+		if idx := strings.LastIndex(cut, marker); idx >= minPos {
+			return cut[:idx+len(marker)-1]
+		}
+	}
+	if idx := strings.LastIndex(cut, "\n"); idx > 0 {
+		return cut[:idx]
+	}
+	return cut
+}
+
+// looksLikeDiff detecta diffs unificados pelos marcadores iniciais
+func looksLikeDiff(text string) bool {
+	if strings.HasPrefix(text, "diff --git ") {
+		return true
+	}
+	lines := strings.SplitN(text, "\n", 3)
+	return len(lines) >= 2 && strings.HasPrefix(lines[0], "--- ") && strings.HasPrefix(lines[1], "+++ ")
+}
+
+// diffStats conta linhas adicionadas, removidas e arquivos alterados em um diff unificado
+func diffStats(text string) (added, removed, files int) {
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			files++
+		case strings.HasPrefix(line, "--- "):
+			// Cabeçalho do arquivo original, não é uma remoção
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed, files
+}
+
 // processBinary processa arquivos binários (como fallback)
 func (fp *FileProcessor) processBinary(pf *ProcessedFile, content []byte) (*ProcessedFile, error) {
 	// Para arquivos binários não suportados, retorna informações básicas
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Modos de tratamento de arquivos minificados ou gerados
+const (
+	GeneratedFilesSummary = "summary" // substitui o conteúdo por um resumo (tamanho, dependências)
+	GeneratedFilesSkip    = "skip"    // omite o conteúdo, deixando apenas uma nota
+	GeneratedFilesInclude = "include" // envia o conteúdo normalmente
+)
+
+// lockfileNames são arquivos gerados por gerenciadores de pacotes
+var lockfileNames = map[string]bool{
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lock":            true,
+	"composer.lock":       true,
+	"gemfile.lock":        true,
+	"cargo.lock":          true,
+	"poetry.lock":         true,
+	"pipfile.lock":        true,
+	"go.sum":              true,
+}
+
+// generatedSuffixes identificam artefatos de build pelo nome
+var generatedSuffixes = []string{".min.js", ".min.css", ".min.mjs", ".js.map", ".css.map", ".bundle.js"}
+
+// minifiableExts são as extensões em que linhas muito longas indicam minificação; em dados
+// como JSON ou CSV, uma linha longa é conteúdo legítimo.
+var minifiableExts = map[string]bool{".js": true, ".mjs": true, ".cjs": true, ".css": true, ".html": true}
+
+// generatedKind classifica o arquivo como "lockfile" ou "minified"; retorna "" para arquivos comuns.
+// Além do nome, JS/CSS com linhas muito longas são considerados minificados.
+func generatedKind(name, text string, minifiedLineLength int) string {
+	base := strings.ToLower(filepath.Base(name))
+	if lockfileNames[base] {
+		return "lockfile"
+	}
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return "minified"
+		}
+	}
+	if minifiableExts[filepath.Ext(base)] && minifiedLineLength > 0 && looksMinified(text, minifiedLineLength) {
+		return "minified"
+	}
+	return ""
+}
+
+// looksMinified verifica se o texto tem poucas linhas e alguma delas muito longa,
+// padrão típico de JS/CSS minificado.
+func looksMinified(text string, minifiedLineLength int) bool {
+	if len(text) < minifiedLineLength {
+		return false
+	}
+	lines := strings.Count(text, "\n") + 1
+	if len(text)/lines < minifiedLineLength/4 {
+		return false
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if len(line) >= minifiedLineLength {
+			return true
+		}
+	}
+	return false
+}
+
+// lockfileDependencies conta as dependências declaradas no lockfile; retorna -1 quando
+// o formato não é reconhecido.
+func lockfileDependencies(name, text string) int {
+	switch strings.ToLower(filepath.Base(name)) {
+	case "package-lock.json", "npm-shrinkwrap.json":
+		var lock struct {
+			Packages     map[string]json.RawMessage `json:"packages"`
+			Dependencies map[string]json.RawMessage `json:"dependencies"`
+		}
+		if err := json.Unmarshal([]byte(text), &lock); err != nil {
+			return -1
+		}
+		if len(lock.Packages) > 0 {
+			// A chave vazia representa o próprio projeto
+			if _, ok := lock.Packages[""]; ok {
+				return len(lock.Packages) - 1
+			}
+			return len(lock.Packages)
+		}
+		return len(lock.Dependencies)
+
+	case "yarn.lock":
+		count := 0
+		for _, line := range strings.Split(text, "\n") {
+			if line != "" && line[0] != ' ' && line[0] != '#' && strings.HasSuffix(strings.TrimSpace(line), ":") {
+				count++
+			}
+		}
+		return count
+
+	case "cargo.lock", "poetry.lock":
+		return strings.Count(text, "[[package]]")
+
+	case "go.sum":
+		modules := make(map[string]bool)
+		for _, line := range strings.Split(text, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && !strings.HasSuffix(fields[1], "/go.mod") {
+				modules[fields[0]+" "+fields[1]] = true
+			}
+		}
+		return len(modules)
+	}
+	return -1
+}
+
+// applyGeneratedFilePolicy substitui o conteúdo de arquivos minificados ou gerados conforme
+// GeneratedFilesMode. Retorna true se o conteúdo foi substituído.
+func (fp *FileProcessor) applyGeneratedFilePolicy(pf *ProcessedFile, text string) bool {
+	mode := fp.config.GeneratedFilesMode
+	if mode == "" || mode == GeneratedFilesInclude {
+		return false
+	}
+	kind := generatedKind(pf.Name, text, fp.config.MinifiedLineLength)
+	if kind == "" {
+		return false
+	}
+
+	lines := strings.Count(text, "\n") + 1
+	label := "arquivo minificado"
+	if kind == "lockfile" {
+		label = "lockfile"
+	}
+
+	if mode == GeneratedFilesSkip {
+		pf.Content = fmt.Sprintf("[%s omitido: %s (%d bytes)]", label, pf.Name, pf.Size)
+	} else {
+		var b strings.Builder
+		b.WriteString(fmt.Sprintf("[%s resumido: %s]\n", label, pf.Name))
+		b.WriteString(fmt.Sprintf("Tamanho: %d bytes, %d linhas\n", pf.Size, lines))
+		if kind == "lockfile" {
+			if deps := lockfileDependencies(pf.Name, text); deps >= 0 {
+				b.WriteString(fmt.Sprintf("Dependências: %d\n", deps))
+				pf.Metadata["dependencies"] = deps
+			}
+		}
+		b.WriteString("O conteúdo completo foi omitido por ser gerado automaticamente.")
+		pf.Content = b.String()
+	}
+
+	pf.IsBase64 = false
+	pf.Metadata["generated"] = kind
+	pf.Metadata["lines"] = lines
+
+	fp.logger.Info("Arquivo gerado omitido do prompt",
+		zap.String("name", pf.Name),
+		zap.String("kind", kind),
+		zap.String("mode", mode),
+		zap.Int64("size", pf.Size),
+	)
+	return true
+}
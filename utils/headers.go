@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// reservedHeaders não podem ser sobrescritos por configuração, pois carregam credenciais
+// ou controlam o protocolo da requisição.
+var reservedHeaders = map[string]bool{
+	"Authorization":     true,
+	"X-Api-Key":         true,
+	"Anthropic-Version": true,
+	"Content-Type":      true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Transfer-Encoding": true,
+}
+
+// ParseHeaderList interpreta uma lista no formato "Nome:Valor,Outro:Valor" e valida
+// a sintaxe de cada cabeçalho. Uma string vazia resulta em nenhum cabeçalho.
+func ParseHeaderList(s string) (http.Header, error) {
+	headers := http.Header{}
+	if strings.TrimSpace(s) == "" {
+		return headers, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("cabeçalho inválido %q: formato esperado Nome:Valor", pair)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		if !isValidHeaderName(name) {
+			return nil, fmt.Errorf("nome de cabeçalho inválido: %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("valor do cabeçalho %s contém quebra de linha", name)
+		}
+		if reservedHeaders[http.CanonicalHeaderKey(name)] {
+			return nil, fmt.Errorf("cabeçalho %s é reservado e não pode ser configurado", name)
+		}
+
+		headers.Add(name, value)
+	}
+
+	return headers, nil
+}
+
+// HeaderNames lista apenas os nomes dos cabeçalhos, para logs sem expor valores.
+func HeaderNames(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ApplyHeaders adiciona cabeçalhos extras à requisição sem remover os existentes.
+func ApplyHeaders(req *http.Request, h http.Header) {
+	for name, values := range h {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+}
+
+// isValidHeaderName verifica se o nome é um token válido segundo a RFC 7230.
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 127 || r <= 32 || strings.ContainsRune("()<>@,;:\\\"/[]?={}", r) {
+			return false
+		}
+	}
+	return true
+}
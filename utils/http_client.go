@@ -1,19 +1,104 @@
 package utils
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/webchatcomllm/config"
 	"go.uber.org/zap"
 )
 
+var (
+	transportOnce   sync.Once
+	sharedTransport http.RoundTripper
+)
+
 // NewHTTPClient cria um cliente HTTP com LoggingTransport e timeout configurado.
 func NewHTTPClient(logger *zap.Logger, timeout time.Duration) *http.Client {
+	transportOnce.Do(func() {
+		sharedTransport = newBaseTransport(logger)
+	})
 	return &http.Client{
 		Transport: &LoggingTransport{
 			Logger:    logger,
-			Transport: http.DefaultTransport,
+			Transport: sharedTransport,
 		},
 		Timeout: timeout,
 	}
 }
+
+// newBaseTransport retorna o transporte padrão ou, com EXTRA_CA_CERTS definido, uma cópia
+// dele que confia também nos certificados informados (ex.: CA de um proxy corporativo).
+// Em caso de erro na leitura dos certificados, o transporte padrão é mantido.
+func newBaseTransport(logger *zap.Logger) http.RoundTripper {
+	path := config.GetEnvString("EXTRA_CA_CERTS", "")
+	if path == "" {
+		return http.DefaultTransport
+	}
+
+	pool, added, err := loadExtraCACerts(path)
+	if err != nil {
+		logger.Error("Falha ao carregar EXTRA_CA_CERTS, usando apenas as CAs do sistema",
+			zap.String("path", path), zap.Error(err))
+		return http.DefaultTransport
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	logger.Info("Certificados de CA adicionais carregados", zap.String("path", path), zap.Int("certificados", added))
+	return transport
+}
+
+// loadExtraCACerts acrescenta ao pool do sistema os certificados PEM do arquivo ou diretório
+// informado (arquivos .pem, .crt e .cer). Retorna o pool e o número de arquivos aceitos.
+func loadExtraCACerts(path string) (*x509.CertPool, int, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".pem", ".crt", ".cer":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+
+	added := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, 0, fmt.Errorf("nenhum certificado PEM válido em %s", file)
+		}
+		added++
+	}
+	if added == 0 {
+		return nil, 0, fmt.Errorf("nenhum certificado encontrado em %s", path)
+	}
+	return pool, added, nil
+}
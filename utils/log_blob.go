@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LogBlobPrefix marca os campos de log comprimidos por CompressLogField.
+const LogBlobPrefix = "gzip+base64:"
+
+// MaxLogFieldSize limita o texto expandido por DecompressLogField, para que um blob malicioso
+// (uma bomba gzip) não esgote a memória.
+const MaxLogFieldSize = 64 << 20
+
+// CompressLogField comprime com gzip os textos com threshold bytes ou mais, devolvendo-os em
+// base64 precedidos de LogBlobPrefix, para que prompts e respostas grandes não inflem os logs.
+// Textos menores, threshold <= 0 ou textos que não ficariam menores são devolvidos sem alteração,
+// exceto os que já começam com LogBlobPrefix: esses são sempre comprimidos, para que
+// DecompressLogField não os confunda com um blob.
+func CompressLogField(s string, threshold int) string {
+	ambiguous := strings.HasPrefix(s, LogBlobPrefix)
+	if !ambiguous && (threshold <= 0 || len(s) < threshold) {
+		return s
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		return s
+	}
+	if err := zw.Close(); err != nil {
+		return s
+	}
+	blob := LogBlobPrefix + base64.StdEncoding.EncodeToString(buf.Bytes())
+	if !ambiguous && len(blob) >= len(s) {
+		return s
+	}
+	return blob
+}
+
+// DecompressLogField reverte CompressLogField. Textos sem LogBlobPrefix são devolvidos sem
+// alteração; blobs que expandem além de MaxLogFieldSize são recusados.
+func DecompressLogField(s string) (string, error) {
+	encoded, ok := strings.CutPrefix(s, LogBlobPrefix)
+	if !ok {
+		return s, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("blob de log inválido: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("blob de log inválido: %w", err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(io.LimitReader(zr, MaxLogFieldSize+1))
+	if err != nil {
+		return "", fmt.Errorf("blob de log inválido: %w", err)
+	}
+	if len(out) > MaxLogFieldSize {
+		return "", fmt.Errorf("blob de log excede %d MB expandido", MaxLogFieldSize>>20)
+	}
+	return string(out), nil
+}
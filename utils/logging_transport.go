@@ -2,7 +2,6 @@ package utils
 
 import (
 	"net/http"
-	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -47,12 +46,3 @@ func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 
 	return resp, nil
 }
-
-// SanitizeSensitiveText remove/mascara tokens em qualquer texto.
-func SanitizeSensitiveText(s string) string {
-	// Implementação simples para headers, pode ser expandida
-	if strings.HasPrefix(s, "Bearer ") {
-		return "Bearer [REDACTED]"
-	}
-	return s
-}
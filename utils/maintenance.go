@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/webchatcomllm/metrics"
+)
+
+// maintenancePatterns identificam, no corpo de uma resposta 503, a manutenção anunciada pelo
+// provedor (em oposição a uma sobrecarga momentânea, que vale a pena repetir).
+var maintenancePatterns = []string{"maintenance", "manutenção", "manutencao", "scheduled downtime"}
+
+// isMaintenanceResponse indica se a resposta de erro sinaliza manutenção programada.
+func isMaintenanceResponse(statusCode int, body []byte) bool {
+	if statusCode != 503 {
+		return false
+	}
+	text := strings.ToLower(string(body))
+	for _, pattern := range maintenancePatterns {
+		if strings.Contains(text, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMaintenanceError indica se o erro é uma resposta de manutenção do provedor.
+func IsMaintenanceError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Maintenance
+}
+
+var (
+	providerBreakersMu sync.Mutex
+	providerBreakers   = make(map[string]*CircuitBreaker)
+	maintenanceUntil   = make(map[string]time.Time)
+)
+
+// ProviderBreaker retorna o circuit breaker compartilhado do provedor, criado na primeira
+// chamada com a configuração do provedor (LoadCircuitBreakerConfig).
+func ProviderBreaker(provider string) *CircuitBreaker {
+	provider = strings.ToUpper(provider)
+	providerBreakersMu.Lock()
+	defer providerBreakersMu.Unlock()
+	cb, ok := providerBreakers[provider]
+	if !ok {
+		cb = NewCircuitBreakerWithConfig(LoadCircuitBreakerConfig(provider))
+		cb.provider = provider
+		circuitState.Set(float64(CircuitClosed), metrics.Labels{"provider": provider})
+		providerBreakers[provider] = cb
+	}
+	return cb
+}
+
+// MarkProviderMaintenance abre o circuito do provedor durante cooldown, sem novas chamadas
+// até lá.
+func MarkProviderMaintenance(provider string, cooldown time.Duration) time.Time {
+	until := time.Now().Add(cooldown)
+	ProviderBreaker(provider).Trip(cooldown)
+	providerBreakersMu.Lock()
+	maintenanceUntil[strings.ToUpper(provider)] = until
+	providerBreakersMu.Unlock()
+	return until
+}
+
+// ProviderInMaintenance indica se o provedor está em manutenção e até quando.
+func ProviderInMaintenance(provider string) (time.Time, bool) {
+	provider = strings.ToUpper(provider)
+	providerBreakersMu.Lock()
+	until, ok := maintenanceUntil[provider]
+	providerBreakersMu.Unlock()
+	if !ok || time.Now().After(until) {
+		return time.Time{}, false
+	}
+	return until, ProviderBreaker(provider).GetState() == CircuitOpen
+}
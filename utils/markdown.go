@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mdHeaderPattern     = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	mdQuotePattern      = regexp.MustCompile(`^\s{0,3}>\s?`)
+	mdBulletPattern     = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	mdRulePattern       = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	mdTableSepPattern   = regexp.MustCompile(`^\s*\|?(\s*:?-+:?\s*\|)*\s*:?-+:?\s*\|?\s*$`)
+	mdImagePattern      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkPattern       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdInlineCodePattern = regexp.MustCompile("`([^`]+)`")
+	mdBoldPattern       = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	mdItalicStarPattern = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	// Itálico com "_" exige limites de palavra, preservando identificadores como snake_case
+	mdItalicUnderPattern = regexp.MustCompile(`(^|[^\w])_(\S(?:[^_]*?\S)?)_([^\w]|$)`)
+	mdStrikePattern      = regexp.MustCompile(`~~(.+?)~~`)
+	mdBlankLinesPattern  = regexp.MustCompile(`\n{3,}`)
+)
+
+// StripMarkdown converte uma resposta em Markdown para texto puro, removendo cercas de código,
+// cabeçalhos, ênfases e marcações de tabela, mas preservando o conteúdo dos blocos de código.
+// O único parser de Markdown do projeto é o marked.js do frontend; no servidor o módulo não
+// tem parser de Markdown, e a conversão é feita linha a linha para não adicionar dependência.
+func StripMarkdown(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+
+		if strings.Contains(line, "|") && mdTableSepPattern.MatchString(line) {
+			continue
+		}
+		if mdRulePattern.MatchString(line) {
+			out = append(out, "")
+			continue
+		}
+
+		line = mdHeaderPattern.ReplaceAllString(line, "")
+		line = mdQuotePattern.ReplaceAllString(line, "")
+		line = mdBulletPattern.ReplaceAllString(line, "$1- ")
+
+		if strings.HasPrefix(trimmed, "|") && strings.HasSuffix(trimmed, "|") {
+			cells := strings.Split(strings.Trim(trimmed, "|"), "|")
+			for i := range cells {
+				cells[i] = strings.TrimSpace(cells[i])
+			}
+			line = strings.Join(cells, " | ")
+		}
+
+		out = append(out, stripInlineMarkdown(line))
+	}
+
+	result := mdBlankLinesPattern.ReplaceAllString(strings.Join(out, "\n"), "\n\n")
+	return strings.TrimSpace(result)
+}
+
+// stripInlineMarkdown remove as marcações de uma única linha fora de blocos de código.
+func stripInlineMarkdown(line string) string {
+	line = mdImagePattern.ReplaceAllString(line, "$1")
+	line = mdLinkPattern.ReplaceAllStringFunc(line, func(m string) string {
+		parts := mdLinkPattern.FindStringSubmatch(m)
+		if parts[1] == parts[2] {
+			return parts[1]
+		}
+		return parts[1] + " (" + parts[2] + ")"
+	})
+
+	// Código inline é extraído antes das ênfases para que "*" e "_" dentro dele sejam preservados
+	var codes []string
+	line = mdInlineCodePattern.ReplaceAllStringFunc(line, func(m string) string {
+		codes = append(codes, mdInlineCodePattern.FindStringSubmatch(m)[1])
+		return "\x00" + strconv.Itoa(len(codes)-1) + "\x00"
+	})
+
+	line = mdBoldPattern.ReplaceAllString(line, "$2")
+	line = mdStrikePattern.ReplaceAllString(line, "$1")
+	line = mdItalicStarPattern.ReplaceAllString(line, "$1")
+	line = mdItalicUnderPattern.ReplaceAllString(line, "$1$2$3")
+
+	for i, code := range codes {
+		line = strings.Replace(line, "\x00"+strconv.Itoa(i)+"\x00", code, 1)
+	}
+	return line
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MetadataOCRText é a chave dos metadados com o texto extraído de uma imagem por OCR.
+const MetadataOCRText = "ocrText"
+
+// ocrTimeout limita o tempo de OCR de cada imagem, dentro do prazo da requisição.
+const ocrTimeout = 30 * time.Second
+
+// OCREngine extrai o texto de uma imagem.
+type OCREngine interface {
+	ExtractText(ctx context.Context, image []byte) (string, error)
+}
+
+// TesseractOCR executa o binário tesseract, que precisa estar instalado no PATH.
+type TesseractOCR struct {
+	// Languages são os idiomas do Tesseract (ex.: "por+eng"); vazio usa o padrão da instalação
+	Languages string
+}
+
+// ExtractText envia a imagem ao tesseract pela entrada padrão e lê o texto da saída padrão.
+func (t TesseractOCR) ExtractText(ctx context.Context, image []byte) (string, error) {
+	path, err := exec.LookPath("tesseract")
+	if err != nil {
+		return "", fmt.Errorf("tesseract não encontrado no PATH: %w", err)
+	}
+	args := []string{"stdin", "stdout"}
+	if t.Languages != "" {
+		args = append(args, "-l", t.Languages)
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = bytes.NewReader(image)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("falha no tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// WithOCR substitui o mecanismo de OCR usado quando EnableOCR está ativo.
+func (fp *FileProcessor) WithOCR(engine OCREngine) *FileProcessor {
+	fp.ocr = engine
+	return fp
+}
+
+// applyOCR extrai o texto da imagem para os metadados, respeitando MaxCharsPerFile. O OCR é
+// opcional: falhas são registradas e a imagem segue sem o texto. O contexto da requisição
+// interrompe o OCR quando ela expira ou é cancelada, sem deixar o tesseract executando.
+func (fp *FileProcessor) applyOCR(pf *ProcessedFile, content []byte) {
+	if !fp.config.EnableOCR || fp.ocr == nil {
+		return
+	}
+
+	parent := pf.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	if parent.Err() != nil {
+		fp.logger.Debug("OCR ignorado: requisição encerrada", zap.String("name", pf.Name), zap.Error(parent.Err()))
+		return
+	}
+	ctx, cancel := context.WithTimeout(parent, ocrTimeout)
+	defer cancel()
+	start := time.Now()
+	text, err := fp.ocr.ExtractText(ctx, content)
+	if err != nil {
+		fp.logger.Warn("OCR da imagem falhou, seguindo apenas com a imagem",
+			zap.String("name", pf.Name),
+			zap.Error(err),
+		)
+		return
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if limit := fp.config.MaxCharsPerFile; limit > 0 && len(text) > limit {
+		text = TruncateUTF8(text, limit) + "\n... (texto do OCR truncado)"
+	}
+	pf.Metadata[MetadataOCRText] = text
+	fp.logger.Info("Texto extraído da imagem por OCR",
+		zap.String("name", pf.Name),
+		zap.Int("chars", len(text)),
+		zap.Duration("duration", time.Since(start)),
+	)
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+)
+
+var (
+	// ErrPasswordProtected indica um arquivo criptografado sem senha informada.
+	ErrPasswordProtected = errors.New("arquivo protegido por senha")
+	// ErrWrongPassword indica que a senha informada não abriu o arquivo.
+	ErrWrongPassword = errors.New("senha incorreta para o arquivo protegido")
+)
+
+// oleSignature é a assinatura de contêineres OLE (Compound File Binary), formato usado pelo
+// Office para salvar documentos OOXML criptografados.
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// errInvalidOfficeFormat indica um contêiner OLE sem o stream EncryptionInfo, como os
+// formatos binários antigos (.doc, .xls) renomeados para a extensão OOXML.
+var errInvalidOfficeFormat = errors.New("formato inválido: contêiner OLE sem criptografia (formato Office antigo?)")
+
+// isOLECompound verifica a assinatura de contêiner OLE
+func isOLECompound(content []byte) bool {
+	return bytes.HasPrefix(content, oleSignature)
+}
+
+// isEncryptedOffice indica um documento OOXML criptografado: um contêiner OLE com o stream
+// EncryptionInfo.
+func isEncryptedOffice(content []byte) bool {
+	return isOLECompound(content) && oleHasStream(content, "EncryptionInfo")
+}
+
+// oleEndOfChain marca o fim de uma cadeia de setores na FAT.
+const oleEndOfChain = 0xFFFFFFFE
+
+// oleHasStream procura uma entrada com o nome informado no diretório do contêiner OLE,
+// seguindo a cadeia de setores do diretório pela FAT. Contêineres malformados retornam false.
+func oleHasStream(content []byte, name string) bool {
+	if len(content) < 512 {
+		return false
+	}
+	shift := binary.LittleEndian.Uint16(content[30:32])
+	if shift != 9 && shift != 12 {
+		return false
+	}
+	sectorSize := 1 << shift
+	sector := func(id uint32) []byte {
+		start := (int(id) + 1) * sectorSize
+		if id >= oleEndOfChain || start+sectorSize > len(content) {
+			return nil
+		}
+		return content[start : start+sectorSize]
+	}
+
+	// A FAT é lida pelos 109 setores listados no cabeçalho, suficientes para os documentos
+	// aceitos por MaxDocSize
+	var fat []uint32
+	for i := 0; i < 109; i++ {
+		id := binary.LittleEndian.Uint32(content[76+4*i:])
+		data := sector(id)
+		if data == nil {
+			break
+		}
+		for j := 0; j+4 <= len(data); j += 4 {
+			fat = append(fat, binary.LittleEndian.Uint32(data[j:]))
+		}
+	}
+
+	id := binary.LittleEndian.Uint32(content[48:52])
+	for steps := 0; steps < len(fat) && id < uint32(len(fat)); steps++ {
+		data := sector(id)
+		if data == nil {
+			return false
+		}
+		for off := 0; off+128 <= len(data); off += 128 {
+			if oleEntryName(data[off:off+128]) == name {
+				return true
+			}
+		}
+		id = fat[id]
+	}
+	return false
+}
+
+// oleEntryName decodifica o nome UTF-16LE de uma entrada de diretório OLE.
+func oleEntryName(entry []byte) string {
+	size := int(binary.LittleEndian.Uint16(entry[64:66]))
+	if size < 2 || size > 64 {
+		return ""
+	}
+	units := make([]uint16, size/2-1) // o tamanho inclui o terminador nulo
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(entry[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// zipIsEncrypted verifica se alguma entrada do ZIP usa criptografia (bit 0 das flags)
+func zipIsEncrypted(zr *zip.Reader) bool {
+	for _, f := range zr.File {
+		if f.Flags&0x1 != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// protectedFileError diferencia arquivo sem senha de senha incorreta
+func protectedFileError(password string) error {
+	if password == "" {
+		return ErrPasswordProtected
+	}
+	return ErrWrongPassword
+}
+
+// passwordOnce fornece a senha uma única vez ao leitor de PDF, que a solicita repetidamente
+// até receber uma string vazia.
+func passwordOnce(password string) func() string {
+	used := false
+	return func() string {
+		if used {
+			return ""
+		}
+		used = true
+		return password
+	}
+}
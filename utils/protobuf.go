@@ -0,0 +1,381 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Leitura mínima do formato binário do protobuf, suficiente para interpretar um
+// FileDescriptorSet (saída de "protoc --descriptor_set_out") sem depender da biblioteca oficial.
+
+// protoField é um campo decodificado de uma mensagem protobuf.
+type protoField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+var errInvalidProto = errors.New("protobuf inválido")
+
+// decodeProto separa os campos de uma mensagem. Campos fixed32/fixed64 são ignorados,
+// pois não aparecem nas partes do descritor que usamos.
+func decodeProto(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errInvalidProto
+		}
+		data = data[n:]
+		field := protoField{num: int(tag >> 3)}
+		if field.num == 0 {
+			return nil, errInvalidProto
+		}
+
+		switch tag & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errInvalidProto
+			}
+			field.varint = v
+			data = data[n:]
+		case 1: // fixed64
+			if len(data) < 8 {
+				return nil, errInvalidProto
+			}
+			data = data[8:]
+			continue
+		case 2: // length-delimited
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return nil, errInvalidProto
+			}
+			field.bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		case 5: // fixed32
+			if len(data) < 4 {
+				return nil, errInvalidProto
+			}
+			data = data[4:]
+			continue
+		default:
+			return nil, errInvalidProto
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// protoScalarTypes mapeia FieldDescriptorProto.Type para o nome usado em arquivos .proto.
+var protoScalarTypes = map[uint64]string{
+	1: "double", 2: "float", 3: "int64", 4: "uint64", 5: "int32", 6: "fixed64",
+	7: "fixed32", 8: "bool", 9: "string", 10: "group", 12: "bytes", 13: "uint32",
+	15: "sfixed32", 16: "sfixed64", 17: "sint32", 18: "sint64",
+}
+
+// descriptorSummary acumula a renderização e as contagens do descritor.
+type descriptorSummary struct {
+	b        strings.Builder
+	files    int
+	messages int
+	enums    int
+	services int
+	methods  int
+}
+
+// summarizeDescriptorSet renderiza um FileDescriptorSet em sintaxe semelhante à de um .proto.
+func summarizeDescriptorSet(data []byte) (*descriptorSummary, error) {
+	fields, err := decodeProto(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &descriptorSummary{}
+	for _, f := range fields {
+		if f.num != 1 || f.bytes == nil {
+			return nil, errInvalidProto
+		}
+		if err := s.writeFile(f.bytes); err != nil {
+			return nil, err
+		}
+	}
+	if s.files == 0 {
+		return nil, errInvalidProto
+	}
+	return s, nil
+}
+
+// writeFile renderiza um FileDescriptorProto.
+func (s *descriptorSummary) writeFile(data []byte) error {
+	fields, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+
+	var name, pkg, syntax string
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			name = string(f.bytes)
+		case 2:
+			pkg = string(f.bytes)
+		case 12:
+			syntax = string(f.bytes)
+		}
+	}
+	if name == "" {
+		return errInvalidProto
+	}
+	if syntax == "" {
+		syntax = "proto2"
+	}
+
+	s.files++
+	s.b.WriteString(fmt.Sprintf("// arquivo: %s\nsyntax = %q;\n", name, syntax))
+	if pkg != "" {
+		s.b.WriteString(fmt.Sprintf("package %s;\n", pkg))
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 3:
+			s.b.WriteString(fmt.Sprintf("import %q;\n", string(f.bytes)))
+		}
+	}
+	s.b.WriteString("\n")
+
+	for _, f := range fields {
+		switch f.num {
+		case 4:
+			if err := s.writeMessage(f.bytes, ""); err != nil {
+				return err
+			}
+		case 5:
+			if err := s.writeEnum(f.bytes, ""); err != nil {
+				return err
+			}
+		case 6:
+			if err := s.writeService(f.bytes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// maxProtoNesting limita o aninhamento de mensagens, para que um descritor malicioso não
+// esgote a pilha.
+const maxProtoNesting = 64
+
+// writeMessage renderiza um DescriptorProto, incluindo mensagens e enums aninhados.
+func (s *descriptorSummary) writeMessage(data []byte, indent string) error {
+	// Cada nível de aninhamento acrescenta dois espaços à indentação
+	if len(indent)/2 >= maxProtoNesting {
+		return fmt.Errorf("%w: mensagens aninhadas além de %d níveis", errInvalidProto, maxProtoNesting)
+	}
+	fields, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+
+	s.messages++
+	name := ""
+	for _, f := range fields {
+		if f.num == 1 {
+			name = string(f.bytes)
+		}
+	}
+	s.b.WriteString(fmt.Sprintf("%smessage %s {\n", indent, name))
+	for _, f := range fields {
+		var err error
+		switch f.num {
+		case 2:
+			err = s.writeField(f.bytes, indent+"  ")
+		case 3:
+			err = s.writeMessage(f.bytes, indent+"  ")
+		case 4:
+			err = s.writeEnum(f.bytes, indent+"  ")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.closeBlock(indent)
+	return nil
+}
+
+// closeBlock fecha um bloco, separando com linha em branco apenas as declarações de primeiro nível.
+func (s *descriptorSummary) closeBlock(indent string) {
+	s.b.WriteString(indent + "}\n")
+	if indent == "" {
+		s.b.WriteString("\n")
+	}
+}
+
+// writeField renderiza um FieldDescriptorProto.
+func (s *descriptorSummary) writeField(data []byte, indent string) error {
+	fields, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+
+	var name, typeName string
+	var number, label, typ uint64
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			name = string(f.bytes)
+		case 3:
+			number = f.varint
+		case 4:
+			label = f.varint
+		case 5:
+			typ = f.varint
+		case 6:
+			typeName = strings.TrimPrefix(string(f.bytes), ".")
+		}
+	}
+
+	if typeName == "" {
+		typeName = protoScalarTypes[typ]
+	}
+	prefix := ""
+	switch label {
+	case 2:
+		prefix = "required "
+	case 3:
+		prefix = "repeated "
+	}
+	s.b.WriteString(fmt.Sprintf("%s%s%s %s = %d;\n", indent, prefix, typeName, name, number))
+	return nil
+}
+
+// writeEnum renderiza um EnumDescriptorProto.
+func (s *descriptorSummary) writeEnum(data []byte, indent string) error {
+	fields, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+
+	s.enums++
+	for _, f := range fields {
+		if f.num == 1 {
+			s.b.WriteString(fmt.Sprintf("%senum %s {\n", indent, string(f.bytes)))
+		}
+	}
+	for _, f := range fields {
+		if f.num != 2 {
+			continue
+		}
+		values, err := decodeProto(f.bytes)
+		if err != nil {
+			return err
+		}
+		var name string
+		var number int32
+		for _, v := range values {
+			switch v.num {
+			case 1:
+				name = string(v.bytes)
+			case 2:
+				number = int32(v.varint)
+			}
+		}
+		s.b.WriteString(fmt.Sprintf("%s  %s = %d;\n", indent, name, number))
+	}
+	s.closeBlock(indent)
+	return nil
+}
+
+// writeService renderiza um ServiceDescriptorProto com seus métodos.
+func (s *descriptorSummary) writeService(data []byte) error {
+	fields, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+
+	s.services++
+	for _, f := range fields {
+		if f.num == 1 {
+			s.b.WriteString(fmt.Sprintf("service %s {\n", string(f.bytes)))
+		}
+	}
+	for _, f := range fields {
+		if f.num != 2 {
+			continue
+		}
+		method, err := decodeProto(f.bytes)
+		if err != nil {
+			return err
+		}
+		var name, input, output string
+		var clientStream, serverStream bool
+		for _, m := range method {
+			switch m.num {
+			case 1:
+				name = string(m.bytes)
+			case 2:
+				input = strings.TrimPrefix(string(m.bytes), ".")
+			case 3:
+				output = strings.TrimPrefix(string(m.bytes), ".")
+			case 5:
+				clientStream = m.varint != 0
+			case 6:
+				serverStream = m.varint != 0
+			}
+		}
+		if clientStream {
+			input = "stream " + input
+		}
+		if serverStream {
+			output = "stream " + output
+		}
+		s.methods++
+		s.b.WriteString(fmt.Sprintf("  rpc %s(%s) returns (%s);\n", name, input, output))
+	}
+	s.b.WriteString("}\n\n")
+	return nil
+}
+
+// isDescriptorSet identifica descritores compilados pela extensão
+func (fp *FileProcessor) isDescriptorSet(ext string) bool {
+	switch ext {
+	case ".desc", ".pb", ".binpb", ".protoset":
+		return true
+	}
+	return false
+}
+
+// processDescriptorSet converte um FileDescriptorSet em um resumo legível das mensagens e
+// serviços. Arquivos que não forem descritores válidos (".pb" também é usado por outros
+// formatos) seguem como binários.
+func (fp *FileProcessor) processDescriptorSet(pf *ProcessedFile, content []byte) (*ProcessedFile, error) {
+	summary, err := summarizeDescriptorSet(content)
+	if err != nil {
+		fp.logger.Debug("Arquivo não é um FileDescriptorSet válido", zap.String("name", pf.Name), zap.Error(err))
+		return fp.processBinary(pf, content)
+	}
+
+	pf.FileType = FileTypeCode
+	pf.IsBase64 = false
+	pf.Content = strings.TrimRight(summary.b.String(), "\n")
+	pf.Metadata["language"] = "protobuf"
+	pf.Metadata["descriptorSet"] = true
+	pf.Metadata["protoFiles"] = summary.files
+	pf.Metadata["messages"] = summary.messages
+	pf.Metadata["enums"] = summary.enums
+	pf.Metadata["services"] = summary.services
+	pf.Metadata["methods"] = summary.methods
+
+	fp.logger.Debug("Descritor protobuf processado",
+		zap.String("name", pf.Name),
+		zap.Int("files", summary.files),
+		zap.Int("messages", summary.messages),
+		zap.Int("services", summary.services),
+	)
+	return pf, nil
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/webchatcomllm/config"
+)
+
+// Comportamentos de PROVIDER_CONCURRENCY_MODE quando o limite do provedor está ocupado.
+const (
+	ConcurrencyWait   = "wait"
+	ConcurrencyReject = "reject"
+)
+
+// providerSlots limita as chamadas simultâneas a um provedor em todo o servidor. Um valor com
+// slots nil não impõe limite.
+type providerSlots struct {
+	slots  chan struct{}
+	reject bool
+}
+
+var (
+	providerSlotsMu sync.Mutex
+	providerLimits  = make(map[string]*providerSlots)
+)
+
+// loadProviderSlots lê <PROVEDOR>_MAX_CONCURRENCY (0 não limita) e PROVIDER_CONCURRENCY_MODE,
+// que aceita o prefixo do provedor (ex.: OPENAI_PROVIDER_CONCURRENCY_MODE).
+func loadProviderSlots(provider string) *providerSlots {
+	max := config.GetEnvInt(provider+"_MAX_CONCURRENCY", 0)
+	mode := config.GetEnvString("PROVIDER_CONCURRENCY_MODE", ConcurrencyWait)
+	mode = strings.ToLower(config.GetEnvString(provider+"_PROVIDER_CONCURRENCY_MODE", mode))
+
+	ps := &providerSlots{reject: mode == ConcurrencyReject}
+	if max > 0 {
+		ps.slots = make(chan struct{}, max)
+	}
+	return ps
+}
+
+func providerSlotsFor(provider string) *providerSlots {
+	provider = strings.ToUpper(provider)
+	providerSlotsMu.Lock()
+	defer providerSlotsMu.Unlock()
+	ps, ok := providerLimits[provider]
+	if !ok {
+		ps = loadProviderSlots(provider)
+		providerLimits[provider] = ps
+	}
+	return ps
+}
+
+type queueNotifierKey struct{}
+
+// WithQueueNotifier anexa ao contexto uma função chamada quando a requisição precisa aguardar
+// uma vaga no limite de concorrência do provedor, para informar o usuário.
+func WithQueueNotifier(ctx context.Context, notify func(message string)) context.Context {
+	return context.WithValue(ctx, queueNotifierKey{}, notify)
+}
+
+// AcquireProviderSlot ocupa uma vaga no limite de chamadas simultâneas do provedor e retorna a
+// função que a libera. Com o limite ocupado, aguarda até o fim do contexto ou, no modo reject,
+// falha imediatamente com um erro da categoria rate_limit.
+func AcquireProviderSlot(ctx context.Context, provider string) (func(), error) {
+	ps := providerSlotsFor(provider)
+	if ps.slots == nil {
+		return func() {}, nil
+	}
+	release := func() { <-ps.slots }
+
+	select {
+	case ps.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if ps.reject {
+		return nil, &CategorizedError{
+			Category: ErrorCategoryRateLimit,
+			Err: fmt.Errorf("limite de %d requisições simultâneas ao provedor %s atingido; tente novamente em instantes",
+				cap(ps.slots), provider),
+		}
+	}
+
+	if notify, ok := ctx.Value(queueNotifierKey{}).(func(string)); ok {
+		notify(fmt.Sprintf("Aguardando vaga no provedor %s (%d requisições simultâneas em andamento)", provider, cap(ps.slots)))
+	}
+	select {
+	case ps.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, CategorizeError(ctx.Err())
+	}
+}
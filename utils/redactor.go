@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Padrões de dados sensíveis mascarados antes de expor conteúdo em logs ou respostas de debug.
+var piiPatterns = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`), "Bearer [REDACTED]"},
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}\b`), "[API_KEY]"},
+	{regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`\b\d{2}\.?\d{3}\.?\d{3}/?\d{4}-?\d{2}\b`), "[CNPJ]"},
+	{regexp.MustCompile(`\b\d{3}\.\d{3}\.\d{3}-\d{2}\b`), "[CPF]"},
+	{regexp.MustCompile(`\b(?:\d[ \-]?){13,16}\b`), "[CARTAO]"},
+}
+
+var dataURIPattern = regexp.MustCompile(`data:([a-zA-Z0-9.+\-/]+);base64,[A-Za-z0-9+/=]+`)
+
+// RedactPII mascara e-mails, documentos, cartões e credenciais em um texto.
+func RedactPII(s string) string {
+	for _, p := range piiPatterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// SanitizeSensitiveText mascara tokens e dados sensíveis em qualquer texto, com as mesmas
+// regras de RedactPII.
+func SanitizeSensitiveText(s string) string {
+	return RedactPII(s)
+}
+
+// CollapseDataURIs substitui o conteúdo de data URIs base64 por um marcador com o tamanho original.
+func CollapseDataURIs(s string) string {
+	return dataURIPattern.ReplaceAllStringFunc(s, func(match string) string {
+		sub := dataURIPattern.FindStringSubmatch(match)
+		return fmt.Sprintf("data:%s;base64,[%d caracteres omitidos]", sub[1], len(match)-len(sub[1])-len("data:;base64,"))
+	})
+}
+
+// RedactBody prepara o corpo bruto de uma resposta para logs e metadados de debug: omite
+// data URIs, limita o tamanho a maxLen bytes e mascara dados sensíveis.
+func RedactBody(body []byte, maxLen int) string {
+	s := CollapseDataURIs(string(body))
+	if maxLen > 0 && len(s) > maxLen {
+		s = TruncateUTF8(s, maxLen) + fmt.Sprintf("... [%d bytes omitidos]", len(s)-maxLen)
+	}
+	return RedactPII(s)
+}
@@ -5,8 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/webchatcomllm/config"
 	"go.uber.org/zap"
 )
 
@@ -14,6 +19,44 @@ import (
 type APIError struct {
 	StatusCode int
 	Message    string
+	// Provider e Code identificam o código de erro específico do provedor (ex.: OPENAI,
+	// "rate_limit_exceeded"), quando informado no corpo da resposta
+	Provider string
+	Code     string
+	// Maintenance indica uma resposta 503 em que o provedor anuncia manutenção
+	Maintenance bool
+	// RetryAfter é a espera pedida pelo cabeçalho Retry-After da resposta; 0 quando ausente
+	RetryAfter time.Duration
+}
+
+// MaxRetryAfter limita a espera pedida por Retry-After: acima dela o erro é devolvido sem
+// nova tentativa, em vez de prender a requisição.
+var MaxRetryAfter = 60 * time.Second
+
+// WithRetryAfter lê o cabeçalho Retry-After da resposta, nas formas em segundos e data HTTP.
+func (e *APIError) WithRetryAfter(header http.Header) *APIError {
+	e.RetryAfter = ParseRetryAfter(header.Get("Retry-After"), time.Now())
+	return e
+}
+
+// ParseRetryAfter interpreta o valor de Retry-After em segundos ("120") ou como data HTTP
+// ("Wed, 21 Oct 2015 07:28:00 GMT"), relativa a now. Valores inválidos ou no passado
+// resultam em 0.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
 }
 
 func (e *APIError) Error() string {
@@ -31,17 +74,33 @@ func Retry[T any](ctx context.Context, logger *zap.Logger, maxAttempts int, init
 			return res, nil
 		}
 
-		if IsTemporaryError(err) {
-			if attempt < maxAttempts {
-				logger.Warn("Erro temporário, tentando novamente...",
-					zap.Int("tentativa", attempt),
-					zap.Int("max_tentativas", maxAttempts),
-					zap.Duration("espera", backoff),
-					zap.Error(err))
-				time.Sleep(backoff)
-				backoff *= 2 // Backoff exponencial
-				continue
+		if IsTemporaryError(err) && attempt < maxAttempts {
+			wait := backoff
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+				if apiErr.RetryAfter > MaxRetryAfter {
+					logger.Warn("Retry-After excede o limite, sem nova tentativa",
+						zap.Duration("retry_after", apiErr.RetryAfter),
+						zap.Duration("limite", MaxRetryAfter))
+					return zero, err
+				}
+				wait = apiErr.RetryAfter
 			}
+			logger.Warn("Erro temporário, tentando novamente...",
+				zap.Int("tentativa", attempt),
+				zap.Int("max_tentativas", maxAttempts),
+				zap.Duration("espera", wait),
+				zap.Error(err))
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return zero, err
+			case <-timer.C:
+			}
+			backoff *= 2 // Backoff exponencial
+			continue
 		}
 
 		// Erro permanente ou última tentativa
@@ -60,9 +119,57 @@ func IsTemporaryError(err error) bool {
 
 	var apiErr *APIError
 	if errors.As(err, &apiErr) {
-		// Retry para Rate Limit (429) e Erros de Servidor (5xx)
-		return apiErr.StatusCode == 429 || (apiErr.StatusCode >= 500 && apiErr.StatusCode < 600)
+		// Em manutenção, repetir só sobrecarrega o provedor
+		if apiErr.Maintenance {
+			return false
+		}
+		// Códigos configurados em RETRYABLE_ERROR_CODES/NON_RETRYABLE_ERROR_CODES têm precedência
+		if retry, ok := errorCodeRetryable(apiErr); ok {
+			return retry
+		}
+		return statusRetryable(apiErr.StatusCode)
 	}
 
 	return false
 }
+
+var (
+	retryableStatusMu sync.RWMutex
+	// retryableStatus substitui a regra padrão quando RETRYABLE_STATUS_CODES é definida
+	retryableStatus map[int]bool
+)
+
+// ConfigureRetryableStatusCodes carrega RETRYABLE_STATUS_CODES, a lista de status HTTP com
+// retry (ex.: "429,500,502,503,504,529"). Sem a variável vale a regra padrão: 429 e 5xx,
+// incluindo o 529 "Overloaded" da Anthropic. Retorna erro para status inválidos.
+func ConfigureRetryableStatusCodes() error {
+	entries := config.GetEnvList("RETRYABLE_STATUS_CODES", nil)
+	var statuses map[int]bool
+	if len(entries) > 0 {
+		statuses = make(map[int]bool, len(entries))
+		for _, entry := range entries {
+			code, err := strconv.Atoi(entry)
+			if err != nil || code < 100 || code > 599 {
+				return fmt.Errorf("RETRYABLE_STATUS_CODES: status HTTP inválido: %q", entry)
+			}
+			statuses[code] = true
+		}
+	}
+
+	retryableStatusMu.Lock()
+	retryableStatus = statuses
+	retryableStatusMu.Unlock()
+	return nil
+}
+
+// statusRetryable aplica a lista configurada ou, sem ela, retry para Rate Limit (429) e erros
+// de servidor (5xx).
+func statusRetryable(status int) bool {
+	retryableStatusMu.RLock()
+	statuses := retryableStatus
+	retryableStatusMu.RUnlock()
+	if statuses != nil {
+		return statuses[status]
+	}
+	return status == 429 || (status >= 500 && status < 600)
+}
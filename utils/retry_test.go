@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRetryOverloaded529(t *testing.T) {
+	overloaded := &APIError{StatusCode: 529, Message: "Overloaded", Provider: "CLAUDE"}
+	if !IsTemporaryError(overloaded) {
+		t.Fatal("529 deveria ser temporário")
+	}
+
+	const maxAttempts = 4
+	calls := 0
+	_, err := Retry(context.Background(), zap.NewNop(), maxAttempts, time.Millisecond, func(context.Context) (string, error) {
+		calls++
+		return "", overloaded
+	})
+	if calls != maxAttempts {
+		t.Fatalf("chamadas = %d, want %d", calls, maxAttempts)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 529 {
+		t.Fatalf("erro = %v, want o 529 da última tentativa", err)
+	}
+}
+
+func TestRetry529ThenSuccess(t *testing.T) {
+	calls := 0
+	res, err := Retry(context.Background(), zap.NewNop(), 3, time.Millisecond, func(context.Context) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", &APIError{StatusCode: 529, Message: "Overloaded"}
+		}
+		return "ok", nil
+	})
+	if err != nil || res != "ok" || calls != 2 {
+		t.Fatalf("res = %q, err = %v, chamadas = %d", res, err, calls)
+	}
+}
+
+func TestRetryableStatusCodesOverride(t *testing.T) {
+	t.Setenv("RETRYABLE_STATUS_CODES", "503")
+	if err := ConfigureRetryableStatusCodes(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		t.Setenv("RETRYABLE_STATUS_CODES", "")
+		ConfigureRetryableStatusCodes()
+	}()
+
+	if IsTemporaryError(&APIError{StatusCode: 529}) {
+		t.Fatal("529 fora da lista configurada não deveria ter retry")
+	}
+	calls := 0
+	Retry(context.Background(), zap.NewNop(), 3, time.Millisecond, func(context.Context) (string, error) {
+		calls++
+		return "", &APIError{StatusCode: 529}
+	})
+	if calls != 1 {
+		t.Fatalf("chamadas = %d, want 1", calls)
+	}
+}
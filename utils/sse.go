@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxSSELineSize limita o tamanho de uma linha do stream; eventos com trechos longos
+// excedem o buffer padrão do bufio.Scanner.
+const maxSSELineSize = 1024 * 1024
+
+// ReadSSE lê um corpo text/event-stream e chama fn para cada evento com o nome ("message"
+// quando omitido) e os dados acumulados. Um erro retornado por fn interrompe a leitura e é
+// repassado; io.EOF retornado por fn encerra a leitura sem erro.
+func ReadSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxSSELineSize)
+
+	event := ""
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		name := event
+		if name == "" {
+			name = "message"
+		}
+		err := fn(name, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comentário, usado como keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := dispatch(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// decodeTextBOM remove o BOM do início do conteúdo, convertendo UTF-16 para UTF-8. Retorna o
+// nome da codificação indicada pelo BOM, ou "" quando não há BOM.
+func decodeTextBOM(content []byte) ([]byte, string) {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		return content[len(bomUTF8):], "UTF-8"
+	case bytes.HasPrefix(content, bomUTF16LE):
+		return decodeUTF16(content[2:], binary.LittleEndian), "UTF-16LE"
+	case bytes.HasPrefix(content, bomUTF16BE):
+		return decodeUTF16(content[2:], binary.BigEndian), "UTF-16BE"
+	}
+	return content, ""
+}
+
+func decodeUTF16(content []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// normalizeText remove o BOM, converte quebras de linha CRLF/CR para LF e, com
+// MaxBlankLines > 0, reduz sequências de linhas em branco a esse tamanho. Conteúdo com bytes
+// nulos (provavelmente binário) não é alterado. O que foi aplicado é registrado nos metadados.
+func (fp *FileProcessor) normalizeText(pf *ProcessedFile, content []byte) []byte {
+	if !fp.config.NormalizeText {
+		return content
+	}
+
+	content, bom := decodeTextBOM(content)
+	if bom != "" {
+		pf.Metadata["bomRemoved"] = bom
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return content
+	}
+
+	if bytes.IndexByte(content, '\r') >= 0 {
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+		pf.Metadata["lineEndingsNormalized"] = true
+	}
+
+	if fp.config.MaxBlankLines > 0 {
+		var collapsed int
+		content, collapsed = collapseBlankLines(content, fp.config.MaxBlankLines)
+		if collapsed > 0 {
+			pf.Metadata["blankLinesRemoved"] = collapsed
+		}
+	}
+	return content
+}
+
+// collapseBlankLines mantém no máximo max linhas em branco seguidas (linhas só com espaços
+// contam como em branco) e retorna quantas foram removidas.
+func collapseBlankLines(content []byte, max int) ([]byte, int) {
+	lines := strings.Split(string(content), "\n")
+	out := lines[:0]
+	blank, removed := 0, 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			blank++
+			if blank > max {
+				removed++
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+	if removed == 0 {
+		return content, 0
+	}
+	return []byte(strings.Join(out, "\n")), removed
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Fases medidas por RequestTimings.
+const (
+	PhaseTokenRefresh   = "tokenRefresh"
+	PhaseFileProcessing = "fileProcessing"
+	PhaseTTFB           = "ttfb"
+	PhaseLLM            = "llm"
+	PhaseTotal          = "total"
+)
+
+// RequestTimings acumula a duração das fases de uma requisição, para diferenciar o tempo gasto
+// pelo servidor do tempo de resposta do provedor. Um valor nil ignora as medições.
+type RequestTimings struct {
+	mu     sync.Mutex
+	phases map[string]time.Duration
+	wrote  time.Time
+}
+
+type timingsKey struct{}
+
+// NewRequestTimings cria uma medição vazia.
+func NewRequestTimings() *RequestTimings {
+	return &RequestTimings{phases: make(map[string]time.Duration)}
+}
+
+// WithRequestTimings anexa a medição ao contexto. As requisições HTTP feitas com ele registram o
+// TTFB (do envio da requisição ao primeiro byte da resposta); com várias requisições, como a
+// renovação do token seguida da chamada ao provedor ou novas tentativas, vale a última.
+func WithRequestTimings(ctx context.Context, t *RequestTimings) context.Context {
+	if t == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, timingsKey{}, t)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.wrote = time.Now()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			if !t.wrote.IsZero() {
+				t.phases[PhaseTTFB] = time.Since(t.wrote)
+			}
+			t.mu.Unlock()
+		},
+	})
+}
+
+// RequestTimingsFrom retorna a medição anexada ao contexto, ou nil.
+func RequestTimingsFrom(ctx context.Context) *RequestTimings {
+	t, _ := ctx.Value(timingsKey{}).(*RequestTimings)
+	return t
+}
+
+// RecordPhase soma d à fase da medição anexada ao contexto, se houver.
+func RecordPhase(ctx context.Context, phase string, d time.Duration) {
+	RequestTimingsFrom(ctx).Add(phase, d)
+}
+
+// Add soma d à fase.
+func (t *RequestTimings) Add(phase string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.phases[phase] += d
+	t.mu.Unlock()
+}
+
+// Milliseconds retorna as fases medidas em milissegundos.
+func (t *RequestTimings) Milliseconds() map[string]int64 {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]int64, len(t.phases))
+	for phase, d := range t.phases {
+		out[phase] = d.Milliseconds()
+	}
+	return out
+}
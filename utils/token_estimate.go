@@ -0,0 +1,29 @@
+package utils
+
+import "unicode"
+
+// EstimateTokens estima o número de tokens do texto para provedores que não informam o
+// consumo. Cada palavra conta um token a cada quatro caracteres e cada sinal de pontuação
+// conta um token, aproximando os tokenizadores BPE usados pelos modelos.
+func EstimateTokens(text string) int {
+	tokens, wordLen := 0, 0
+	flush := func() {
+		if wordLen > 0 {
+			tokens += (wordLen + 3) / 4
+			wordLen = 0
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			wordLen++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
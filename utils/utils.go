@@ -3,9 +3,24 @@ package utils
 import (
 	"bytes"
 	"io"
+	"unicode/utf8"
 )
 
 // NewJSONReader cria um io.Reader a partir de um []byte para requisições HTTP.
 func NewJSONReader(data []byte) io.Reader {
 	return bytes.NewReader(data)
 }
+
+// TruncateUTF8 corta s em até max bytes sem dividir um caractere multibyte.
+func TruncateUTF8(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
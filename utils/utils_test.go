@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{"ascii curto", "abc", 10, "abc"},
+		{"ascii cortado", "abcdef", 3, "abc"},
+		{"limite zero", "abc", 0, ""},
+		{"limite negativo", "abc", -1, ""},
+		{"acento inteiro", "ação", 3, "aç"},
+		{"acento no meio", "ação", 2, "a"},
+		{"emoji inteiro", "a😀b", 5, "a😀"},
+		{"emoji no meio", "a😀b", 3, "a"},
+		{"só emoji", "😀😀", 7, "😀"},
+		{"antes do primeiro caractere", "😀", 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateUTF8(tt.s, tt.max)
+			if got != tt.want {
+				t.Errorf("TruncateUTF8(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateUTF8(%q, %d) = %q, UTF-8 inválido", tt.s, tt.max, got)
+			}
+			if tt.max >= 0 && len(got) > tt.max {
+				t.Errorf("TruncateUTF8(%q, %d) tem %d bytes", tt.s, tt.max, len(got))
+			}
+		})
+	}
+}